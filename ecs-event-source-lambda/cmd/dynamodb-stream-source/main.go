@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// AWS Service factory, replaced by fakes in tests
+var newAWSService = internal.NewAWSServiceFromConfig
+
+// HandleRequest maps DynamoDB stream records to notification messages and publishes
+// them to the observer queue. Stream records are processed in order, the first failed
+// record is reported as batch item failure and the stream is retried from it
+func HandleRequest(ctx context.Context, event *events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	requestId := internal.RequestIdFromContext(ctx)
+	response := events.DynamoDBEventResponse{BatchItemFailures: []events.DynamoDBBatchItemFailure{}}
+
+	awsService, err := newAWSService(ctx)
+	if err != nil {
+		return response, err
+	}
+
+	sqsQueueURL, keyNotExists := os.LookupEnv("SQS_QUEUE_URL")
+	if !keyNotExists {
+		slog.Error("Environment variable value is missing", "Key", "SQS_QUEUE_URL")
+		return response, fmt.Errorf("environment key missing: %v", "SQS_QUEUE_URL")
+	}
+
+	// Item attribute naming the cluster, optional comma separated payload attributes and event names
+	mapping, mappingErr := internal.ParseStreamMapping(os.Getenv("CLUSTER_ATTRIBUTE"), os.Getenv("PAYLOAD_ATTRIBUTES"), os.Getenv("EVENT_NAMES"))
+	if mappingErr != nil {
+		slog.Error("Invalid environment variable value", "requestId", requestId, "errorMessage", mappingErr)
+		return response, mappingErr
+	}
+
+	for _, record := range event.Records {
+		if err := handleRecord(ctx, awsService, sqsQueueURL, mapping, &record); err != nil {
+			slog.Error("Failed to process stream record", "requestId", requestId, "eventId", record.EventID, "errorMessage", err)
+			// Permanent failures fail every retry, acknowledged to unblock the shard
+			if failure.IsPermanent(err) {
+				continue
+			}
+			// Later records are retried after the failed record, keeping stream order
+			response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{ItemIdentifier: record.Change.SequenceNumber})
+			break
+		}
+	}
+	return response, nil
+}
+
+// Map the stream record to a notification message and publish it
+func handleRecord(ctx context.Context, awsService *internal.AWSService, sqsQueueURL string, mapping *internal.StreamMapping, record *events.DynamoDBEventRecord) error {
+	requestId := internal.RequestIdFromContext(ctx)
+	slog.Info("Received Stream Record Details", "requestId", requestId, "eventId", record.EventID, "eventName", record.EventName, "eventSourceArn", record.EventSourceArn)
+
+	ecsNotify, err := mapping.EcsNotify(record)
+	if err != nil {
+		return err
+	}
+	if ecsNotify == nil {
+		slog.Info("Stream record event not notified", "requestId", requestId, "eventId", record.EventID, "eventName", record.EventName)
+		return nil
+	}
+
+	msgId, err := awsService.PublishEcsNotify(ctx, sqsQueueURL, ecsNotify)
+	if err != nil {
+		return err
+	}
+	slog.Info("Message published successfully", "requestId", requestId, "notificationId", ecsNotify.NotificationId, "cluster", ecsNotify.Cluster, "messageId", *msgId)
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
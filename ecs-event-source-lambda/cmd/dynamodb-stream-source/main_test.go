@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/smithy-go"
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal/fake"
+)
+
+// Replace AWS Service factory with fakes for the duration of the test
+func useFakeAWSService(t *testing.T, sqsClient *fake.SQS) {
+	original := newAWSService
+	newAWSService = func(ctx context.Context) (*internal.AWSService, error) {
+		return internal.NewAWSService(sqsClient), nil
+	}
+	t.Cleanup(func() { newAWSService = original })
+}
+
+// Stream record of an item with the given cluster attribute, none when empty
+func streamRecord(sequenceNumber string, eventName string, cluster string) events.DynamoDBEventRecord {
+	item := map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute("item-" + sequenceNumber)}
+	if cluster != "" {
+		item["cluster"] = events.NewStringAttribute(cluster)
+	}
+	record := events.DynamoDBEventRecord{EventID: "event-" + sequenceNumber, EventName: eventName}
+	record.Change.SequenceNumber = sequenceNumber
+	record.Change.NewImage = item
+	return record
+}
+
+func TestHandlerMissingQueueURL(t *testing.T) {
+	useFakeAWSService(t, fake.NewSQS())
+	// Setenv restores the original value on cleanup
+	t.Setenv("SQS_QUEUE_URL", "")
+	os.Unsetenv("SQS_QUEUE_URL")
+	t.Setenv("CLUSTER_ATTRIBUTE", "cluster")
+
+	if _, err := HandleRequest(context.TODO(), &events.DynamoDBEvent{}); err == nil {
+		t.Error("HandleRequest() expected missing environment key error")
+	}
+}
+
+func TestHandlerInvalidMapping(t *testing.T) {
+	useFakeAWSService(t, fake.NewSQS())
+	t.Setenv("SQS_QUEUE_URL", "observer-queue-url")
+	t.Setenv("CLUSTER_ATTRIBUTE", "")
+
+	if _, err := HandleRequest(context.TODO(), &events.DynamoDBEvent{}); err == nil {
+		t.Error("HandleRequest() expected invalid stream mapping error")
+	}
+}
+
+var handlerTests = map[string]struct {
+	records      []events.DynamoDBEventRecord
+	eventNames   string
+	sendErr      error
+	failAfter    int
+	wantFailures []string
+	wantClusters []string
+}{
+	"all records published": {
+		[]events.DynamoDBEventRecord{streamRecord("1", "INSERT", "cluster-a"), streamRecord("2", "MODIFY", "cluster-b")},
+		"", nil, 0, []string{}, []string{"cluster-a", "cluster-b"},
+	},
+	"event names filtered": {
+		[]events.DynamoDBEventRecord{streamRecord("1", "INSERT", "cluster-a"), streamRecord("2", "MODIFY", "cluster-b")},
+		"MODIFY", nil, 0, []string{}, []string{"cluster-b"},
+	},
+	"missing cluster acknowledged": {
+		[]events.DynamoDBEventRecord{streamRecord("1", "INSERT", ""), streamRecord("2", "INSERT", "cluster-b")},
+		"", nil, 0, []string{}, []string{"cluster-b"},
+	},
+	"publish error stops at failed record": {
+		[]events.DynamoDBEventRecord{streamRecord("1", "INSERT", "cluster-a"), streamRecord("2", "INSERT", "cluster-b"), streamRecord("3", "INSERT", "cluster-c")},
+		"", errors.New("connection reset by peer"), 1, []string{"2"}, []string{"cluster-a"},
+	},
+	"permanent publish error acknowledged": {
+		[]events.DynamoDBEventRecord{streamRecord("1", "INSERT", "cluster-a")},
+		"", &smithy.GenericAPIError{Code: "AccessDenied"}, 0, []string{}, nil,
+	},
+}
+
+func TestHandleRequest(t *testing.T) {
+	for name, tc := range handlerTests {
+		t.Run(name, func(t *testing.T) {
+			sqsClient := fake.NewSQS()
+			sqsClient.Err, sqsClient.FailAfter = tc.sendErr, tc.failAfter
+			useFakeAWSService(t, sqsClient)
+			t.Setenv("SQS_QUEUE_URL", "observer-queue-url")
+			t.Setenv("CLUSTER_ATTRIBUTE", "cluster")
+			t.Setenv("EVENT_NAMES", tc.eventNames)
+
+			response, err := HandleRequest(context.TODO(), &events.DynamoDBEvent{Records: tc.records})
+			if err != nil {
+				t.Fatalf("HandleRequest() error = %v", err)
+			}
+
+			failures := []string{}
+			for _, failure := range response.BatchItemFailures {
+				failures = append(failures, failure.ItemIdentifier)
+			}
+			if !reflect.DeepEqual(failures, tc.wantFailures) {
+				t.Errorf("HandleRequest() failures = %v, want %v", failures, tc.wantFailures)
+			}
+
+			var clusters []string
+			for _, body := range sqsClient.Messages["observer-queue-url"] {
+				var ecsNotify internal.EcsNotify
+				if err := json.Unmarshal([]byte(body), &ecsNotify); err != nil {
+					t.Fatal(err)
+				}
+				clusters = append(clusters, ecsNotify.Cluster)
+			}
+			if !reflect.DeepEqual(clusters, tc.wantClusters) {
+				t.Errorf("published clusters = %v, want %v", clusters, tc.wantClusters)
+			}
+		})
+	}
+}
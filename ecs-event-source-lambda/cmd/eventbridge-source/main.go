@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// AWS Service factory, replaced by fakes in tests
+var newAWSService = internal.NewAWSServiceFromConfig
+
+// HandleRequest maps the EventBridge event to a notification message and publishes
+// it to the observer queue. EventBridge invokes the Lambda asynchronously, a transient
+// failure fails the invocation and the event is retried by Lambda
+func HandleRequest(ctx context.Context, event *events.CloudWatchEvent) error {
+	requestId := internal.RequestIdFromContext(ctx)
+	slog.Info("Received EventBridge Event Details", "requestId", requestId, "eventId", event.ID, "source", event.Source, "detailType", event.DetailType)
+
+	awsService, err := newAWSService(ctx)
+	if err != nil {
+		return err
+	}
+
+	sqsQueueURL, keyNotExists := os.LookupEnv("SQS_QUEUE_URL")
+	if !keyNotExists {
+		slog.Error("Environment variable value is missing", "Key", "SQS_QUEUE_URL")
+		return fmt.Errorf("environment key missing: %v", "SQS_QUEUE_URL")
+	}
+
+	// JSONPath or literal cluster and optional topic, optional payload JSONPath ($.detail by default)
+	mapping, mappingErr := internal.ParseEventMapping(os.Getenv("CLUSTER_PATH"), os.Getenv("TOPIC_PATH"), os.Getenv("PAYLOAD_PATH"))
+	if mappingErr != nil {
+		slog.Error("Invalid environment variable value", "requestId", requestId, "errorMessage", mappingErr)
+		return mappingErr
+	}
+
+	if err := handleEvent(ctx, awsService, sqsQueueURL, mapping, event); err != nil {
+		slog.Error("Failed to process EventBridge event", "requestId", requestId, "eventId", event.ID, "errorMessage", err)
+		// Permanent failures fail every retry, dropped
+		if failure.IsPermanent(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Map the EventBridge event to a notification message and publish it
+func handleEvent(ctx context.Context, awsService *internal.AWSService, sqsQueueURL string, mapping *internal.EventMapping, event *events.CloudWatchEvent) error {
+	requestId := internal.RequestIdFromContext(ctx)
+
+	ecsNotify, err := mapping.EcsNotify(event)
+	if err != nil {
+		return err
+	}
+
+	msgId, err := awsService.PublishEcsNotify(ctx, sqsQueueURL, ecsNotify)
+	if err != nil {
+		return err
+	}
+	slog.Info("Message published successfully", "requestId", requestId, "notificationId", ecsNotify.NotificationId, "cluster", ecsNotify.Cluster, "clusters", ecsNotify.Clusters, "messageId", *msgId)
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
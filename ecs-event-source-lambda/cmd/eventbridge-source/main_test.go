@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal/fake"
+)
+
+// Replace AWS Service factory with fakes for the duration of the test
+func useFakeAWSService(t *testing.T, sqsClient *fake.SQS) {
+	original := newAWSService
+	newAWSService = func(ctx context.Context) (*internal.AWSService, error) {
+		return internal.NewAWSService(sqsClient), nil
+	}
+	t.Cleanup(func() { newAWSService = original })
+}
+
+func TestHandlerMissingQueueURL(t *testing.T) {
+	useFakeAWSService(t, fake.NewSQS())
+	// Setenv restores the original value on cleanup
+	t.Setenv("SQS_QUEUE_URL", "")
+	os.Unsetenv("SQS_QUEUE_URL")
+	t.Setenv("CLUSTER_PATH", "$.detail.cluster")
+
+	if err := HandleRequest(context.TODO(), fake.ConfigEvent(`{}`)); err == nil {
+		t.Error("HandleRequest() expected missing environment key error")
+	}
+}
+
+var handlerTests = map[string]struct {
+	clusterPath  string
+	detail       string
+	sendErr      error
+	wantErr      bool
+	wantMessages int
+}{
+	"event published":             {"$.detail.cluster", `{"cluster":"cluster-a"}`, nil, false, 1},
+	"invalid mapping fails":       {"$.detail.", `{"cluster":"cluster-a"}`, nil, true, 0},
+	"missing cluster dropped":     {"$.detail.cluster", `{"service":"service-a"}`, nil, false, 0},
+	"publish error retries event": {"$.detail.cluster", `{"cluster":"cluster-a"}`, errors.New("connection reset by peer"), true, 0},
+}
+
+func TestHandleRequest(t *testing.T) {
+	for name, tc := range handlerTests {
+		t.Run(name, func(t *testing.T) {
+			sqsClient := fake.NewSQS()
+			sqsClient.Err = tc.sendErr
+			useFakeAWSService(t, sqsClient)
+			t.Setenv("SQS_QUEUE_URL", "observer-queue-url")
+			t.Setenv("CLUSTER_PATH", tc.clusterPath)
+			t.Setenv("TOPIC_PATH", "$['detail-type']")
+
+			if err := HandleRequest(context.TODO(), fake.ConfigEvent(tc.detail)); (err != nil) != tc.wantErr {
+				t.Fatalf("HandleRequest() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			messages := sqsClient.Messages["observer-queue-url"]
+			if len(messages) != tc.wantMessages {
+				t.Fatalf("published messages = %d, want %d", len(messages), tc.wantMessages)
+			}
+			for _, body := range messages {
+				var ecsNotify internal.EcsNotify
+				if err := json.Unmarshal([]byte(body), &ecsNotify); err != nil {
+					t.Fatal(err)
+				}
+				if ecsNotify.NotificationId != "event-1" || ecsNotify.Cluster != "cluster-a" || ecsNotify.Topic != "Config Changed" {
+					t.Errorf("published message = %s", body)
+				}
+			}
+		})
+	}
+}
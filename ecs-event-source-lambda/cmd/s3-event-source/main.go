@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// AWS Service factory, replaced by fakes in tests
+var newAWSService = internal.NewAWSServiceFromConfig
+
+// HandleRequest maps S3 object created and deleted events, delivered by EventBridge,
+// to notification messages and publishes them to the observer queue. EventBridge
+// invokes the Lambda asynchronously, a transient failure fails the invocation and
+// the event is retried by Lambda
+func HandleRequest(ctx context.Context, event *events.CloudWatchEvent) error {
+	requestId := internal.RequestIdFromContext(ctx)
+	slog.Info("Received EventBridge Event Details", "requestId", requestId, "eventId", event.ID, "source", event.Source, "detailType", event.DetailType)
+
+	awsService, err := newAWSService(ctx)
+	if err != nil {
+		return err
+	}
+
+	sqsQueueURL, keyNotExists := os.LookupEnv("SQS_QUEUE_URL")
+	if !keyNotExists {
+		slog.Error("Environment variable value is missing", "Key", "SQS_QUEUE_URL")
+		return fmt.Errorf("environment key missing: %v", "SQS_QUEUE_URL")
+	}
+
+	// Comma separated routing rules e.g. config-bucket/orders/=orders-cluster:config.reload
+	rules, rulesErr := internal.ParseRoutingRules(os.Getenv("ROUTING_RULES"))
+	if rulesErr != nil {
+		slog.Error("Invalid environment variable value", "Key", "ROUTING_RULES", "errorMessage", rulesErr)
+		return rulesErr
+	}
+
+	if err := handleEvent(ctx, awsService, sqsQueueURL, rules, event); err != nil {
+		slog.Error("Failed to process S3 event", "requestId", requestId, "eventId", event.ID, "errorMessage", err)
+		// Permanent failures fail every retry, dropped
+		if failure.IsPermanent(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Map the S3 object event to a notification message and publish it
+func handleEvent(ctx context.Context, awsService *internal.AWSService, sqsQueueURL string, rules []internal.RoutingRule, event *events.CloudWatchEvent) error {
+	requestId := internal.RequestIdFromContext(ctx)
+
+	record, err := internal.S3EventRecordOf(event)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		slog.Info("EventBridge event is not an S3 object event", "requestId", requestId, "eventId", event.ID)
+		return nil
+	}
+	slog.Info("Received S3 Event Details", "requestId", requestId, "eventName", record.EventName, "bucket", record.S3.Bucket.Name, "key", record.S3.Object.URLDecodedKey)
+
+	ecsNotify, err := internal.EcsNotifyOf(rules, record)
+	if err != nil {
+		return err
+	}
+	if ecsNotify == nil {
+		slog.Info("S3 event not notified", "requestId", requestId, "eventName", record.EventName)
+		return nil
+	}
+
+	msgId, err := awsService.PublishEcsNotify(ctx, sqsQueueURL, ecsNotify)
+	if err != nil {
+		return err
+	}
+	slog.Info("Message published successfully", "requestId", requestId, "cluster", ecsNotify.Cluster, "topic", ecsNotify.Topic, "messageId", *msgId)
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal/fake"
+)
+
+// Replace AWS Service factory with fakes for the duration of the test
+func useFakeAWSService(t *testing.T, sqsClient *fake.SQS) {
+	original := newAWSService
+	newAWSService = func(ctx context.Context) (*internal.AWSService, error) {
+		return internal.NewAWSService(sqsClient), nil
+	}
+	t.Cleanup(func() { newAWSService = original })
+}
+
+func TestHandlerMissingQueueURL(t *testing.T) {
+	useFakeAWSService(t, fake.NewSQS())
+	// Setenv restores the original value on cleanup
+	t.Setenv("SQS_QUEUE_URL", "")
+	os.Unsetenv("SQS_QUEUE_URL")
+	t.Setenv("ROUTING_RULES", "config-bucket/=cluster-a")
+
+	if err := HandleRequest(context.TODO(), fake.S3Event("Object Created", "config-bucket", "app.yaml")); err == nil {
+		t.Error("HandleRequest() expected missing environment key error")
+	}
+}
+
+func TestHandlerInvalidRoutingRules(t *testing.T) {
+	useFakeAWSService(t, fake.NewSQS())
+	t.Setenv("SQS_QUEUE_URL", "observer-queue-url")
+	t.Setenv("ROUTING_RULES", "config-bucket")
+
+	if err := HandleRequest(context.TODO(), fake.S3Event("Object Created", "config-bucket", "app.yaml")); err == nil {
+		t.Error("HandleRequest() expected invalid routing rules error")
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	sqsClient := fake.NewSQS()
+	useFakeAWSService(t, sqsClient)
+	t.Setenv("SQS_QUEUE_URL", "observer-queue-url")
+	t.Setenv("ROUTING_RULES", "config-bucket/orders/=orders-cluster:config.reload,config-bucket/=*")
+
+	s3Events := []*events.CloudWatchEvent{
+		fake.S3Event("Object Created", "config-bucket", "orders/app config.yaml"),
+		fake.S3Event("Object Deleted", "config-bucket", "payments/app.yaml"),
+		fake.S3Event("Object Created", "unrouted-bucket", "orders/app.yaml"),
+		fake.S3Event("Object Restore Completed", "config-bucket", "orders/app.yaml"),
+		fake.ConfigEvent(`{"cluster":"cluster-a"}`),
+	}
+	for _, event := range s3Events {
+		if err := HandleRequest(context.TODO(), event); err != nil {
+			t.Fatalf("HandleRequest() error = %v", err)
+		}
+	}
+
+	var actual []string
+	for _, body := range sqsClient.Messages["observer-queue-url"] {
+		var ecsNotify internal.EcsNotify
+		var payload internal.S3ObjectEvent
+		if err := json.Unmarshal([]byte(body), &ecsNotify); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(ecsNotify.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, ecsNotify.Cluster+" "+ecsNotify.Topic+" "+payload.Key)
+	}
+	want := []string{"orders-cluster config.reload orders/app config.yaml", "*  payments/app.yaml"}
+	if !reflect.DeepEqual(actual, want) {
+		t.Errorf("published messages = %q, want %q", actual, want)
+	}
+}
+
+func TestHandleRequestPublishError(t *testing.T) {
+	sqsClient := fake.NewSQS()
+	sqsClient.Err = errors.New("connection reset by peer")
+	useFakeAWSService(t, sqsClient)
+	t.Setenv("SQS_QUEUE_URL", "observer-queue-url")
+	t.Setenv("ROUTING_RULES", "config-bucket/=cluster-a")
+
+	if err := HandleRequest(context.TODO(), fake.S3Event("Object Created", "config-bucket", "app.yaml")); err == nil {
+		t.Error("HandleRequest() expected transient publish error to retry the event")
+	}
+}
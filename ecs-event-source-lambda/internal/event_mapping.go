@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// Default JSONPath of the payload, the detail of the event
+const DefaultPayloadPath = "$.detail"
+
+// Value of the notification, selected from the event by JSONPath or a literal
+// value for expressions not starting with $ e.g. "*" for all clusters
+type valueExpression struct {
+	literal  string
+	jsonPath *JSONPath
+}
+
+func parseValueExpression(expression string) (*valueExpression, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(expression, "$") {
+		return &valueExpression{literal: expression}, nil
+	}
+	jsonPath, err := ParseJSONPath(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &valueExpression{jsonPath: jsonPath}, nil
+}
+
+// String values of the expression, strings of selected arrays included
+func (expression *valueExpression) strings(document interface{}) []string {
+	if expression.jsonPath == nil {
+		return []string{expression.literal}
+	}
+	var values []string
+	for _, value := range expression.jsonPath.Evaluate(document) {
+		switch selected := value.(type) {
+		case string:
+			values = append(values, selected)
+		case []interface{}:
+			for _, element := range selected {
+				if s, ok := element.(string); ok {
+					values = append(values, s)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// Mapping of EventBridge events to notification messages
+type EventMapping struct {
+	cluster *valueExpression
+	topic   *valueExpression
+	payload *JSONPath
+}
+
+// Parse the event mapping from the cluster, optional topic and payload expressions
+// e.g. "$.detail.cluster", "$['detail-type']" and "$.detail" (default payload)
+func ParseEventMapping(clusterExpression string, topicExpression string, payloadPath string) (*EventMapping, error) {
+	cluster, err := parseValueExpression(clusterExpression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CLUSTER_PATH: %w", err)
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf("CLUSTER_PATH missing")
+	}
+	topic, err := parseValueExpression(topicExpression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOPIC_PATH: %w", err)
+	}
+
+	if strings.TrimSpace(payloadPath) == "" {
+		payloadPath = DefaultPayloadPath
+	}
+	payload, err := ParseJSONPath(payloadPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PAYLOAD_PATH: %w", err)
+	}
+	return &EventMapping{cluster: cluster, topic: topic, payload: payload}, nil
+}
+
+// Map the EventBridge event to a notification message. Several selected clusters
+// are notified together. Events without cluster fail every retry, reported as permanent error
+func (mapping *EventMapping) EcsNotify(event *events.CloudWatchEvent) (*EcsNotify, error) {
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return nil, failure.Permanent(err)
+	}
+	var document interface{}
+	if err := json.Unmarshal(eventBytes, &document); err != nil {
+		return nil, failure.Permanent(err)
+	}
+
+	ecsNotify := NewEcsNotify()
+	// EventBridge event Id identifies the notification, retries keep the Id
+	ecsNotify.NotificationId = event.ID
+
+	clusters := mapping.cluster.strings(document)
+	switch len(clusters) {
+	case 0:
+		return nil, failure.Permanent(fmt.Errorf("cluster of %s missing in event %s", mapping.cluster.jsonPath, event.ID))
+	case 1:
+		ecsNotify.Cluster = clusters[0]
+	default:
+		ecsNotify.Clusters = clusters
+	}
+
+	if mapping.topic != nil {
+		if topics := mapping.topic.strings(document); len(topics) > 0 {
+			ecsNotify.Topic = topics[0]
+		}
+	}
+
+	var payload interface{}
+	if values := mapping.payload.Evaluate(document); len(values) > 0 && mapping.payload.IsWildcard() {
+		payload = values
+	} else if len(values) > 0 {
+		payload = values[0]
+	}
+	if payload != nil {
+		if ecsNotify.Payload, err = json.Marshal(payload); err != nil {
+			return nil, failure.Permanent(err)
+		}
+	}
+	return ecsNotify, nil
+}
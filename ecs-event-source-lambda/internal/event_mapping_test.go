@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+var parseEventMappingTests = map[string]struct {
+	cluster string
+	topic   string
+	payload string
+	// Environment key named by the error
+	wantErrKey string
+}{
+	"cluster path only":    {"$.detail.cluster", "", "", ""},
+	"literal cluster":      {"*", "$['detail-type']", "$.detail.config", ""},
+	"missing cluster":      {" ", "config.reload", "", "CLUSTER_PATH"},
+	"invalid cluster path": {"$.detail.", "", "", "CLUSTER_PATH"},
+	"invalid topic path":   {"$.detail.cluster", "$[", "", "TOPIC_PATH"},
+	"literal payload":      {"$.detail.cluster", "", "detail", "PAYLOAD_PATH"},
+}
+
+func TestParseEventMapping(t *testing.T) {
+	for name, tc := range parseEventMappingTests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseEventMapping(tc.cluster, tc.topic, tc.payload)
+			if (err != nil) != (tc.wantErrKey != "") || (err != nil && !strings.Contains(err.Error(), tc.wantErrKey)) {
+				t.Errorf("ParseEventMapping() error = %v, want error of %q", err, tc.wantErrKey)
+			}
+		})
+	}
+}
+
+var eventMappingTests = map[string]struct {
+	cluster      string
+	topic        string
+	payload      string
+	detail       string
+	wantCluster  string
+	wantClusters []string
+	wantTopic    string
+	wantPayload  string
+	wantErr      bool
+}{
+	"cluster and detail payload": {
+		"$.detail.cluster", "", "", `{"cluster":"cluster-a","key":"app.yaml"}`,
+		"cluster-a", nil, "", `{"cluster":"cluster-a","key":"app.yaml"}`, false,
+	},
+	"clusters of array and topic of detail type": {
+		"$.detail.clusters", "$['detail-type']", "$.detail.key", `{"clusters":["cluster-a","cluster-b"],"key":"app.yaml"}`,
+		"", []string{"cluster-a", "cluster-b"}, "Config Changed", `"app.yaml"`, false,
+	},
+	"clusters of wildcard and payload wildcard": {
+		"$.detail.targets[*].cluster", "config.reload", "$.detail.targets[*].version", `{"targets":[{"cluster":"cluster-a","version":1},{"cluster":"cluster-b","version":2}]}`,
+		"", []string{"cluster-a", "cluster-b"}, "config.reload", `[1,2]`, false,
+	},
+	"literal cluster and missing payload": {
+		"*", "$.detail.topic", "$.detail.payload", `{"key":"app.yaml"}`,
+		"*", nil, "", "", false,
+	},
+	"missing cluster": {
+		"$.detail.cluster", "", "", `{"key":"app.yaml"}`,
+		"", nil, "", "", true,
+	},
+}
+
+func TestEventMappingEcsNotify(t *testing.T) {
+	for name, tc := range eventMappingTests {
+		t.Run(name, func(t *testing.T) {
+			mapping, err := ParseEventMapping(tc.cluster, tc.topic, tc.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := mapping.EcsNotify(fake.ConfigEvent(tc.detail))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("EcsNotify() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if !failure.IsPermanent(err) {
+					t.Errorf("EcsNotify() error = %v, want permanent error", err)
+				}
+				return
+			}
+			if actual.NotificationId != "event-1" || actual.Cluster != tc.wantCluster || !reflect.DeepEqual(actual.Clusters, tc.wantClusters) ||
+				actual.Topic != tc.wantTopic || string(actual.Payload) != tc.wantPayload {
+				t.Errorf("EcsNotify() = %+v, want %s %v %s %s", actual, tc.wantCluster, tc.wantClusters, tc.wantTopic, tc.wantPayload)
+			}
+		})
+	}
+}
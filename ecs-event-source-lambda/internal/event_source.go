@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// Get AWSRequestId from Lambda Context Object
+func RequestIdFromContext(ctx context.Context) string {
+	var requestId string = "x"
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		requestId = lc.AwsRequestID
+	}
+	return requestId
+}
+
+type AWSService struct {
+	sqsClient SQSClient
+}
+
+// Build AWS Service on top of given clients, fakes are injected in tests
+func NewAWSService(sqsClient SQSClient) *AWSService {
+	awsService := &AWSService{}
+
+	return awsService.withSQSClient(sqsClient)
+}
+
+// Build AWS Service with clients of AWS default config
+func NewAWSServiceFromConfig(ctx context.Context) (*AWSService, error) {
+	requestId := RequestIdFromContext(ctx)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		slog.Error("Failed to load default config", "requestId", requestId, "errorMessage", err)
+		return nil, err
+	}
+
+	return NewAWSService(sqs.NewFromConfig(cfg)), nil
+}
+
+func (awsService *AWSService) withSQSClient(sqsClient SQSClient) *AWSService {
+	awsService.sqsClient = sqsClient
+	return awsService
+}
+
+// Publish the notification message to the observer queue of the ECS Service Discovery Lambda
+func (awsService *AWSService) PublishEcsNotify(ctx context.Context, sqsQueueURL string, ecsNotify *EcsNotify) (*string, error) {
+	requestId := RequestIdFromContext(ctx)
+
+	msgJsonBytes, jsonMarshalErr := json.Marshal(ecsNotify)
+	if jsonMarshalErr != nil {
+		slog.Error("Failed to marshal notification message", "requestId", requestId, "errorMessage", jsonMarshalErr)
+		return nil, failure.Permanent(jsonMarshalErr)
+	}
+
+	sendMsgOutput, sendMsgErr := awsService.sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		MessageBody: aws.String(string(msgJsonBytes)),
+		QueueUrl:    aws.String(sqsQueueURL),
+	})
+	if sendMsgErr != nil {
+		slog.Error("Failed to publish message to SQS", "requestId", requestId, "errorMessage", sendMsgErr)
+		return nil, sendMsgErr
+	}
+
+	return sendMsgOutput.MessageId, nil
+}
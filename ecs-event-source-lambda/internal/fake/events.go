@@ -0,0 +1,31 @@
+package fake
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// EventBridge event of a config change with the given detail
+func ConfigEvent(detail string) *events.CloudWatchEvent {
+	return &events.CloudWatchEvent{ID: "event-1", Source: "com.example.config", DetailType: "Config Changed", Detail: json.RawMessage(detail)}
+}
+
+// EventBridge event of an S3 object of the bucket, of detail type Object Created
+// of objects put, or Object Deleted of objects deleted
+func S3Event(detailType string, bucket string, key string) *events.CloudWatchEvent {
+	reason := "PutObject"
+	if detailType == "Object Deleted" {
+		reason = "DeleteObject"
+	}
+	detail := `{"bucket":{"name":"` + bucket + `"},"object":{"key":"` + key + `","size":42,"etag":"etag-1","version-id":"v1"},"reason":"` + reason + `"}`
+	return &events.CloudWatchEvent{
+		ID:         "event-1",
+		Source:     "aws.s3",
+		DetailType: detailType,
+		Time:       time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC),
+		Region:     "us-east-1",
+		Detail:     json.RawMessage(detail),
+	}
+}
@@ -0,0 +1,43 @@
+package fake
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// In-memory SQS recording sent message bodies by queue URL
+type SQS struct {
+	mu sync.Mutex
+
+	// Sent message bodies by queue URL
+	Messages map[string][]string
+	// Error returned by SendMessage
+	Err error
+	// SendMessage fails once this many messages are sent, when Err is set
+	FailAfter int
+}
+
+func NewSQS() *SQS {
+	return &SQS{Messages: make(map[string][]string)}
+}
+
+func (fake *SQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	sent := 0
+	for _, messages := range fake.Messages {
+		sent += len(messages)
+	}
+	if fake.Err != nil && sent >= fake.FailAfter {
+		return nil, fake.Err
+	}
+
+	queueURL := aws.ToString(params.QueueUrl)
+	fake.Messages[queueURL] = append(fake.Messages[queueURL], aws.ToString(params.MessageBody))
+	return &sqs.SendMessageOutput{MessageId: aws.String("message-" + strconv.Itoa(sent+1))}, nil
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Step of a JSONPath expression, a member name, an array index or a wildcard
+type pathStep struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// JSONPath expression of the subset $, .name, ['name'], [index], .* and [*]
+// e.g. $.detail.cluster, $['detail-type'] or $.detail.clusters[*]
+type JSONPath struct {
+	expression string
+	steps      []pathStep
+}
+
+// Parse the JSONPath expression, expressions start with $ the root of the document
+func ParseJSONPath(expression string) (*JSONPath, error) {
+	expression = strings.TrimSpace(expression)
+	if !strings.HasPrefix(expression, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q, expected $ root", expression)
+	}
+
+	jsonPath := &JSONPath{expression: expression}
+	rest := expression[1:]
+	for rest != "" {
+		var step pathStep
+		var err error
+		switch rest[0] {
+		case '.':
+			step, rest, err = parseMemberStep(rest[1:])
+		case '[':
+			step, rest, err = parseBracketStep(rest[1:])
+		default:
+			err = fmt.Errorf("unexpected %q", rest[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSONPath %q: %w", expression, err)
+		}
+		jsonPath.steps = append(jsonPath.steps, step)
+	}
+	return jsonPath, nil
+}
+
+// Member name or wildcard after a dot, up to the next step
+func parseMemberStep(rest string) (pathStep, string, error) {
+	end := strings.IndexAny(rest, ".[")
+	if end < 0 {
+		end = len(rest)
+	}
+	name := rest[:end]
+	if name == "" {
+		return pathStep{}, "", fmt.Errorf("member name missing")
+	}
+	if name == "*" {
+		return pathStep{wildcard: true}, rest[end:], nil
+	}
+	return pathStep{name: name}, rest[end:], nil
+}
+
+// Quoted member name, index or wildcard within brackets
+func parseBracketStep(rest string) (pathStep, string, error) {
+	if rest != "" && (rest[0] == '\'' || rest[0] == '"') {
+		end := strings.IndexByte(rest[1:], rest[0])
+		if end < 0 || !strings.HasPrefix(rest[end+2:], "]") {
+			return pathStep{}, "", fmt.Errorf("unterminated member name")
+		}
+		return pathStep{name: rest[1 : end+1]}, rest[end+3:], nil
+	}
+
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return pathStep{}, "", fmt.Errorf("missing ]")
+	}
+	if rest[:end] == "*" {
+		return pathStep{wildcard: true}, rest[end+1:], nil
+	}
+	index, err := strconv.Atoi(rest[:end])
+	if err != nil || index < 0 {
+		return pathStep{}, "", fmt.Errorf("invalid index %q", rest[:end])
+	}
+	return pathStep{index: index, isIndex: true}, rest[end+1:], nil
+}
+
+// Check whether the expression selects several values through a wildcard
+func (jsonPath *JSONPath) IsWildcard() bool {
+	for _, step := range jsonPath.steps {
+		if step.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+func (jsonPath *JSONPath) String() string {
+	return jsonPath.expression
+}
+
+// Values of the document selected by the expression, none when a member or
+// index is missing. Wildcards select object members in key order
+func (jsonPath *JSONPath) Evaluate(document interface{}) []interface{} {
+	values := []interface{}{document}
+	for _, step := range jsonPath.steps {
+		var selected []interface{}
+		for _, value := range values {
+			switch node := value.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					keys := make([]string, 0, len(node))
+					for key := range node {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						selected = append(selected, node[key])
+					}
+				} else if member, ok := node[step.name]; ok && !step.isIndex {
+					selected = append(selected, member)
+				}
+			case []interface{}:
+				if step.wildcard {
+					selected = append(selected, node...)
+				} else if step.isIndex && step.index < len(node) {
+					selected = append(selected, node[step.index])
+				}
+			}
+		}
+		values = selected
+	}
+	return values
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const jsonPathDocument = `{
+	"detail-type": "Config Changed",
+	"detail": {
+		"cluster": "cluster-a",
+		"clusters": ["cluster-b", "cluster-c"],
+		"targets": [{"cluster": "cluster-d"}, {"cluster": "cluster-e"}],
+		"version": 3
+	}
+}`
+
+var jsonPathTests = map[string]struct {
+	expression string
+	want       []interface{}
+	wantErr    bool
+}{
+	"root":             {"$", nil, false},
+	"member":           {"$.detail.cluster", []interface{}{"cluster-a"}, false},
+	"quoted member":    {"$['detail-type']", []interface{}{"Config Changed"}, false},
+	"double quoted":    {`$["detail"]["version"]`, []interface{}{float64(3)}, false},
+	"index":            {"$.detail.clusters[1]", []interface{}{"cluster-c"}, false},
+	"array wildcard":   {"$.detail.targets[*].cluster", []interface{}{"cluster-d", "cluster-e"}, false},
+	"member wildcard":  {"$.detail.targets.*.cluster", []interface{}{"cluster-d", "cluster-e"}, false},
+	"missing member":   {"$.detail.service", []interface{}(nil), false},
+	"index of object":  {"$.detail[0]", []interface{}(nil), false},
+	"index out of end": {"$.detail.clusters[2]", []interface{}(nil), false},
+	"missing root":     {"detail.cluster", nil, true},
+	"empty member":     {"$.detail..cluster", nil, true},
+	"negative index":   {"$.detail.clusters[-1]", nil, true},
+	"unterminated":     {"$['detail", nil, true},
+	"missing bracket":  {"$.detail.clusters[0", nil, true},
+}
+
+func TestJSONPath(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(jsonPathDocument), &document); err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range jsonPathTests {
+		t.Run(name, func(t *testing.T) {
+			jsonPath, err := ParseJSONPath(tc.expression)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseJSONPath(%q) error = %v, wantErr %v", tc.expression, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			want := tc.want
+			if tc.expression == "$" {
+				want = []interface{}{document}
+			}
+			if actual := jsonPath.Evaluate(document); !reflect.DeepEqual(actual, want) {
+				t.Errorf("Evaluate(%q) = %v, want %v", tc.expression, actual, want)
+			}
+		})
+	}
+}
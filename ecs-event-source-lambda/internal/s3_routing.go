@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// Notified S3 event name prefixes e.g. ObjectCreated:PutObject or ObjectRemoved:DeleteObject
+const (
+	EventNameObjectCreated = "ObjectCreated:"
+	EventNameObjectRemoved = "ObjectRemoved:"
+)
+
+// Detail types of S3 object events delivered by EventBridge, by S3 event name prefix
+var s3EventNamePrefixes = map[string]string{
+	"Object Created": EventNameObjectCreated,
+	"Object Deleted": EventNameObjectRemoved,
+}
+
+// Detail of S3 object events delivered by EventBridge
+type S3EventDetail struct {
+	Bucket struct {
+		Name string `json:"name"`
+	} `json:"bucket"`
+	Object struct {
+		Key       string `json:"key"`
+		Size      int64  `json:"size"`
+		ETag      string `json:"etag"`
+		VersionId string `json:"version-id"`
+	} `json:"object"`
+	// API call or lifecycle action of the event e.g. PutObject or Lifecycle Expiration
+	Reason string `json:"reason"`
+}
+
+// Route objects of the bucket under the key prefix to the ECS cluster and topic
+type RoutingRule struct {
+	Bucket  string
+	Prefix  string
+	Cluster string
+	Topic   string
+}
+
+// Parse comma separated routing rules bucket/prefix=cluster[:topic] e.g.
+// "config-bucket/orders/=orders-cluster:config.reload,config-bucket/=*:config.reload".
+// Clusters are names or globs e.g. "*" for all clusters, an empty prefix matches all keys
+func ParseRoutingRules(value string) ([]RoutingRule, error) {
+	var rules []RoutingRule
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		location, target, ok := strings.Cut(item, "=")
+		bucket, prefix, _ := strings.Cut(strings.TrimSpace(location), "/")
+		cluster, topic, _ := strings.Cut(strings.TrimSpace(target), ":")
+		if !ok || bucket == "" || strings.TrimSpace(cluster) == "" {
+			return nil, fmt.Errorf("invalid routing rule %q, expected bucket/prefix=cluster[:topic]", item)
+		}
+		rules = append(rules, RoutingRule{Bucket: bucket, Prefix: prefix, Cluster: strings.TrimSpace(cluster), Topic: strings.TrimSpace(topic)})
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("routing rules missing")
+	}
+	return rules, nil
+}
+
+// S3 event record of the EventBridge S3 object event, named after the S3 event name
+// prefix and the reason e.g. ObjectCreated:PutObject. Nil for other events, an invalid
+// detail fails every retry, reported as permanent error
+func S3EventRecordOf(event *events.CloudWatchEvent) (*events.S3EventRecord, error) {
+	eventNamePrefix, ok := s3EventNamePrefixes[event.DetailType]
+	if !ok || event.Source != "aws.s3" {
+		return nil, nil
+	}
+
+	var detail S3EventDetail
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return nil, failure.Permanent(fmt.Errorf("invalid S3 event detail: %w", err))
+	}
+
+	record := &events.S3EventRecord{
+		EventSource: event.Source,
+		EventName:   eventNamePrefix + strings.ReplaceAll(detail.Reason, " ", ""),
+		EventTime:   event.Time,
+		AWSRegion:   event.Region,
+	}
+	record.S3.Bucket.Name = detail.Bucket.Name
+	record.S3.Object = events.S3Object{
+		Key:           detail.Object.Key,
+		URLDecodedKey: detail.Object.Key,
+		VersionID:     detail.Object.VersionId,
+		ETag:          detail.Object.ETag,
+		Size:          detail.Object.Size,
+	}
+	return record, nil
+}
+
+// Routing rule of the object, the rule of the longest matching prefix wins. Nil without rule
+func Route(rules []RoutingRule, bucket string, key string) *RoutingRule {
+	var route *RoutingRule
+	for i, rule := range rules {
+		if rule.Bucket != bucket || !strings.HasPrefix(key, rule.Prefix) {
+			continue
+		}
+		if route == nil || len(rule.Prefix) > len(route.Prefix) {
+			route = &rules[i]
+		}
+	}
+	return route
+}
+
+// Map the S3 event record to a notification message, nil for events other than
+// created and removed objects. Objects without routing rule fail every retry,
+// reported as permanent error
+func EcsNotifyOf(rules []RoutingRule, record *events.S3EventRecord) (*EcsNotify, error) {
+	if !strings.HasPrefix(record.EventName, EventNameObjectCreated) && !strings.HasPrefix(record.EventName, EventNameObjectRemoved) {
+		return nil, nil
+	}
+
+	bucket, object := record.S3.Bucket.Name, record.S3.Object
+	rule := Route(rules, bucket, object.URLDecodedKey)
+	if rule == nil {
+		return nil, failure.Permanent(fmt.Errorf("no routing rule of object %s/%s", bucket, object.URLDecodedKey))
+	}
+
+	payload, err := json.Marshal(&S3ObjectEvent{
+		EventName: record.EventName,
+		EventTime: record.EventTime.UTC().Format(time.RFC3339),
+		Bucket:    bucket,
+		Key:       object.URLDecodedKey,
+		VersionId: object.VersionID,
+		ETag:      object.ETag,
+		Size:      object.Size,
+	})
+	if err != nil {
+		return nil, failure.Permanent(err)
+	}
+
+	ecsNotify := NewEcsNotify()
+	ecsNotify.Cluster = rule.Cluster
+	ecsNotify.Topic = rule.Topic
+	ecsNotify.Payload = payload
+	return ecsNotify, nil
+}
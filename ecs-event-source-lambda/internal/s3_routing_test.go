@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jittakal/ecs-task-notifier/ecs-event-source-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+var parseRoutingRulesTests = map[string]struct {
+	value   string
+	want    []RoutingRule
+	wantErr bool
+}{
+	"cluster and topic": {
+		"config-bucket/orders/=orders-cluster:config.reload, config-bucket/=*",
+		[]RoutingRule{
+			{Bucket: "config-bucket", Prefix: "orders/", Cluster: "orders-cluster", Topic: "config.reload"},
+			{Bucket: "config-bucket", Prefix: "", Cluster: "*"},
+		},
+		false,
+	},
+	"bucket without prefix": {"config-bucket=cluster-a", []RoutingRule{{Bucket: "config-bucket", Cluster: "cluster-a"}}, false},
+	"missing cluster":       {"config-bucket/orders/=:config.reload", nil, true},
+	"missing bucket":        {"/orders/=cluster-a", nil, true},
+	"missing target":        {"config-bucket/orders/", nil, true},
+	"no rules":              {" , ", nil, true},
+}
+
+func TestParseRoutingRules(t *testing.T) {
+	for name, tc := range parseRoutingRulesTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseRoutingRules(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseRoutingRules() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(actual, tc.want) {
+				t.Errorf("ParseRoutingRules() = %+v, want %+v", actual, tc.want)
+			}
+		})
+	}
+}
+
+var routingRules = []RoutingRule{
+	{Bucket: "config-bucket", Prefix: "", Cluster: "*", Topic: "config.reload"},
+	{Bucket: "config-bucket", Prefix: "orders/", Cluster: "orders-cluster", Topic: "orders.config"},
+	{Bucket: "other-bucket", Prefix: "orders/", Cluster: "other-cluster"},
+}
+
+// S3 event record of an object of config-bucket
+func s3EventRecord(eventName string, key string) *events.S3EventRecord {
+	record := &events.S3EventRecord{EventName: eventName, EventTime: time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)}
+	record.S3.Bucket.Name = "config-bucket"
+	record.S3.Object = events.S3Object{Key: key, URLDecodedKey: key, VersionID: "v1", ETag: "etag-1", Size: 42}
+	return record
+}
+
+var ecsNotifyOfTests = map[string]struct {
+	record      *events.S3EventRecord
+	wantCluster string
+	wantTopic   string
+	wantPayload string
+	wantSkipped bool
+	wantErr     bool
+}{
+	"object created of longest prefix": {
+		s3EventRecord("ObjectCreated:Put", "orders/app.yaml"), "orders-cluster", "orders.config",
+		`{"event_name":"ObjectCreated:Put","event_time":"2024-04-01T10:00:00Z","bucket":"config-bucket","key":"orders/app.yaml","version_id":"v1","etag":"etag-1","size":42}`,
+		false, false,
+	},
+	"object removed of bucket rule": {
+		s3EventRecord("ObjectRemoved:Delete", "payments/app.yaml"), "*", "config.reload",
+		`{"event_name":"ObjectRemoved:Delete","event_time":"2024-04-01T10:00:00Z","bucket":"config-bucket","key":"payments/app.yaml","version_id":"v1","etag":"etag-1","size":42}`,
+		false, false,
+	},
+	"other event skipped": {s3EventRecord("ObjectRestore:Completed", "orders/app.yaml"), "", "", "", true, false},
+}
+
+func TestEcsNotifyOf(t *testing.T) {
+	for name, tc := range ecsNotifyOfTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := EcsNotifyOf(routingRules, tc.record)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("EcsNotifyOf() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if (actual == nil) != tc.wantSkipped {
+				t.Fatalf("EcsNotifyOf() = %v, want skipped %v", actual, tc.wantSkipped)
+			}
+			if tc.wantSkipped {
+				return
+			}
+			if actual.Cluster != tc.wantCluster || actual.Topic != tc.wantTopic || string(actual.Payload) != tc.wantPayload {
+				t.Errorf("EcsNotifyOf() = %s %s %s, want %s %s %s", actual.Cluster, actual.Topic, actual.Payload, tc.wantCluster, tc.wantTopic, tc.wantPayload)
+			}
+		})
+	}
+}
+
+func TestEcsNotifyOfWithoutRoute(t *testing.T) {
+	record := s3EventRecord("ObjectCreated:Put", "orders/app.yaml")
+	record.S3.Bucket.Name = "unrouted-bucket"
+	if _, err := EcsNotifyOf(routingRules, record); !failure.IsPermanent(err) {
+		t.Errorf("EcsNotifyOf() error = %v, want permanent error", err)
+	}
+}
+
+var s3EventRecordOfTests = map[string]struct {
+	event         *events.CloudWatchEvent
+	wantEventName string
+	wantSkipped   bool
+	wantErr       bool
+}{
+	"object created":  {fake.S3Event("Object Created", "config-bucket", "orders/app.yaml"), "ObjectCreated:PutObject", false, false},
+	"object deleted":  {fake.S3Event("Object Deleted", "config-bucket", "orders/app.yaml"), "ObjectRemoved:DeleteObject", false, false},
+	"object restored": {fake.S3Event("Object Restore Completed", "config-bucket", "orders/app.yaml"), "", true, false},
+	"other source":    {fake.ConfigEvent(`{"cluster":"cluster-a"}`), "", true, false},
+	"invalid detail":  {&events.CloudWatchEvent{Source: "aws.s3", DetailType: "Object Created", Detail: []byte(`"bucket"`)}, "", false, true},
+}
+
+func TestS3EventRecordOf(t *testing.T) {
+	for name, tc := range s3EventRecordOfTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := S3EventRecordOf(tc.event)
+			if (err != nil) != tc.wantErr || (err != nil && !failure.IsPermanent(err)) {
+				t.Fatalf("S3EventRecordOf() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if (actual == nil) != tc.wantSkipped {
+				t.Fatalf("S3EventRecordOf() = %+v, want skipped %v", actual, tc.wantSkipped)
+			}
+			if tc.wantSkipped {
+				return
+			}
+			want := s3EventRecord(tc.wantEventName, "orders/app.yaml")
+			want.EventSource, want.AWSRegion = "aws.s3", "us-east-1"
+			if !reflect.DeepEqual(actual, want) {
+				t.Errorf("S3EventRecordOf() = %+v, want %+v", actual, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// Stream record event names
+const (
+	EventNameInsert = "INSERT"
+	EventNameModify = "MODIFY"
+	EventNameRemove = "REMOVE"
+)
+
+// Mapping of DynamoDB stream records to notification messages
+type StreamMapping struct {
+	// Item attribute naming the ECS cluster to notify
+	ClusterAttribute string
+	// Item attributes sent as payload, all attributes of the item when empty
+	PayloadAttributes []string
+	// Event names notified, all event names when empty
+	EventNames []string
+}
+
+// Parse the stream mapping from the cluster attribute name and comma separated
+// payload attribute and event names e.g. "cluster", "id,version", "INSERT,MODIFY"
+func ParseStreamMapping(clusterAttribute string, payloadAttributes string, eventNames string) (*StreamMapping, error) {
+	mapping := &StreamMapping{ClusterAttribute: strings.TrimSpace(clusterAttribute)}
+	if mapping.ClusterAttribute == "" {
+		return nil, fmt.Errorf("CLUSTER_ATTRIBUTE missing")
+	}
+
+	mapping.PayloadAttributes = splitList(payloadAttributes)
+	for _, eventName := range splitList(eventNames) {
+		eventName = strings.ToUpper(eventName)
+		if eventName != EventNameInsert && eventName != EventNameModify && eventName != EventNameRemove {
+			return nil, fmt.Errorf("invalid EVENT_NAMES event name %q, expected INSERT, MODIFY or REMOVE", eventName)
+		}
+		mapping.EventNames = append(mapping.EventNames, eventName)
+	}
+	return mapping, nil
+}
+
+// Non-empty trimmed values of a comma separated list
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Map the stream record to a notification message, nil when the event name is
+// not notified. The item is the new image, the old image of removed items.
+// Records without cluster attribute fail every retry, reported as permanent error
+func (mapping *StreamMapping) EcsNotify(record *events.DynamoDBEventRecord) (*EcsNotify, error) {
+	if len(mapping.EventNames) > 0 && !slices.Contains(mapping.EventNames, record.EventName) {
+		return nil, nil
+	}
+
+	item := record.Change.NewImage
+	if record.EventName == EventNameRemove {
+		item = record.Change.OldImage
+	}
+	// Streams of KEYS_ONLY view type carry the keys only
+	if len(item) == 0 {
+		item = record.Change.Keys
+	}
+
+	cluster, ok := item[mapping.ClusterAttribute]
+	if !ok || cluster.DataType() != events.DataTypeString || cluster.String() == "" {
+		return nil, failure.Permanent(fmt.Errorf("cluster attribute %q missing in %s record %s", mapping.ClusterAttribute, record.EventName, record.EventID))
+	}
+
+	payload := make(map[string]interface{})
+	for name, value := range item {
+		if len(mapping.PayloadAttributes) == 0 || slices.Contains(mapping.PayloadAttributes, name) {
+			payload[name] = attributeValue(value)
+		}
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, failure.Permanent(err)
+	}
+
+	ecsNotify := NewEcsNotify()
+	// Stream event Id identifies the notification in audit records, retries keep the Id
+	ecsNotify.NotificationId = record.EventID
+	ecsNotify.Cluster = cluster.String()
+	ecsNotify.Payload = payloadBytes
+	return ecsNotify, nil
+}
+
+// Plain JSON value of the DynamoDB attribute value, numbers keep their precision
+func attributeValue(value events.DynamoDBAttributeValue) interface{} {
+	switch value.DataType() {
+	case events.DataTypeString:
+		return value.String()
+	case events.DataTypeNumber:
+		return json.Number(value.Number())
+	case events.DataTypeBoolean:
+		return value.Boolean()
+	case events.DataTypeBinary:
+		return value.Binary()
+	case events.DataTypeStringSet:
+		return value.StringSet()
+	case events.DataTypeNumberSet:
+		numbers := make([]json.Number, 0, len(value.NumberSet()))
+		for _, number := range value.NumberSet() {
+			numbers = append(numbers, json.Number(number))
+		}
+		return numbers
+	case events.DataTypeBinarySet:
+		return value.BinarySet()
+	case events.DataTypeList:
+		list := make([]interface{}, 0, len(value.List()))
+		for _, element := range value.List() {
+			list = append(list, attributeValue(element))
+		}
+		return list
+	case events.DataTypeMap:
+		attributes := make(map[string]interface{}, len(value.Map()))
+		for name, element := range value.Map() {
+			attributes[name] = attributeValue(element)
+		}
+		return attributes
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+var parseStreamMappingTests = map[string]struct {
+	clusterAttribute  string
+	payloadAttributes string
+	eventNames        string
+	want              *StreamMapping
+	// Environment key named by the error
+	wantErrKey string
+}{
+	"cluster attribute only": {"cluster", "", "", &StreamMapping{ClusterAttribute: "cluster"}, ""},
+	"payload and events":     {" cluster ", "id, version,", "insert,MODIFY", &StreamMapping{ClusterAttribute: "cluster", PayloadAttributes: []string{"id", "version"}, EventNames: []string{"INSERT", "MODIFY"}}, ""},
+	"missing cluster":        {" ", "id", "", nil, "CLUSTER_ATTRIBUTE"},
+	"invalid event name":     {"cluster", "", "INSERT,UPDATE", nil, "EVENT_NAMES"},
+}
+
+func TestParseStreamMapping(t *testing.T) {
+	for name, tc := range parseStreamMappingTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseStreamMapping(tc.clusterAttribute, tc.payloadAttributes, tc.eventNames)
+			if (err != nil) != (tc.wantErrKey != "") || (err != nil && !strings.Contains(err.Error(), tc.wantErrKey)) {
+				t.Fatalf("ParseStreamMapping() error = %v, want error of %q", err, tc.wantErrKey)
+			}
+			if err != nil {
+				return
+			}
+			if actual.ClusterAttribute != tc.want.ClusterAttribute ||
+				!slices.Equal(actual.PayloadAttributes, tc.want.PayloadAttributes) ||
+				!slices.Equal(actual.EventNames, tc.want.EventNames) {
+				t.Errorf("ParseStreamMapping() = %+v, want %+v", actual, tc.want)
+			}
+		})
+	}
+}
+
+// Stream record of an item of cluster-a with nested and set attributes
+func streamRecord(eventName string) *events.DynamoDBEventRecord {
+	item := map[string]events.DynamoDBAttributeValue{
+		"id":      events.NewStringAttribute("item-1"),
+		"cluster": events.NewStringAttribute("cluster-a"),
+		"version": events.NewNumberAttribute("12345678901234567890"),
+		"enabled": events.NewBooleanAttribute(true),
+		"tags":    events.NewStringSetAttribute([]string{"a", "b"}),
+		"config": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+			"ports": events.NewListAttribute([]events.DynamoDBAttributeValue{events.NewNumberAttribute("8080"), events.NewNullAttribute()}),
+		}),
+	}
+	record := &events.DynamoDBEventRecord{EventID: "event-1", EventName: eventName}
+	record.Change.Keys = map[string]events.DynamoDBAttributeValue{"id": item["id"]}
+	if eventName == EventNameRemove {
+		record.Change.OldImage = item
+	} else {
+		record.Change.NewImage = item
+	}
+	return record
+}
+
+var ecsNotifyTests = map[string]struct {
+	mapping     StreamMapping
+	record      *events.DynamoDBEventRecord
+	wantCluster string
+	wantPayload string
+	wantSkipped bool
+	wantErr     bool
+}{
+	"insert with all attributes": {
+		StreamMapping{ClusterAttribute: "cluster"}, streamRecord(EventNameInsert), "cluster-a",
+		`{"cluster":"cluster-a","config":{"ports":[8080,null]},"enabled":true,"id":"item-1","tags":["a","b"],"version":12345678901234567890}`, false, false,
+	},
+	"modify with payload attributes": {
+		StreamMapping{ClusterAttribute: "cluster", PayloadAttributes: []string{"id", "version", "missing"}}, streamRecord(EventNameModify), "cluster-a",
+		`{"id":"item-1","version":12345678901234567890}`, false, false,
+	},
+	"remove maps old image": {
+		StreamMapping{ClusterAttribute: "cluster", PayloadAttributes: []string{"id"}}, streamRecord(EventNameRemove), "cluster-a",
+		`{"id":"item-1"}`, false, false,
+	},
+	"event name not notified": {
+		StreamMapping{ClusterAttribute: "cluster", EventNames: []string{EventNameInsert}}, streamRecord(EventNameRemove), "", "", true, false,
+	},
+	"missing cluster attribute": {
+		StreamMapping{ClusterAttribute: "ecs_cluster"}, streamRecord(EventNameInsert), "", "", false, true,
+	},
+	"cluster attribute not a string": {
+		StreamMapping{ClusterAttribute: "enabled"}, streamRecord(EventNameInsert), "", "", false, true,
+	},
+}
+
+func TestEcsNotify(t *testing.T) {
+	for name, tc := range ecsNotifyTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := tc.mapping.EcsNotify(tc.record)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("EcsNotify() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if !failure.IsPermanent(err) {
+					t.Errorf("EcsNotify() error = %v, want permanent error", err)
+				}
+				return
+			}
+			if (actual == nil) != tc.wantSkipped {
+				t.Fatalf("EcsNotify() = %v, want skipped %v", actual, tc.wantSkipped)
+			}
+			if tc.wantSkipped {
+				return
+			}
+			if actual.NotificationId != "event-1" || actual.Cluster != tc.wantCluster || string(actual.Payload) != tc.wantPayload {
+				t.Errorf("EcsNotify() = %s %s %s, want event-1 %s %s", actual.NotificationId, actual.Cluster, actual.Payload, tc.wantCluster, tc.wantPayload)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package internal
+
+import "encoding/json"
+
+// Observer message of the ECS Service Discovery Lambda
+type EcsNotify struct {
+	NotificationId string          `json:"notification_id,omitempty"`
+	Cluster        string          `json:"cluster,omitempty"`
+	Clusters       []string        `json:"clusters,omitempty"`
+	Topic          string          `json:"topic,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+}
+
+func NewEcsNotify() *EcsNotify {
+	return &EcsNotify{}
+}
+
+// Payload of notifications of S3 object events
+type S3ObjectEvent struct {
+	EventName string `json:"event_name"`
+	EventTime string `json:"event_time"`
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	VersionId string `json:"version_id,omitempty"`
+	ETag      string `json:"etag,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/assumerole"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/cache"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// AWS clients of a workload account role in a region, calling with credentials of
+// the assumed role. Clients of the notifier account without role
+type RoleClients struct {
+	ECSClient ECSClient
+	SQSClient SQSClient
+}
+
+// Build AWS clients of the workload account role in the region, the default region
+// of the config without region. Fakes are injected in tests
+type RoleClientsFactory func(roleArn string, region string) *RoleClients
+
+// Clients by role ARN and region, kept across warm invocations
+var roleClientsCache = cache.New[assumerole.Key, *RoleClients](0)
+
+// Role clients factory assuming roles with the STS client of the config,
+// clients are built once per role and region
+func newRoleClientsFactory(cfg aws.Config) RoleClientsFactory {
+	return assumerole.NewFactory(cfg, roleClientsCache, func(roleCfg aws.Config) *RoleClients {
+		return &RoleClients{ECSClient: ecs.NewFromConfig(roleCfg), SQSClient: sqs.NewFromConfig(roleCfg)}
+	})
+}
+
+// Factory of role and region clients, cross-account and cross-region notifications fail without factory
+func (awsService *AWSService) WithRoleClients(roleClients RoleClientsFactory) *AWSService {
+	awsService.roleClients = roleClients
+	return awsService
+}
+
+// Workload account role ARNs by cluster, used unless the notification carries a role ARN
+func (awsService *AWSService) WithClusterRoles(clusterRoles map[string]string) *AWSService {
+	awsService.clusterRoles = clusterRoles
+	return awsService
+}
+
+// Role ARN to discover services of the cluster with, role ARN of the notification
+// takes precedence over the configured role of the cluster. Empty without role
+func (awsService *AWSService) ClusterRoleArn(cluster string, roleArn string) string {
+	if roleArn != "" {
+		return roleArn
+	}
+	return awsService.clusterRoles[cluster]
+}
+
+// AWS Service calling ECS with credentials of the assumed workload account role,
+// the service itself without role. SQS and DynamoDB clients stay in the notifier account.
+// Invalid role ARNs fail every retry, reported as permanent error
+func (awsService *AWSService) ForRole(roleArn string) (*AWSService, error) {
+	if roleArn == "" {
+		return awsService, nil
+	}
+	if err := assumerole.ValidateRoleArn(roleArn); err != nil {
+		return nil, failure.Permanent(err)
+	}
+	if awsService.roleClients == nil {
+		return nil, failure.Permanent(fmt.Errorf("cross-account role %s not supported", roleArn))
+	}
+
+	// ECS of the region of the service e.g. a region of the notification
+	roleService := *awsService
+	roleService.roleArn = roleArn
+	roleService.ecsClient = awsService.roleClients(roleArn, awsService.region).ECSClient
+	return &roleService, nil
+}
+
+// Parse comma separated cluster role ARNs e.g. cluster-a=arn:aws:iam::123456789012:role/name
+func ParseClusterRoles(value string) (map[string]string, error) {
+	clusterRoles := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		cluster, roleArn, ok := strings.Cut(item, "=")
+		cluster, roleArn = strings.TrimSpace(cluster), strings.TrimSpace(roleArn)
+		if !ok || cluster == "" {
+			return nil, fmt.Errorf("invalid cluster role %q, expected cluster=role-arn", item)
+		}
+		if err := assumerole.ValidateRoleArn(roleArn); err != nil {
+			return nil, err
+		}
+		clusterRoles[cluster] = roleArn
+	}
+	return clusterRoles, nil
+}
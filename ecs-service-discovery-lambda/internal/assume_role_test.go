@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+const workloadRoleArn = "arn:aws:iam::210987654321:role/ECSTaskNotifierWorkloadRole"
+
+var forRoleTests = map[string]struct {
+	roleArn      string
+	noFactory    bool
+	wantWorkload bool
+	wantErr      bool
+}{
+	"no role":         {"", false, false, false},
+	"workload role":   {workloadRoleArn, false, true, false},
+	"invalid arn":     {"ECSTaskNotifierWorkloadRole", false, false, true},
+	"not a role":      {"arn:aws:iam::210987654321:user/notifier", false, false, true},
+	"without factory": {workloadRoleArn, true, false, true},
+}
+
+func TestForRole(t *testing.T) {
+	for name, tc := range forRoleTests {
+		t.Run(name, func(t *testing.T) {
+			awsService, _, _ := newFakeAWSService()
+			workloadECS := fake.NewECS()
+			workloadECS.AddService("workload-cluster", "workload-service", notifyLabels)
+			if !tc.noFactory {
+				awsService = awsService.WithRoleClients(func(roleArn string, region string) *RoleClients {
+					return &RoleClients{ECSClient: workloadECS}
+				})
+			}
+
+			roleService, err := awsService.ForRole(tc.roleArn)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ForRole() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if !failure.IsPermanent(err) {
+					t.Errorf("ForRole() error = %v, want permanent error", err)
+				}
+				return
+			}
+
+			services, err := roleService.ListECSServices(context.TODO(), "workload-cluster")
+			if tc.wantWorkload != (err == nil && len(services) == 1) {
+				t.Errorf("ListECSServices() = %v, %v, want workload services %v", services, err, tc.wantWorkload)
+			}
+		})
+	}
+}
+
+func TestClusterRoleArn(t *testing.T) {
+	awsService, _, _ := newFakeAWSService()
+	awsService = awsService.WithClusterRoles(map[string]string{"workload-cluster": workloadRoleArn})
+
+	if actual := awsService.ClusterRoleArn("workload-cluster", ""); actual != workloadRoleArn {
+		t.Errorf("ClusterRoleArn() = %q, want configured role", actual)
+	}
+	otherRoleArn := "arn:aws:iam::111122223333:role/other"
+	if actual := awsService.ClusterRoleArn("workload-cluster", otherRoleArn); actual != otherRoleArn {
+		t.Errorf("ClusterRoleArn() = %q, want notification role", actual)
+	}
+	if actual := awsService.ClusterRoleArn("central-cluster", ""); actual != "" {
+		t.Errorf("ClusterRoleArn() = %q, want none", actual)
+	}
+}
+
+var parseClusterRolesTests = map[string]struct {
+	value   string
+	want    map[string]string
+	wantErr bool
+}{
+	"empty":        {"", map[string]string{}, false},
+	"single":       {"cluster-a=" + workloadRoleArn, map[string]string{"cluster-a": workloadRoleArn}, false},
+	"with spaces":  {" cluster-a = " + workloadRoleArn + " , ", map[string]string{"cluster-a": workloadRoleArn}, false},
+	"missing role": {"cluster-a", nil, true},
+	"missing name": {"=" + workloadRoleArn, nil, true},
+	"invalid role": {"cluster-a=role/name", nil, true},
+	"multiple": {
+		"cluster-a=" + workloadRoleArn + ",cluster-b=arn:aws:iam::111122223333:role/name",
+		map[string]string{"cluster-a": workloadRoleArn, "cluster-b": "arn:aws:iam::111122223333:role/name"}, false,
+	},
+}
+
+func TestParseClusterRoles(t *testing.T) {
+	for name, tc := range parseClusterRolesTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseClusterRoles(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseClusterRoles() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(actual, tc.want) {
+				t.Errorf("ParseClusterRoles() = %v, want %v", actual, tc.want)
+			}
+		})
+	}
+}
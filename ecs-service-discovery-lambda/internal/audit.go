@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const (
+	// Notification stage audited by this Lambda
+	AuditStageServiceDiscovery = "SERVICE_DISCOVERY"
+
+	// Audit records expire from the DynamoDB table after retention period
+	auditRecordRetention = 30 * 24 * time.Hour
+)
+
+// Audit record of the ECS service discovery stage of a notification
+type AuditRecord struct {
+	NotificationId string   `dynamodbav:"notification_id"`
+	AuditKey       string   `dynamodbav:"audit_key"`
+	Stage          string   `dynamodbav:"stage"`
+	RequestId      string   `dynamodbav:"request_id"`
+	Cluster        string   `dynamodbav:"cluster"`
+	Clusters       []string `dynamodbav:"clusters,omitempty,stringset"`
+	RoleArn        string   `dynamodbav:"role_arn,omitempty"`
+	Regions        []string `dynamodbav:"regions,omitempty,stringset"`
+	Topic          string   `dynamodbav:"topic,omitempty"`
+	Services       []string `dynamodbav:"services,omitempty,stringset"`
+	ServiceCount   int      `dynamodbav:"service_count"`
+	RequeueCount   int      `dynamodbav:"requeue_count,omitempty"`
+	Error          string   `dynamodbav:"error,omitempty"`
+	Permanent      bool     `dynamodbav:"permanent,omitempty"`
+	CreatedAt      string   `dynamodbav:"created_at"`
+	ExpiresAt      int64    `dynamodbav:"expires_at"`
+}
+
+func NewAuditRecord(notificationId string, cluster string) *AuditRecord {
+	now := time.Now().UTC()
+	return &AuditRecord{
+		NotificationId: notificationId,
+		AuditKey:       AuditStageServiceDiscovery,
+		Stage:          AuditStageServiceDiscovery,
+		Cluster:        cluster,
+		CreatedAt:      now.Format(time.RFC3339Nano),
+		ExpiresAt:      now.Add(auditRecordRetention).Unix(),
+	}
+}
+
+// Record re-enqueues of the notification, audited apart from the first attempt
+// of the notification e.g. SERVICE_DISCOVERY#1 for the first re-enqueue
+func (auditRecord *AuditRecord) WithRequeueCount(requeueCount int) *AuditRecord {
+	auditRecord.RequeueCount = requeueCount
+	auditRecord.AuditKey = AuditStageServiceDiscovery
+	if requeueCount > 0 {
+		auditRecord.AuditKey += "#" + strconv.Itoa(requeueCount)
+	}
+	return auditRecord
+}
+
+// Record ECS clusters targeted by the notification
+func (auditRecord *AuditRecord) WithClusters(clusters []string) *AuditRecord {
+	auditRecord.Clusters = nil
+	for _, cluster := range clusters {
+		// Clusters of the same name in several regions, string sets hold distinct values
+		if cluster != "" && !slices.Contains(auditRecord.Clusters, cluster) {
+			auditRecord.Clusters = append(auditRecord.Clusters, cluster)
+		}
+	}
+	return auditRecord
+}
+
+// Record discovered ECS services subscribed to the notification
+func (auditRecord *AuditRecord) WithServices(serviceMessages []*ServiceMessage) *AuditRecord {
+	auditRecord.Services = nil
+	for _, serviceMessage := range serviceMessages {
+		// Services of the same name in several clusters, string sets hold distinct values
+		if !slices.Contains(auditRecord.Services, serviceMessage.Service) {
+			auditRecord.Services = append(auditRecord.Services, serviceMessage.Service)
+		}
+	}
+	auditRecord.ServiceCount = len(serviceMessages)
+	return auditRecord
+}
+
+// Audit table name, auditing is disabled without table name
+func (awsService *AWSService) WithAuditTable(tableName string) *AWSService {
+	awsService.auditTableName = tableName
+	return awsService
+}
+
+// Persist audit record to DynamoDB, failure to audit does not fail the notification
+func (awsService *AWSService) PutAuditRecord(ctx context.Context, auditRecord *AuditRecord) {
+	if awsService.auditTableName == "" {
+		return
+	}
+
+	requestId := RequestIdFromContext(ctx)
+	auditRecord.RequestId = requestId
+
+	item, err := attributevalue.MarshalMap(auditRecord)
+	if err != nil {
+		slog.Error("failed to marshal audit record", "requestId", requestId, "errorMessage", err)
+		return
+	}
+
+	_, err = awsService.dynamodbClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(awsService.auditTableName),
+		Item:      item,
+	})
+	if err != nil {
+		slog.Error("failed to put audit record", "requestId", requestId, "notificationId", auditRecord.NotificationId, "errorMessage", err)
+	}
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/dynamodblocal"
+)
+
+func TestAuditRecordWithServices(t *testing.T) {
+	auditRecord := NewAuditRecord("notification-1", "ecs_cluster_name").
+		WithServices([]*ServiceMessage{{Service: "svc-a"}, {Service: "svc-b"}})
+
+	if !reflect.DeepEqual(auditRecord.Services, []string{"svc-a", "svc-b"}) || auditRecord.ServiceCount != 2 {
+		t.Errorf("services = %v, count = %d", auditRecord.Services, auditRecord.ServiceCount)
+	}
+	if auditRecord.AuditKey != AuditStageServiceDiscovery || auditRecord.ExpiresAt == 0 {
+		t.Errorf("unexpected audit record %+v", auditRecord)
+	}
+}
+
+func TestAuditRecordWithClusters(t *testing.T) {
+	auditRecord := NewAuditRecord("notification-1", "*").
+		WithClusters([]string{"cluster-a", "cluster-b"}).
+		WithServices([]*ServiceMessage{{Cluster: "cluster-a", Service: "svc-a"}, {Cluster: "cluster-b", Service: "svc-a"}})
+
+	if !reflect.DeepEqual(auditRecord.Clusters, []string{"cluster-a", "cluster-b"}) {
+		t.Errorf("clusters = %v", auditRecord.Clusters)
+	}
+	// String sets hold distinct services, the count covers every cluster
+	if !reflect.DeepEqual(auditRecord.Services, []string{"svc-a"}) || auditRecord.ServiceCount != 2 {
+		t.Errorf("services = %v, count = %d", auditRecord.Services, auditRecord.ServiceCount)
+	}
+}
+
+func TestAuditRecordWithRequeueCount(t *testing.T) {
+	if auditKey := NewAuditRecord("notification-1", "*").WithRequeueCount(0).AuditKey; auditKey != AuditStageServiceDiscovery {
+		t.Errorf("AuditKey = %s, want %s", auditKey, AuditStageServiceDiscovery)
+	}
+	// Re-enqueued notifications are audited next to the original discovery
+	auditRecord := NewAuditRecord("notification-1", "*").WithRequeueCount(2)
+	if auditRecord.AuditKey != AuditStageServiceDiscovery+"#2" || auditRecord.RequeueCount != 2 {
+		t.Errorf("AuditKey = %s, RequeueCount = %d", auditRecord.AuditKey, auditRecord.RequeueCount)
+	}
+}
+
+var putAuditRecordTests = map[string]struct {
+	tableName string
+	putErr    error
+	wantItems int
+}{
+	"audit disabled":        {"", nil, 0},
+	"audit record put":      {"audit-table", nil, 1},
+	"put failure tolerated": {"audit-table", errors.New("ProvisionedThroughputExceeded"), 0},
+}
+
+func TestPutAuditRecord(t *testing.T) {
+	for name, tc := range putAuditRecordTests {
+		t.Run(name, func(t *testing.T) {
+			dynamodbClient := fake.NewDynamoDB()
+			dynamodbClient.Err = tc.putErr
+			awsService := NewAWSService(fake.NewECS(), fake.NewSQS(), dynamodbClient).WithAuditTable(tc.tableName)
+
+			awsService.PutAuditRecord(context.TODO(), NewAuditRecord("notification-1", "ecs_cluster_name"))
+			if len(dynamodbClient.Items) != tc.wantItems {
+				t.Errorf("items = %d, want %d", len(dynamodbClient.Items), tc.wantItems)
+			}
+		})
+	}
+}
+
+// Runs against DynamoDB Local, see package dynamodblocal
+func TestPutAuditRecordDynamoDBLocal(t *testing.T) {
+	ctx := context.TODO()
+	dynamodbClient := dynamodblocal.NewAuditTable(t)
+	awsService := NewAWSService(nil, nil, dynamodbClient).WithAuditTable(dynamodblocal.AuditTableName)
+
+	auditRecord := NewAuditRecord("notification-1", "ecs_cluster_name").
+		WithServices([]*ServiceMessage{{Service: "svc-a"}})
+	awsService.PutAuditRecord(ctx, auditRecord)
+
+	var actual AuditRecord
+	dynamodblocal.GetAuditRecord(t, dynamodbClient, "notification-1", AuditStageServiceDiscovery, &actual)
+	if actual.Cluster != "ecs_cluster_name" || actual.ServiceCount != 1 {
+		t.Errorf("unexpected audit record %+v", actual)
+	}
+}
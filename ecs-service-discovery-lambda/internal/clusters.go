@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/describe"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// Maximum clusters of a DescribeClusters call
+const describeClustersBatchSize = 100
+
+// Cluster names or globs targeted by the notification, e.g. "*" broadcasts to all clusters
+func (ecsNotify *EcsNotify) clusterPatterns() []string {
+	var patterns []string
+	for _, cluster := range append([]string{ecsNotify.Cluster}, ecsNotify.Clusters...) {
+		if cluster = strings.TrimSpace(cluster); cluster != "" && !slices.Contains(patterns, cluster) {
+			patterns = append(patterns, cluster)
+		}
+	}
+	// Cluster tags alone select among all clusters
+	if len(patterns) == 0 && len(ecsNotify.ClusterTags) > 0 {
+		patterns = append(patterns, "*")
+	}
+	return patterns
+}
+
+// Check whether the cluster is a glob matched against listed clusters
+func isClusterPattern(cluster string) bool {
+	return strings.ContainsAny(cluster, `*?[\`)
+}
+
+// Resolve the ECS clusters targeted by the notification. Cluster names without
+// cluster tags are used as given, like single cluster notifications. Globs e.g. "*"
+// or "team-*" and cluster tags are matched against clusters of ListClusters.
+// Invalid globs fail every retry, reported as permanent error
+func (awsService *AWSService) ResolveECSClusters(ctx context.Context, ecsNotify *EcsNotify) ([]string, error) {
+	requestId := RequestIdFromContext(ctx)
+
+	patterns := ecsNotify.clusterPatterns()
+	if len(patterns) == 0 {
+		// Single cluster format, the cluster is validated by ECS
+		return []string{ecsNotify.Cluster}, nil
+	}
+
+	listClusters := len(ecsNotify.ClusterTags) > 0
+	for _, pattern := range patterns {
+		if !isClusterPattern(pattern) {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, failure.Permanent(fmt.Errorf("invalid cluster pattern %q: %w", pattern, err))
+		}
+		listClusters = true
+	}
+	if !listClusters {
+		return patterns, nil
+	}
+
+	clusterTags, err := awsService.listECSClusters(ctx, len(ecsNotify.ClusterTags) > 0)
+	if err != nil {
+		slog.Error("Failed to list ECS clusters", "requestId", requestId, "errorMessage", err)
+		return nil, err
+	}
+
+	var clusters []string
+	for _, cluster := range clusterTags.names {
+		if !tagsMatch(ecsNotify.ClusterTags, clusterTags.tags[cluster]) {
+			continue
+		}
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, cluster); matched {
+				clusters = append(clusters, cluster)
+				break
+			}
+		}
+	}
+	return clusters, nil
+}
+
+// ECS cluster names in listing order with tags of each cluster
+type ecsClusters struct {
+	names []string
+	tags  map[string]map[string]string
+}
+
+// List all the ECS clusters of the account and region, with tags when asked for
+func (awsService *AWSService) listECSClusters(ctx context.Context, withTags bool) (*ecsClusters, error) {
+	var clusterArns []string
+	paginator := ecs.NewListClustersPaginator(awsService.ecsClient, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		clusterArns = append(clusterArns, page.ClusterArns...)
+	}
+
+	clusters := &ecsClusters{tags: make(map[string]map[string]string)}
+	if !withTags {
+		// Cluster ARN e.g. arn:aws:ecs:us-east-1:123456789012:cluster/cluster-name
+		for _, clusterArn := range clusterArns {
+			clusters.names = append(clusters.names, clusterArn[strings.LastIndex(clusterArn, "/")+1:])
+		}
+		return clusters, nil
+	}
+
+	// Describe clusters for their tags, DescribeClusters accepts up to 100 clusters
+	describedClusters, err := describe.InChunks(ctx, clusterArns, describeClustersBatchSize,
+		func(ctx context.Context, chunk []string) ([]types.Cluster, error) {
+			respClusters, err := awsService.ecsClient.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+				Clusters: chunk,
+				Include:  []types.ClusterField{types.ClusterFieldTags},
+			})
+			if err != nil {
+				return nil, err
+			}
+			return respClusters.Clusters, describe.FailuresErr(RequestIdFromContext(ctx), "DescribeClusters", respClusters.Failures)
+		})
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range describedClusters {
+		name := aws.ToString(cluster.ClusterName)
+		tags := make(map[string]string)
+		for _, tag := range cluster.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		clusters.names = append(clusters.names, name)
+		clusters.tags[name] = tags
+	}
+	return clusters, nil
+}
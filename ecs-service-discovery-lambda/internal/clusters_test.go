@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+var resolveClustersTests = map[string]struct {
+	ecsNotify        EcsNotify
+	wantClusters     []string
+	wantListClusters bool
+	wantErr          bool
+}{
+	"single cluster":         {EcsNotify{Cluster: "team-a"}, []string{"team-a"}, false, false},
+	"unknown single cluster": {EcsNotify{Cluster: "missing"}, []string{"missing"}, false, false},
+	"list of clusters": {
+		EcsNotify{Cluster: "team-a", Clusters: []string{"platform", "team-a"}},
+		[]string{"team-a", "platform"}, false, false,
+	},
+	"all clusters":    {EcsNotify{Cluster: "*"}, []string{"team-a", "team-b", "platform"}, true, false},
+	"cluster glob":    {EcsNotify{Clusters: []string{"team-*"}}, []string{"team-a", "team-b"}, true, false},
+	"glob and name":   {EcsNotify{Clusters: []string{"platform", "team-?"}}, []string{"team-a", "team-b", "platform"}, true, false},
+	"cluster tags":    {EcsNotify{Cluster: "*", ClusterTags: map[string]string{"env": "prod"}}, []string{"team-a", "platform"}, true, false},
+	"tags only":       {EcsNotify{ClusterTags: map[string]string{"env": "prod", "owner": ""}}, []string{"platform"}, true, false},
+	"tags and name":   {EcsNotify{Cluster: "team-b", ClusterTags: map[string]string{"env": "prod"}}, nil, true, false},
+	"invalid pattern": {EcsNotify{Cluster: "team-["}, nil, false, true},
+}
+
+func TestResolveECSClusters(t *testing.T) {
+	for name, tc := range resolveClustersTests {
+		t.Run(name, func(t *testing.T) {
+			awsService, ecsClient, _ := newFakeAWSService()
+			ecsClient.AddCluster("team-a", map[string]string{"env": "prod"})
+			ecsClient.AddCluster("team-b", map[string]string{"env": "dev"})
+			ecsClient.AddCluster("platform", map[string]string{"env": "prod", "owner": "sre"})
+
+			actual, err := awsService.ResolveECSClusters(context.TODO(), &tc.ecsNotify)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ResolveECSClusters() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr && !failure.IsPermanent(err) {
+				t.Errorf("ResolveECSClusters() error = %v, want permanent error", err)
+			}
+			if !reflect.DeepEqual(actual, tc.wantClusters) {
+				t.Errorf("ResolveECSClusters() = %v, want %v", actual, tc.wantClusters)
+			}
+			if listed := ecsClient.Calls["ListClusters"] > 0; listed != tc.wantListClusters {
+				t.Errorf("ListClusters called = %v, want %v", listed, tc.wantListClusters)
+			}
+			// Clusters are described for tags only
+			if described := ecsClient.Calls["DescribeClusters"] > 0; described != (len(tc.ecsNotify.ClusterTags) > 0) {
+				t.Errorf("DescribeClusters called = %v", described)
+			}
+		})
+	}
+}
+
+func TestResolveECSClustersPages(t *testing.T) {
+	awsService, ecsClient, _ := newFakeAWSService()
+	ecsClient.PageSize = 2
+	for i := range 150 {
+		ecsClient.AddCluster(fmt.Sprintf("cluster-%03d", i), map[string]string{"env": "prod"})
+	}
+
+	actual, err := awsService.ResolveECSClusters(context.TODO(), &EcsNotify{ClusterTags: map[string]string{"env": "prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 150 || actual[0] != "cluster-000" || actual[149] != "cluster-149" {
+		t.Errorf("ResolveECSClusters() = %d clusters, want 150", len(actual))
+	}
+	if ecsClient.Calls["ListClusters"] != 75 || ecsClient.Calls["DescribeClusters"] != 2 {
+		t.Errorf("calls = %v, want 75 ListClusters and 2 DescribeClusters", ecsClient.Calls)
+	}
+}
+
+func TestResolveECSClustersListError(t *testing.T) {
+	awsService, ecsClient, _ := newFakeAWSService()
+	listErr := errors.New("ServiceUnavailable")
+	ecsClient.Errors["ListClusters"] = listErr
+
+	if _, err := awsService.ResolveECSClusters(context.TODO(), &EcsNotify{Cluster: "*"}); !errors.Is(err, listErr) {
+		t.Errorf("ResolveECSClusters() error = %v, want %v", err, listErr)
+	}
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"net/http"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/cache"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/describe"
 )
 
 // Get AWSRequestId from Lambda Context Object
@@ -23,45 +28,75 @@ func RequestIdFromContext(ctx context.Context) string {
 }
 
 type AWSService struct {
-	ecsClient *ecs.Client
-	sqsClient *sqs.Client
+	ecsClient      ECSClient
+	sqsClient      SQSClient
+	dynamodbClient DynamoDBClient
+	auditTableName string
+	roleClients    RoleClientsFactory
+	clusterRoles   map[string]string
+	roleArn        string
+	region         string
 }
 
-func NewAWSService(ctx context.Context) (*AWSService, error) {
-	requestId := RequestIdFromContext(ctx)
+// Build AWS Service on top of given clients, fakes are injected in tests
+func NewAWSService(ecsClient ECSClient, sqsClient SQSClient, dynamodbClient DynamoDBClient) *AWSService {
 	awsService := &AWSService{}
 
+	return awsService.withEcsClient(ecsClient).
+		withSQSClient(sqsClient).
+		withDynamoDBClient(dynamodbClient)
+}
+
+// Build AWS Service with clients of AWS default config
+func NewAWSServiceFromConfig(ctx context.Context) (*AWSService, error) {
+	requestId := RequestIdFromContext(ctx)
+
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		slog.Error("Failed to load default config", "requestId", requestId, "errorMessage", err)
 		return nil, err
 	}
 
-	awsService = awsService.withEcsClient(cfg).
-		withSQSClient(cfg)
-
-	return awsService, nil
+	return NewAWSService(ecs.NewFromConfig(cfg), sqs.NewFromConfig(cfg), dynamodb.NewFromConfig(cfg)).
+		withRegion(cfg.Region).
+		WithRoleClients(newRoleClientsFactory(cfg)), nil
 }
 
-func (awsService *AWSService) withEcsClient(cfg aws.Config) *AWSService {
-	ecsClient := ecs.NewFromConfig(cfg)
+func (awsService *AWSService) withEcsClient(ecsClient ECSClient) *AWSService {
 	awsService.ecsClient = ecsClient
 	return awsService
 }
 
-func (awsService *AWSService) withSQSClient(cfg aws.Config) *AWSService {
-	sqsClient := sqs.NewFromConfig(cfg)
+func (awsService *AWSService) withSQSClient(sqsClient SQSClient) *AWSService {
 	awsService.sqsClient = sqsClient
 	return awsService
 }
 
+// SQS client of the service, e.g. to route messages to the dead letter queue
+func (awsService *AWSService) SQSClient() SQSClient {
+	return awsService.sqsClient
+}
+
+func (awsService *AWSService) withRegion(region string) *AWSService {
+	awsService.region = region
+	return awsService
+}
+
+func (awsService *AWSService) withDynamoDBClient(dynamodbClient DynamoDBClient) *AWSService {
+	awsService.dynamodbClient = dynamodbClient
+	return awsService
+}
+
+// Maximum services of a DescribeServices call
+const describeServicesBatchSize = 10
+
 // List All the ECS Services running within ECS Cluster
 func (awsService *AWSService) ListECSServices(ctx context.Context, cluster string) ([]*EcsService, error) {
 	requestId := RequestIdFromContext(ctx)
 
 	// Initialize variables for pagination
 	var nextToken *string
-	var allServices []types.Service
+	var serviceArns []string
 
 	// Paginate through ECS cluster services
 	for {
@@ -74,19 +109,7 @@ func (awsService *AWSService) ListECSServices(ctx context.Context, cluster strin
 			slog.Error("Failed to list ECS cluster services", "requestId", requestId, "errorMessage", errListSvcs)
 			return nil, errListSvcs
 		}
-
-		// Describe services for the cluster with pagination token
-		respServices, errServices := awsService.ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
-			Services: respListSvcs.ServiceArns,
-			Cluster:  aws.String(cluster),
-		})
-		if errServices != nil {
-			slog.Error("Failed to describe ECS cluster services", "requestId", requestId, "errorMessage", errServices)
-			return nil, errServices
-		}
-
-		// Append services to the list
-		allServices = append(allServices, respServices.Services...)
+		serviceArns = append(serviceArns, respListSvcs.ServiceArns...)
 
 		// Check if there are more services to fetch
 		if respListSvcs.NextToken == nil {
@@ -96,35 +119,92 @@ func (awsService *AWSService) ListECSServices(ctx context.Context, cluster strin
 		nextToken = respListSvcs.NextToken
 	}
 
+	// Describe services of the cluster, DescribeServices accepts up to 10 services
+	allServices, errServices := describe.InChunks(ctx, serviceArns, describeServicesBatchSize,
+		func(ctx context.Context, chunk []string) ([]types.Service, error) {
+			respServices, err := awsService.ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+				Services: chunk,
+				Cluster:  aws.String(cluster),
+				// Tags of services are matched by service selectors
+				Include: []types.ServiceField{types.ServiceFieldTags},
+			})
+			if err != nil {
+				return nil, err
+			}
+			return respServices.Services, describe.FailuresErr(RequestIdFromContext(ctx), "DescribeServices", respServices.Failures)
+		})
+	if errServices != nil {
+		slog.Error("Failed to describe ECS cluster services", "requestId", requestId, "errorMessage", errServices)
+		return nil, errServices
+	}
+
 	var ecsServices []*EcsService
 	for _, service := range allServices {
+		tags := make(map[string]string)
+		for _, tag := range service.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
 		ecsServices = append(ecsServices, &EcsService{
-			Cluster:        cluster,
-			Service:        aws.ToString(service.ServiceName),
-			TaskDefinition: aws.ToString(service.TaskDefinition),
+			Cluster:              cluster,
+			Service:              aws.ToString(service.ServiceName),
+			TaskDefinition:       aws.ToString(service.TaskDefinition),
+			TaskDefinitionFamily: taskDefinitionFamily(aws.ToString(service.TaskDefinition)),
+			Tags:                 tags,
 		})
 	}
 
 	return ecsServices, nil
 }
 
-// Filter ECS Services latest TaskDefinition matching required dockerlabels
-func (awsService *AWSService) FilterECSServices(ctx context.Context, services []*EcsService) ([]*ServiceMessage, error) {
+// Task definitions are immutable per revision, cached without expiry by task definition ARN
+var taskDefinitionCache = cache.New[string, *types.TaskDefinition](0)
+
+// Task definition cache hits and misses since the last call
+func TaskDefinitionCacheStats() cache.Stats {
+	return taskDefinitionCache.TakeStats()
+}
+
+// Describe task definition, cached when referenced by its ARN including revision
+func (awsService *AWSService) describeTaskDefinition(ctx context.Context, taskDefinitionArn string) (*types.TaskDefinition, error) {
+	if taskDefinition, ok := taskDefinitionCache.Get(taskDefinitionArn); ok {
+		return taskDefinition, nil
+	}
+
+	output, err := awsService.ecsClient.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(taskDefinitionArn),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// A family reference resolves to the latest revision, which changes over time
+	if aws.ToString(output.TaskDefinition.TaskDefinitionArn) == taskDefinitionArn {
+		taskDefinitionCache.Put(taskDefinitionArn, output.TaskDefinition)
+	}
+	return output.TaskDefinition, nil
+}
+
+// Filter ECS Services latest TaskDefinition matching required dockerlabels,
+// and subscribed to the notification topic when given
+func (awsService *AWSService) FilterECSServices(ctx context.Context, services []*EcsService, topic string) ([]*ServiceMessage, error) {
 	requestId := RequestIdFromContext(ctx)
 
 	var filteredServices []*ServiceMessage
 	for _, service := range services {
-		taskDefinition, err := awsService.ecsClient.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
-			TaskDefinition: aws.String(service.TaskDefinition),
-		})
+		taskDefinition, err := awsService.describeTaskDefinition(ctx, service.TaskDefinition)
 		if err != nil {
 			slog.Error("Failed to describe task definition", "requestId", requestId, "errorMessage", err)
 			return nil, err
 		}
 
-		for _, containerDefinition := range taskDefinition.TaskDefinition.ContainerDefinitions {
+		for _, containerDefinition := range taskDefinition.ContainerDefinitions {
 			// NOTIFY_ME_CONTAINER_PORT = 8080
 			// NOTIFY_ME_API_URI = /v1.0/notify
+			// NOTIFY_ME_HTTP_METHOD = POST (optional)
+			// NOTIFY_ME_PROTOCOL = https (optional)
+			// NOTIFY_ME_TLS_SERVER_NAME = notify.service.internal (optional)
+			// NOTIFY_ME_AUTH_AUDIENCE = https://notify.service.internal (optional)
+			// NOTIFY_ME_AUTH_SCOPE = notify:write (optional)
+			// NOTIFY_ME_TOPICS = config.reload,cache.* (optional)
 
 			dockerLabels := containerDefinition.DockerLabels
 			nmcPort, nmcPortOk := dockerLabels["NOTIFY_ME_CONTAINER_PORT"]
@@ -132,11 +212,29 @@ func (awsService *AWSService) FilterECSServices(ctx context.Context, services []
 
 			// Check if Docker Label Exisits for above two keys
 			if nmcPortOk && nmApiUriOk {
+				// Another container of the task may subscribe to the topic
+				if !topicMatches(notifyMeTopics(dockerLabels), topic) {
+					continue
+				}
+
+				nmProtocol, nmProtocolOk := notifyMeProtocol(dockerLabels)
+				if !nmProtocolOk {
+					slog.Warn("Unsupported notify protocol, service skipped", "requestId", requestId,
+						"service", service.Service, "protocol", dockerLabels["NOTIFY_ME_PROTOCOL"])
+					break
+				}
+
 				ecsService := NewServiceMessage()
 				ecsService.Cluster = service.Cluster
 				ecsService.Service = service.Service
 				ecsService.NotifyMeContainerPort = nmcPort
 				ecsService.NotifyMeAPIUri = nmApiUri
+				ecsService.NotifyMeHTTPMethod = notifyMeHTTPMethod(dockerLabels)
+				ecsService.NotifyMeProtocol = nmProtocol
+				ecsService.NotifyMeTLSServerName = strings.TrimSpace(dockerLabels["NOTIFY_ME_TLS_SERVER_NAME"])
+				ecsService.NotifyMeAuthAudience = strings.TrimSpace(dockerLabels["NOTIFY_ME_AUTH_AUDIENCE"])
+				ecsService.NotifyMeAuthScope = strings.TrimSpace(dockerLabels["NOTIFY_ME_AUTH_SCOPE"])
+				ecsService.Topic = topic
 
 				filteredServices = append(filteredServices, ecsService)
 				break // found the match
@@ -147,6 +245,27 @@ func (awsService *AWSService) FilterECSServices(ctx context.Context, services []
 	return filteredServices, nil
 }
 
+// HTTP method used to call Notify API, defaults to POST when docker label is missing
+func notifyMeHTTPMethod(dockerLabels map[string]string) string {
+	nmHttpMethod, ok := dockerLabels["NOTIFY_ME_HTTP_METHOD"]
+	if !ok || strings.TrimSpace(nmHttpMethod) == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(strings.TrimSpace(nmHttpMethod))
+}
+
+// Protocol used to call Notify API, defaults to http when docker label is missing
+func notifyMeProtocol(dockerLabels map[string]string) (string, bool) {
+	nmProtocol := strings.ToLower(strings.TrimSpace(dockerLabels["NOTIFY_ME_PROTOCOL"]))
+	switch nmProtocol {
+	case "":
+		return ProtocolHTTP, true
+	case ProtocolHTTP, ProtocolHTTPS:
+		return nmProtocol, true
+	}
+	return "", false
+}
+
 // Publish ECS Service Messages to SQS for further processing
 func (awsService *AWSService) PublishServiceMessage(ctx context.Context, sqsQueueURL string, serviceMessage *ServiceMessage) (*string, error) {
 
@@ -2,29 +2,405 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/cache"
 )
 
+var notifyLabels = map[string]string{
+	"NOTIFY_ME_CONTAINER_PORT": "8080",
+	"NOTIFY_ME_API_URI":        "/v1.0/notify",
+}
+
+// AWS Service on fakes with empty caches, fake task definition ARNs repeat across tests
+func newFakeAWSService() (*AWSService, *fake.ECS, *fake.SQS) {
+	taskDefinitionCache.Clear()
+	ecsClient, sqsClient := fake.NewECS(), fake.NewSQS()
+	return NewAWSService(ecsClient, sqsClient, fake.NewDynamoDB()), ecsClient, sqsClient
+}
+
 var listServicesTests = map[string]struct {
-	cluster string
+	cluster      string
+	services     int
+	pageSize     int
+	wantServices int
+	wantPages    int
+	wantErr      bool
 }{
-	"test case 1": {"ecs_cluster_name"},
+	"empty cluster":          {"ecs_cluster_name", 0, 10, 0, 1, false},
+	"single page":            {"ecs_cluster_name", 3, 10, 3, 1, false},
+	"multiple pages":         {"ecs_cluster_name", 25, 10, 25, 3, false},
+	"exact page boundary":    {"ecs_cluster_name", 20, 10, 20, 2, false},
+	"cluster does not exist": {"missing_cluster", 0, 10, 0, 1, true},
 }
 
 func TestListECSServices(t *testing.T) {
 	ctx := context.TODO()
-	awsService, err := NewAWSService(ctx)
-	if err != nil {
-		t.Fail()
-	}
 
 	for name, tc := range listServicesTests {
 		t.Run(name, func(t *testing.T) {
+			awsService, ecsClient, _ := newFakeAWSService()
+			ecsClient.PageSize = tc.pageSize
+			ecsClient.Services["ecs_cluster_name"] = nil
+			for i := 0; i < tc.services; i++ {
+				ecsClient.AddService("ecs_cluster_name", fmt.Sprintf("service-%02d", i), notifyLabels)
+			}
+
 			actual, err := awsService.ListECSServices(ctx, tc.cluster)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ListECSServices() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if len(actual) != tc.wantServices {
+				t.Errorf("ListECSServices() services = %d, want %d", len(actual), tc.wantServices)
+			}
+			if ecsClient.Calls["ListServices"] != tc.wantPages {
+				t.Errorf("ListServices calls = %d, want %d", ecsClient.Calls["ListServices"], tc.wantPages)
+			}
+			for i, service := range actual {
+				if service.Service != fmt.Sprintf("service-%02d", i) || service.Cluster != tc.cluster || service.TaskDefinition == "" {
+					t.Errorf("unexpected service %+v", service)
+				}
+			}
+		})
+	}
+}
+
+var describeServicesTests = map[string]struct {
+	failures      map[string]string
+	wantServices  int
+	wantDescribes int
+	wantErr       bool
+}{
+	"chunked to describe limit": {nil, 25, 3, false},
+	"missing services skipped":  {map[string]string{"service-03": "MISSING", "service-17": "MISSING"}, 23, 3, false},
+	"other failures fail":       {map[string]string{"service-03": "MISSING", "service-17": "ACCESS_DENIED"}, 0, 3, true},
+}
+
+func TestListECSServicesDescribeChunks(t *testing.T) {
+	ctx := context.TODO()
+
+	for name, tc := range describeServicesTests {
+		t.Run(name, func(t *testing.T) {
+			awsService, ecsClient, _ := newFakeAWSService()
+			// Single ListServices page beyond the DescribeServices limit
+			ecsClient.PageSize = 100
+			for i := range 25 {
+				ecsClient.AddService("ecs_cluster_name", fmt.Sprintf("service-%02d", i), notifyLabels)
+			}
+			for service, reason := range tc.failures {
+				ecsClient.DescribeFailures["arn:aws:ecs:us-east-1:123456789012:service/ecs_cluster_name/"+service] = reason
+			}
+
+			actual, err := awsService.ListECSServices(ctx, "ecs_cluster_name")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ListECSServices() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if len(actual) != tc.wantServices {
+				t.Errorf("ListECSServices() services = %d, want %d", len(actual), tc.wantServices)
+			}
+			if ecsClient.Calls["DescribeServices"] != tc.wantDescribes {
+				t.Errorf("DescribeServices calls = %d, want %d", ecsClient.Calls["DescribeServices"], tc.wantDescribes)
+			}
+			for i := 1; i < len(actual); i++ {
+				if actual[i-1].Service >= actual[i].Service {
+					t.Errorf("services out of listing order %s, %s", actual[i-1].Service, actual[i].Service)
+				}
+			}
+		})
+	}
+}
+
+func TestListECSServicesTagsAndFamily(t *testing.T) {
+	awsService, ecsClient, _ := newFakeAWSService()
+	ecsClient.AddService("ecs_cluster_name", "orders-api", notifyLabels)
+	ecsClient.TagService("ecs_cluster_name", "orders-api", map[string]string{"team": "payments"})
+
+	actual, err := awsService.ListECSServices(context.TODO(), "ecs_cluster_name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 1 || actual[0].TaskDefinitionFamily != "orders-api" || actual[0].Tags["team"] != "payments" {
+		t.Errorf("ListECSServices() = %+v, want task definition family and tags", actual)
+	}
+}
+
+var filterServicesTests = map[string]struct {
+	dockerLabels []map[string]string
+	wantMatch    bool
+	wantMethod   string
+}{
+	"all labels present":       {[]map[string]string{notifyLabels}, true, "POST"},
+	"no docker labels":         {[]map[string]string{{}}, false, ""},
+	"container port only":      {[]map[string]string{{"NOTIFY_ME_CONTAINER_PORT": "8080"}}, false, ""},
+	"api uri only":             {[]map[string]string{{"NOTIFY_ME_API_URI": "/v1.0/notify"}}, false, ""},
+	"labels on second sidecar": {[]map[string]string{{"OTHER": "x"}, notifyLabels}, true, "POST"},
+	"http method label": {[]map[string]string{{
+		"NOTIFY_ME_CONTAINER_PORT": "8080",
+		"NOTIFY_ME_API_URI":        "/v1.0/notify",
+		"NOTIFY_ME_HTTP_METHOD":    "put",
+	}}, true, "PUT"},
+}
+
+func TestFilterECSServices(t *testing.T) {
+	ctx := context.TODO()
+
+	for name, tc := range filterServicesTests {
+		t.Run(name, func(t *testing.T) {
+			awsService, ecsClient, _ := newFakeAWSService()
+			ecsClient.AddService("ecs_cluster_name", "ecs_service_name", tc.dockerLabels...)
+
+			services, err := awsService.ListECSServices(ctx, "ecs_cluster_name")
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := awsService.FilterECSServices(ctx, services, "")
 			if err != nil {
-				t.Fail()
-			} else if len(actual) <= 0 {
-				t.Fail()
+				t.Fatal(err)
+			}
+
+			if !tc.wantMatch {
+				if len(actual) != 0 {
+					t.Errorf("FilterECSServices() = %v, want none", actual)
+				}
+				return
+			}
+
+			want := &ServiceMessage{
+				Cluster:               "ecs_cluster_name",
+				Service:               "ecs_service_name",
+				NotifyMeContainerPort: "8080",
+				NotifyMeAPIUri:        "/v1.0/notify",
+				NotifyMeHTTPMethod:    tc.wantMethod,
+				NotifyMeProtocol:      "http",
+			}
+			if len(actual) != 1 || !reflect.DeepEqual(actual[0], want) {
+				t.Errorf("FilterECSServices() = %+v, want %+v", actual, want)
+			}
+		})
+	}
+}
+
+func TestFilterECSServicesAuthLabels(t *testing.T) {
+	ctx := context.TODO()
+	awsService, ecsClient, _ := newFakeAWSService()
+	ecsClient.AddService("ecs_cluster_name", "ecs_service_name", map[string]string{
+		"NOTIFY_ME_CONTAINER_PORT": "8080",
+		"NOTIFY_ME_API_URI":        "/v1.0/notify",
+		"NOTIFY_ME_AUTH_AUDIENCE":  "https://notify.svc.internal",
+		"NOTIFY_ME_AUTH_SCOPE":     " notify:write ",
+	})
+
+	services, err := awsService.ListECSServices(ctx, "ecs_cluster_name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := awsService.FilterECSServices(ctx, services, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 1 || actual[0].NotifyMeAuthAudience != "https://notify.svc.internal" || actual[0].NotifyMeAuthScope != "notify:write" {
+		t.Errorf("FilterECSServices() = %+v, want audience and scope", actual)
+	}
+}
+
+var filterServicesProtocolTests = map[string]struct {
+	protocol       string
+	serverName     string
+	wantMatch      bool
+	wantProtocol   string
+	wantServerName string
+}{
+	"protocol label missing": {"", "", true, "http", ""},
+	"https protocol":         {"HTTPS", "", true, "https", ""},
+	"https with server name": {"https", " notify.svc.internal ", true, "https", "notify.svc.internal"},
+	"unsupported protocol":   {"grpc", "", false, "", ""},
+}
+
+func TestFilterECSServicesProtocol(t *testing.T) {
+	ctx := context.TODO()
+
+	for name, tc := range filterServicesProtocolTests {
+		t.Run(name, func(t *testing.T) {
+			labels := map[string]string{
+				"NOTIFY_ME_CONTAINER_PORT": "8443",
+				"NOTIFY_ME_API_URI":        "/v1.0/notify",
+			}
+			if tc.protocol != "" {
+				labels["NOTIFY_ME_PROTOCOL"] = tc.protocol
+			}
+			if tc.serverName != "" {
+				labels["NOTIFY_ME_TLS_SERVER_NAME"] = tc.serverName
+			}
+			awsService, ecsClient, _ := newFakeAWSService()
+			ecsClient.AddService("ecs_cluster_name", "ecs_service_name", labels)
+
+			services, err := awsService.ListECSServices(ctx, "ecs_cluster_name")
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := awsService.FilterECSServices(ctx, services, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantServices := 0
+			if tc.wantMatch {
+				wantServices = 1
+			}
+			if len(actual) != wantServices {
+				t.Fatalf("FilterECSServices() = %v, wantMatch %v", actual, tc.wantMatch)
+			}
+			if tc.wantMatch && (actual[0].NotifyMeProtocol != tc.wantProtocol || actual[0].NotifyMeTLSServerName != tc.wantServerName) {
+				t.Errorf("protocol = %q, server name = %q, want %q, %q",
+					actual[0].NotifyMeProtocol, actual[0].NotifyMeTLSServerName, tc.wantProtocol, tc.wantServerName)
+			}
+		})
+	}
+}
+
+func TestFilterECSServicesDescribeError(t *testing.T) {
+	ctx := context.TODO()
+	awsService, ecsClient, _ := newFakeAWSService()
+	ecsClient.AddService("ecs_cluster_name", "ecs_service_name", notifyLabels)
+	describeErr := &types.ServerException{Message: new(string)}
+	ecsClient.Errors["DescribeTaskDefinition"] = describeErr
+
+	services, _ := awsService.ListECSServices(ctx, "ecs_cluster_name")
+	if _, err := awsService.FilterECSServices(ctx, services, ""); !errors.Is(err, describeErr) {
+		t.Errorf("FilterECSServices() error = %v, want %v", err, describeErr)
+	}
+}
+
+func TestFilterECSServicesTopics(t *testing.T) {
+	ctx := context.TODO()
+	awsService, ecsClient, _ := newFakeAWSService()
+	ecsClient.AddService("ecs_cluster_name", "config-service", map[string]string{
+		"NOTIFY_ME_CONTAINER_PORT": "8080",
+		"NOTIFY_ME_API_URI":        "/v1.0/notify",
+		"NOTIFY_ME_TOPICS":         "config.*",
+	})
+	// Sidecar subscribed to the cache topics, application container to the config topics
+	ecsClient.AddService("ecs_cluster_name", "cache-service", map[string]string{
+		"NOTIFY_ME_CONTAINER_PORT": "8080",
+		"NOTIFY_ME_API_URI":        "/v1.0/notify",
+		"NOTIFY_ME_TOPICS":         "config.reload",
+	}, map[string]string{
+		"NOTIFY_ME_CONTAINER_PORT": "9090",
+		"NOTIFY_ME_API_URI":        "/cache/notify",
+		"NOTIFY_ME_TOPICS":         "cache.flush",
+	})
+	ecsClient.AddService("ecs_cluster_name", "any-topic-service", notifyLabels)
+
+	services, err := awsService.ListECSServices(ctx, "ecs_cluster_name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := awsService.FilterECSServices(ctx, services, "cache.flush")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matched := map[string]string{}
+	for _, serviceMessage := range actual {
+		if serviceMessage.Topic != "cache.flush" {
+			t.Errorf("ServiceMessage.Topic = %q, want cache.flush", serviceMessage.Topic)
+		}
+		matched[serviceMessage.Service] = serviceMessage.NotifyMeContainerPort
+	}
+	if want := map[string]string{"cache-service": "9090", "any-topic-service": "8080"}; !reflect.DeepEqual(matched, want) {
+		t.Errorf("FilterECSServices() = %v, want %v", matched, want)
+	}
+}
+
+func TestFilterECSServicesCachesTaskDefinitions(t *testing.T) {
+	ctx := context.TODO()
+	awsService, ecsClient, _ := newFakeAWSService()
+	ecsClient.AddService("ecs_cluster_name", "service-1", notifyLabels)
+	ecsClient.AddService("ecs_cluster_name", "service-2", notifyLabels)
+
+	services, err := awsService.ListECSServices(ctx, "ecs_cluster_name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if actual, err := awsService.FilterECSServices(ctx, services, ""); err != nil || len(actual) != 2 {
+			t.Fatalf("FilterECSServices() = %v, %v", actual, err)
+		}
+	}
+
+	if ecsClient.Calls["DescribeTaskDefinition"] != 2 {
+		t.Errorf("DescribeTaskDefinition calls = %d, want 2", ecsClient.Calls["DescribeTaskDefinition"])
+	}
+	if stats := TaskDefinitionCacheStats(); stats != (cache.Stats{Hits: 4, Misses: 2}) {
+		t.Errorf("TaskDefinitionCacheStats() = %+v, want 4 hits and 2 misses", stats)
+	}
+}
+
+var publishServiceMessageTests = map[string]struct {
+	sendErr error
+	wantErr bool
+}{
+	"message published":     {nil, false},
+	"send message rejected": {errors.New("AccessDenied"), true},
+}
+
+func TestPublishServiceMessage(t *testing.T) {
+	ctx := context.TODO()
+
+	for name, tc := range publishServiceMessageTests {
+		t.Run(name, func(t *testing.T) {
+			awsService, _, sqsClient := newFakeAWSService()
+			sqsClient.Err = tc.sendErr
+
+			serviceMessage := NewServiceMessage()
+			serviceMessage.NotificationId = "notification-1"
+			serviceMessage.Cluster = "ecs_cluster_name"
+			serviceMessage.Service = "ecs_service_name"
+			serviceMessage.Payload = json.RawMessage(`{"version":"2"}`)
+
+			messageId, err := awsService.PublishServiceMessage(ctx, "queue-url", serviceMessage)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("PublishServiceMessage() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if messageId != nil || len(sqsClient.Messages["queue-url"]) != 0 {
+					t.Errorf("unexpected message published")
+				}
+				return
+			}
+
+			var published ServiceMessage
+			if err := json.Unmarshal([]byte(sqsClient.Messages["queue-url"][0]), &published); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&published, serviceMessage) {
+				t.Errorf("published %+v, want %+v", published, serviceMessage)
+			}
+		})
+	}
+}
+
+var notifyMeHTTPMethodTests = map[string]struct {
+	labels map[string]string
+	want   string
+}{
+	"label missing":    {map[string]string{}, "POST"},
+	"label blank":      {map[string]string{"NOTIFY_ME_HTTP_METHOD": " "}, "POST"},
+	"label lower case": {map[string]string{"NOTIFY_ME_HTTP_METHOD": "put"}, "PUT"},
+	"label upper case": {map[string]string{"NOTIFY_ME_HTTP_METHOD": "GET"}, "GET"},
+}
+
+func TestNotifyMeHTTPMethod(t *testing.T) {
+	for name, tc := range notifyMeHTTPMethodTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := notifyMeHTTPMethod(tc.labels); actual != tc.want {
+				t.Errorf("notifyMeHTTPMethod() = %s, want %s", actual, tc.want)
 			}
 		})
 	}
@@ -0,0 +1,266 @@
+// Package fake provides in-memory fakes of the AWS clients used by the
+// ECS service discovery Lambda, for unit tests without AWS access
+package fake
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// In-memory ECS clusters, services and task definitions
+type ECS struct {
+	mu sync.Mutex
+
+	// Cluster names in listing order
+	Clusters []string
+	// Tags by cluster name
+	ClusterTags map[string]map[string]string
+	// Services by cluster name in listing order
+	Services map[string][]types.Service
+	// Task definitions by task definition ARN
+	TaskDefinitions map[string]types.TaskDefinition
+	// Services returned per ListServices page, 10 unless set
+	PageSize int
+	// Errors returned by operation name e.g. "DescribeTaskDefinition"
+	Errors map[string]error
+	// Errors returned by ListServices by cluster name
+	ClusterErrors map[string]error
+	// Failure reasons reported by DescribeServices by service ARN, e.g. "MISSING"
+	DescribeFailures map[string]string
+	// Number of calls by operation name
+	Calls map[string]int
+}
+
+func NewECS() *ECS {
+	return &ECS{
+		ClusterTags:      make(map[string]map[string]string),
+		Services:         make(map[string][]types.Service),
+		TaskDefinitions:  make(map[string]types.TaskDefinition),
+		Errors:           make(map[string]error),
+		ClusterErrors:    make(map[string]error),
+		DescribeFailures: make(map[string]string),
+		Calls:            make(map[string]int),
+	}
+}
+
+// Add a service of the cluster running the task definition with container docker labels
+func (fake *ECS) AddService(cluster string, service string, dockerLabels ...map[string]string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.addCluster(cluster)
+	taskDefinitionArn := fmt.Sprintf("arn:aws:ecs:us-east-1:123456789012:task-definition/%s:1", service)
+	fake.Services[cluster] = append(fake.Services[cluster], types.Service{
+		ServiceArn:     aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:123456789012:service/%s/%s", cluster, service)),
+		ServiceName:    aws.String(service),
+		TaskDefinition: aws.String(taskDefinitionArn),
+	})
+
+	var containerDefinitions []types.ContainerDefinition
+	for i, labels := range dockerLabels {
+		containerDefinitions = append(containerDefinitions, types.ContainerDefinition{
+			Name:         aws.String(fmt.Sprintf("container-%d", i)),
+			DockerLabels: labels,
+		})
+	}
+	fake.TaskDefinitions[taskDefinitionArn] = types.TaskDefinition{
+		TaskDefinitionArn:    aws.String(taskDefinitionArn),
+		Family:               aws.String(service),
+		ContainerDefinitions: containerDefinitions,
+	}
+}
+
+// Add a cluster without services, tagged with the given tags
+func (fake *ECS) AddCluster(cluster string, tags map[string]string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.addCluster(cluster)
+	fake.ClusterTags[cluster] = tags
+}
+
+func (fake *ECS) addCluster(cluster string) {
+	if _, ok := fake.Services[cluster]; !ok {
+		fake.Clusters = append(fake.Clusters, cluster)
+		fake.Services[cluster] = []types.Service{}
+	}
+}
+
+func clusterArn(cluster string) string {
+	return fmt.Sprintf("arn:aws:ecs:us-east-1:123456789012:cluster/%s", cluster)
+}
+
+// Tag a service of the cluster added before
+func (fake *ECS) TagService(cluster string, service string, tags map[string]string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	for i := range fake.Services[cluster] {
+		if aws.ToString(fake.Services[cluster][i].ServiceName) != service {
+			continue
+		}
+		for key, value := range tags {
+			fake.Services[cluster][i].Tags = append(fake.Services[cluster][i].Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+	}
+}
+
+func (fake *ECS) call(operation string) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.Calls[operation]++
+	return fake.Errors[operation]
+}
+
+func (fake *ECS) ListClusters(ctx context.Context, params *ecs.ListClustersInput, optFns ...func(*ecs.Options)) (*ecs.ListClustersOutput, error) {
+	if err := fake.call("ListClusters"); err != nil {
+		return nil, err
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	pageSize := fake.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	start := 0
+	if params.NextToken != nil {
+		start, _ = strconv.Atoi(aws.ToString(params.NextToken))
+	}
+	end := min(start+pageSize, len(fake.Clusters))
+
+	output := &ecs.ListClustersOutput{}
+	for _, cluster := range fake.Clusters[start:end] {
+		output.ClusterArns = append(output.ClusterArns, clusterArn(cluster))
+	}
+	if end < len(fake.Clusters) {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+func (fake *ECS) DescribeClusters(ctx context.Context, params *ecs.DescribeClustersInput, optFns ...func(*ecs.Options)) (*ecs.DescribeClustersOutput, error) {
+	if err := fake.call("DescribeClusters"); err != nil {
+		return nil, err
+	}
+	if len(params.Clusters) > 100 {
+		return nil, &types.InvalidParameterException{Message: aws.String("clusters can have at most 100 items.")}
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	output := &ecs.DescribeClustersOutput{}
+	for _, cluster := range params.Clusters {
+		name := cluster[strings.LastIndex(cluster, "/")+1:]
+		if !slices.Contains(fake.Clusters, name) {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(cluster), Reason: aws.String("MISSING")})
+			continue
+		}
+		described := types.Cluster{ClusterArn: aws.String(clusterArn(name)), ClusterName: aws.String(name)}
+		// Tags are returned only when included
+		if slices.Contains(params.Include, types.ClusterFieldTags) {
+			for key, value := range fake.ClusterTags[name] {
+				described.Tags = append(described.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+			}
+		}
+		output.Clusters = append(output.Clusters, described)
+	}
+	return output, nil
+}
+
+func (fake *ECS) ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error) {
+	if err := fake.call("ListServices"); err != nil {
+		return nil, err
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if err := fake.ClusterErrors[aws.ToString(params.Cluster)]; err != nil {
+		return nil, err
+	}
+	services, ok := fake.Services[aws.ToString(params.Cluster)]
+	if !ok {
+		return nil, &types.ClusterNotFoundException{Message: aws.String("Cluster not found.")}
+	}
+
+	pageSize := fake.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	start := 0
+	if params.NextToken != nil {
+		start, _ = strconv.Atoi(aws.ToString(params.NextToken))
+	}
+	end := min(start+pageSize, len(services))
+
+	output := &ecs.ListServicesOutput{}
+	for _, service := range services[start:end] {
+		output.ServiceArns = append(output.ServiceArns, aws.ToString(service.ServiceArn))
+	}
+	if end < len(services) {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+func (fake *ECS) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	if err := fake.call("DescribeServices"); err != nil {
+		return nil, err
+	}
+	if len(params.Services) == 0 || len(params.Services) > 10 {
+		return nil, &types.InvalidParameterException{Message: aws.String("services must contain between 1 and 10 items.")}
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	output := &ecs.DescribeServicesOutput{}
+	for _, serviceArn := range params.Services {
+		if reason, ok := fake.DescribeFailures[serviceArn]; ok {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(serviceArn), Reason: aws.String(reason)})
+			continue
+		}
+		found := false
+		for _, service := range fake.Services[aws.ToString(params.Cluster)] {
+			if aws.ToString(service.ServiceArn) == serviceArn || aws.ToString(service.ServiceName) == serviceArn {
+				// Tags are returned only when included
+				if !slices.Contains(params.Include, types.ServiceFieldTags) {
+					service.Tags = nil
+				}
+				output.Services = append(output.Services, service)
+				found = true
+				break
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(serviceArn), Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+func (fake *ECS) DescribeTaskDefinition(ctx context.Context, params *ecs.DescribeTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTaskDefinitionOutput, error) {
+	if err := fake.call("DescribeTaskDefinition"); err != nil {
+		return nil, err
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	taskDefinition, ok := fake.TaskDefinitions[aws.ToString(params.TaskDefinition)]
+	if !ok {
+		return nil, &types.ClientException{Message: aws.String("Unable to describe task definition.")}
+	}
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: &taskDefinition}, nil
+}
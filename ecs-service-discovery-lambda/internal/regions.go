@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// AWS region name e.g. us-east-1 or us-gov-west-1
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// Region of the AWS Service, default region of the config unless built for a region
+func (awsService *AWSService) Region() string {
+	return awsService.region
+}
+
+// AWS Service calling ECS and SQS in the region, the service itself in its own region.
+// ECS is called with the workload account role of the service, SQS in the notifier account.
+// Invalid regions fail every retry, reported as permanent error
+func (awsService *AWSService) ForRegion(region string) (*AWSService, error) {
+	if region == "" || region == awsService.region {
+		return awsService, nil
+	}
+	if !regionPattern.MatchString(region) {
+		return nil, failure.Permanent(fmt.Errorf("invalid region %q", region))
+	}
+	if awsService.roleClients == nil {
+		return nil, failure.Permanent(fmt.Errorf("cross-region notification to %s not supported", region))
+	}
+
+	regionService := *awsService
+	regionService.region = region
+	regionService.ecsClient = awsService.roleClients(awsService.roleArn, region).ECSClient
+	regionService.sqsClient = awsService.roleClients("", region).SQSClient
+	return &regionService, nil
+}
+
+// Parse comma separated queue URLs of regions e.g. us-west-2=https://sqs.us-west-2.amazonaws.com/123456789012/name
+func ParseRegionQueueURLs(value string) (map[string]string, error) {
+	regionQueueURLs := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		region, queueURL, ok := strings.Cut(item, "=")
+		region, queueURL = strings.TrimSpace(region), strings.TrimSpace(queueURL)
+		if !ok || !regionPattern.MatchString(region) || queueURL == "" {
+			return nil, fmt.Errorf("invalid region queue URL %q, expected region=queue-url", item)
+		}
+		regionQueueURLs[region] = queueURL
+	}
+	return regionQueueURLs, nil
+}
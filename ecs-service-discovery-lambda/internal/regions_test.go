@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/assumerole"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// Fake clients of each role and region, built on first use
+type fakeRoleClients struct {
+	ecsClients map[assumerole.Key]*fake.ECS
+	sqsClients map[assumerole.Key]*fake.SQS
+}
+
+func newFakeRoleClients() *fakeRoleClients {
+	return &fakeRoleClients{ecsClients: map[assumerole.Key]*fake.ECS{}, sqsClients: map[assumerole.Key]*fake.SQS{}}
+}
+
+func (clients *fakeRoleClients) factory(roleArn string, region string) *RoleClients {
+	key := assumerole.Key{RoleArn: roleArn, Region: region}
+	if clients.ecsClients[key] == nil {
+		clients.ecsClients[key], clients.sqsClients[key] = fake.NewECS(), fake.NewSQS()
+	}
+	return &RoleClients{ECSClient: clients.ecsClients[key], SQSClient: clients.sqsClients[key]}
+}
+
+func TestForRegion(t *testing.T) {
+	awsService, _, _ := newFakeAWSService()
+	awsService = awsService.withRegion("us-east-1")
+	roleClients := newFakeRoleClients()
+	awsService = awsService.WithRoleClients(roleClients.factory)
+
+	for _, region := range []string{"", "us-east-1"} {
+		if regionService, err := awsService.ForRegion(region); err != nil || regionService != awsService {
+			t.Errorf("ForRegion(%q) = %v, %v, want the service itself", region, regionService, err)
+		}
+	}
+
+	regionService, err := awsService.ForRegion("us-west-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regionService.Region() != "us-west-2" {
+		t.Errorf("Region() = %q, want us-west-2", regionService.Region())
+	}
+	notifierKey := assumerole.Key{Region: "us-west-2"}
+	if regionService.ecsClient != roleClients.ecsClients[notifierKey] || regionService.sqsClient != roleClients.sqsClients[notifierKey] {
+		t.Errorf("ForRegion() clients not of region us-west-2")
+	}
+
+	// Workload account role in the region, SQS stays in the notifier account
+	roleService, err := regionService.ForRole(workloadRoleArn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workloadKey := assumerole.Key{RoleArn: workloadRoleArn, Region: "us-west-2"}
+	if roleService.ecsClient != roleClients.ecsClients[workloadKey] || roleService.sqsClient != roleClients.sqsClients[notifierKey] {
+		t.Errorf("ForRole() clients not of workload role in region us-west-2")
+	}
+	roleRegionService, err := awsService.ForRole(workloadRoleArn)
+	if err == nil {
+		roleRegionService, err = roleRegionService.ForRegion("us-west-2")
+	}
+	if err != nil || roleRegionService.ecsClient != roleClients.ecsClients[workloadKey] {
+		t.Errorf("ForRole().ForRegion() clients not of workload role in region us-west-2")
+	}
+}
+
+var forRegionErrorTests = map[string]struct {
+	region    string
+	noFactory bool
+}{
+	"invalid region":  {"us-west-2/../", false},
+	"region endpoint": {"sqs.us-west-2.amazonaws.com", false},
+	"without factory": {"us-west-2", true},
+}
+
+func TestForRegionErrors(t *testing.T) {
+	for name, tc := range forRegionErrorTests {
+		t.Run(name, func(t *testing.T) {
+			awsService, _, _ := newFakeAWSService()
+			if !tc.noFactory {
+				awsService = awsService.WithRoleClients(newFakeRoleClients().factory)
+			}
+			if _, err := awsService.ForRegion(tc.region); !failure.IsPermanent(err) {
+				t.Errorf("ForRegion() error = %v, want permanent error", err)
+			}
+		})
+	}
+}
+
+var parseRegionQueueURLsTests = map[string]struct {
+	value   string
+	want    map[string]string
+	wantErr bool
+}{
+	"empty":          {"", map[string]string{}, false},
+	"single":         {"us-west-2=west-queue-url", map[string]string{"us-west-2": "west-queue-url"}, false},
+	"govcloud":       {" us-gov-west-1 = gov-queue-url ,", map[string]string{"us-gov-west-1": "gov-queue-url"}, false},
+	"missing url":    {"us-west-2=", nil, true},
+	"invalid region": {"west=west-queue-url", nil, true},
+	"multiple": {
+		"us-west-2=west-queue-url,eu-west-1=eu-queue-url",
+		map[string]string{"us-west-2": "west-queue-url", "eu-west-1": "eu-queue-url"}, false,
+	},
+}
+
+func TestParseRegionQueueURLs(t *testing.T) {
+	for name, tc := range parseRegionQueueURLsTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseRegionQueueURLs(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseRegionQueueURLs() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(actual, tc.want) {
+				t.Errorf("ParseRegionQueueURLs() = %v, want %v", actual, tc.want)
+			}
+		})
+	}
+}
+
+func TestListECSServicesOfRegion(t *testing.T) {
+	awsService, ecsClient, _ := newFakeAWSService()
+	ecsClient.AddService("cluster-a", "home-service", notifyLabels)
+	roleClients := newFakeRoleClients()
+	awsService = awsService.WithRoleClients(roleClients.factory)
+	roleClients.factory("", "eu-west-1")
+	roleClients.ecsClients[assumerole.Key{Region: "eu-west-1"}].AddService("cluster-a", "eu-service", notifyLabels)
+
+	regionService, err := awsService.ForRegion("eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	services, err := regionService.ListECSServices(context.TODO(), "cluster-a")
+	if err != nil || len(services) != 1 || services[0].Service != "eu-service" {
+		t.Errorf("ListECSServices() = %v, %v, want eu-service", services, err)
+	}
+}
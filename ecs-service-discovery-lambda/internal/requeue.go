@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/requeue"
+)
+
+const (
+	// Message attribute counting re-enqueues of the notification message
+	RequeueCountAttribute = "DiscoveryRequeueCount"
+
+	// Re-enqueues of the failed clusters and regions of a notification, the
+	// message is retried by SQS and reaches the dead letter queue afterwards
+	MaxRequeues = 3
+)
+
+// Re-enqueues of the SQS message so far
+func RequeueCount(record events.SQSMessage) int {
+	return requeue.Count(record, RequeueCountAttribute)
+}
+
+// Send notification message of the failed clusters and regions back to the
+// notification queue to be delivered after delay
+func (awsService *AWSService) RequeueNotification(ctx context.Context, sqsQueueURL string, ecsNotify *EcsNotify, requeueCount int, delay time.Duration) (*string, error) {
+	return requeue.Send(ctx, awsService.sqsClient, RequestIdFromContext(ctx), sqsQueueURL, ecsNotify, RequeueCountAttribute, requeueCount, delay)
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-discovery-lambda/internal/fake"
+)
+
+var requeueCountTests = map[string]struct {
+	attributes map[string]events.SQSMessageAttribute
+	want       int
+}{
+	"not re-enqueued": {nil, 0},
+	"re-enqueued":     {map[string]events.SQSMessageAttribute{RequeueCountAttribute: {StringValue: aws.String("2"), DataType: "Number"}}, 2},
+	"invalid count":   {map[string]events.SQSMessageAttribute{RequeueCountAttribute: {StringValue: aws.String("many"), DataType: "Number"}}, 0},
+}
+
+func TestRequeueCount(t *testing.T) {
+	for name, tc := range requeueCountTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := RequeueCount(events.SQSMessage{MessageAttributes: tc.attributes}); actual != tc.want {
+				t.Errorf("RequeueCount() = %d, want %d", actual, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequeueNotification(t *testing.T) {
+	sqsClient := fake.NewSQS()
+	awsService := NewAWSService(fake.NewECS(), sqsClient, fake.NewDynamoDB())
+
+	_, err := awsService.RequeueNotification(context.TODO(), "notification-queue-url", &EcsNotify{NotificationId: "n1", Clusters: []string{"cluster-a"}}, 1, time.Minute)
+	if err != nil {
+		t.Fatalf("RequeueNotification() error = %v", err)
+	}
+	if want := `{"notification_id":"n1","cluster":"","clusters":["cluster-a"]}`; len(sqsClient.Messages["notification-queue-url"]) != 1 || sqsClient.Messages["notification-queue-url"][0] != want {
+		t.Errorf("re-enqueued messages = %v, want %s", sqsClient.Messages["notification-queue-url"], want)
+	}
+}
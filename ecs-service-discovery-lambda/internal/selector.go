@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// Optional selector of the ECS services targeted by a notification. All given
+// criteria must match, a list criterion matches any of its values
+type ServiceSelector struct {
+	// Service names e.g. ["orders-api", "orders-worker"]
+	Services []string `json:"services,omitempty"`
+	// Service name glob e.g. "orders-*"
+	ServiceNamePattern string `json:"service_name_pattern,omitempty"`
+	// Service name regular expression e.g. "^orders-(api|worker)$"
+	ServiceNameRegex string `json:"service_name_regex,omitempty"`
+	// ECS service tags, an empty value matches any value of the tag
+	Tags map[string]string `json:"tags,omitempty"`
+	// Task definition families e.g. ["orders"]
+	TaskDefinitionFamilies []string `json:"task_definition_families,omitempty"`
+}
+
+// Task definition family of task definition ARN or family:revision reference
+func taskDefinitionFamily(taskDefinition string) string {
+	family := taskDefinition[strings.LastIndex(taskDefinition, "/")+1:]
+	if i := strings.LastIndex(family, ":"); i >= 0 {
+		family = family[:i]
+	}
+	return family
+}
+
+// Select the ECS services matching the selector, all services without selector.
+// Invalid pattern or regular expression fails every retry, reported as permanent error
+func SelectECSServices(services []*EcsService, selector *ServiceSelector) ([]*EcsService, error) {
+	if selector == nil {
+		return services, nil
+	}
+
+	if selector.ServiceNamePattern != "" {
+		if _, err := path.Match(selector.ServiceNamePattern, ""); err != nil {
+			return nil, failure.Permanent(fmt.Errorf("invalid service name pattern %q: %w", selector.ServiceNamePattern, err))
+		}
+	}
+	var serviceNameRegex *regexp.Regexp
+	if selector.ServiceNameRegex != "" {
+		var err error
+		serviceNameRegex, err = regexp.Compile(selector.ServiceNameRegex)
+		if err != nil {
+			return nil, failure.Permanent(fmt.Errorf("invalid service name regex %q: %w", selector.ServiceNameRegex, err))
+		}
+	}
+
+	var selected []*EcsService
+	for _, service := range services {
+		if selector.matches(service, serviceNameRegex) {
+			selected = append(selected, service)
+		}
+	}
+	return selected, nil
+}
+
+func (selector *ServiceSelector) matches(service *EcsService, serviceNameRegex *regexp.Regexp) bool {
+	if len(selector.Services) > 0 && !slices.Contains(selector.Services, service.Service) {
+		return false
+	}
+	if selector.ServiceNamePattern != "" {
+		if matched, _ := path.Match(selector.ServiceNamePattern, service.Service); !matched {
+			return false
+		}
+	}
+	if serviceNameRegex != nil && !serviceNameRegex.MatchString(service.Service) {
+		return false
+	}
+	if !tagsMatch(selector.Tags, service.Tags) {
+		return false
+	}
+	if len(selector.TaskDefinitionFamilies) > 0 && !slices.Contains(selector.TaskDefinitionFamilies, service.TaskDefinitionFamily) {
+		return false
+	}
+	return true
+}
+
+// Check whether the resource tags carry all wanted tags, an empty value matches any value of the tag
+func tagsMatch(wantTags map[string]string, tags map[string]string) bool {
+	for key, value := range wantTags {
+		tagValue, ok := tags[key]
+		if !ok || (value != "" && value != tagValue) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+var selectorServices = []*EcsService{
+	{Service: "orders-api", TaskDefinitionFamily: "orders", Tags: map[string]string{"team": "payments", "tier": "web"}},
+	{Service: "orders-worker", TaskDefinitionFamily: "orders", Tags: map[string]string{"team": "payments"}},
+	{Service: "billing-api", TaskDefinitionFamily: "billing", Tags: map[string]string{"team": "finance", "tier": "web"}},
+}
+
+var selectServicesTests = map[string]struct {
+	selector *ServiceSelector
+	want     []string
+	wantErr  bool
+}{
+	"no selector":            {nil, []string{"orders-api", "orders-worker", "billing-api"}, false},
+	"empty selector":         {&ServiceSelector{}, []string{"orders-api", "orders-worker", "billing-api"}, false},
+	"service names":          {&ServiceSelector{Services: []string{"orders-worker", "billing-api"}}, []string{"orders-worker", "billing-api"}, false},
+	"service name pattern":   {&ServiceSelector{ServiceNamePattern: "orders-*"}, []string{"orders-api", "orders-worker"}, false},
+	"service name regex":     {&ServiceSelector{ServiceNameRegex: "-api$"}, []string{"orders-api", "billing-api"}, false},
+	"tag value":              {&ServiceSelector{Tags: map[string]string{"team": "payments"}}, []string{"orders-api", "orders-worker"}, false},
+	"tag present":            {&ServiceSelector{Tags: map[string]string{"tier": ""}}, []string{"orders-api", "billing-api"}, false},
+	"task definition family": {&ServiceSelector{TaskDefinitionFamilies: []string{"billing"}}, []string{"billing-api"}, false},
+	"all criteria match": {
+		&ServiceSelector{ServiceNamePattern: "*-api", Tags: map[string]string{"team": "payments"}, TaskDefinitionFamilies: []string{"orders"}},
+		[]string{"orders-api"}, false,
+	},
+	"nothing selected": {&ServiceSelector{Services: []string{"inventory"}}, nil, false},
+	"invalid pattern":  {&ServiceSelector{ServiceNamePattern: "orders-["}, nil, true},
+	"invalid regex":    {&ServiceSelector{ServiceNameRegex: "orders-("}, nil, true},
+}
+
+func TestSelectECSServices(t *testing.T) {
+	for name, tc := range selectServicesTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := SelectECSServices(selectorServices, tc.selector)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("SelectECSServices() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr && !failure.IsPermanent(err) {
+				t.Errorf("SelectECSServices() error = %v, want permanent error", err)
+			}
+
+			var selected []string
+			for _, service := range actual {
+				selected = append(selected, service.Service)
+			}
+			if !reflect.DeepEqual(selected, tc.want) {
+				t.Errorf("SelectECSServices() = %v, want %v", selected, tc.want)
+			}
+		})
+	}
+}
+
+func TestTaskDefinitionFamily(t *testing.T) {
+	for taskDefinition, want := range map[string]string{
+		"arn:aws:ecs:us-east-1:123456789012:task-definition/orders:12": "orders",
+		"orders:3": "orders",
+		"orders":   "orders",
+	} {
+		if actual := taskDefinitionFamily(taskDefinition); actual != want {
+			t.Errorf("taskDefinitionFamily(%q) = %q, want %q", taskDefinition, actual, want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"path"
+	"strings"
+)
+
+// Topic patterns of the NOTIFY_ME_TOPICS docker label e.g. "config.reload,cache.*",
+// none when the label is missing or empty
+func notifyMeTopics(dockerLabels map[string]string) []string {
+	var topics []string
+	for _, topic := range strings.Split(dockerLabels["NOTIFY_ME_TOPICS"], ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// Check whether the container subscribes to the notification topic. Notifications
+// without topic and containers without topics label match any, patterns are globs
+// e.g. "config.*" and invalid patterns never match
+func topicMatches(topicPatterns []string, topic string) bool {
+	if topic == "" || len(topicPatterns) == 0 {
+		return true
+	}
+	for _, pattern := range topicPatterns {
+		if matched, _ := path.Match(pattern, topic); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotifyMeTopics(t *testing.T) {
+	actual := notifyMeTopics(map[string]string{"NOTIFY_ME_TOPICS": " config.reload, ,cache.* "})
+	if want := []string{"config.reload", "cache.*"}; !reflect.DeepEqual(actual, want) {
+		t.Errorf("notifyMeTopics() = %v, want %v", actual, want)
+	}
+	if actual := notifyMeTopics(map[string]string{}); actual != nil {
+		t.Errorf("notifyMeTopics() = %v, want none", actual)
+	}
+}
+
+var topicMatchesTests = map[string]struct {
+	topicPatterns []string
+	topic         string
+	want          bool
+}{
+	"notification without topic": {[]string{"config.reload"}, "", true},
+	"container without topics":   {nil, "config.reload", true},
+	"exact topic":                {[]string{"cache.flush", "config.reload"}, "config.reload", true},
+	"wildcard topic":             {[]string{"config.*"}, "config.reload", true},
+	"any topic":                  {[]string{"*"}, "cache.flush", true},
+	"unsubscribed topic":         {[]string{"config.*"}, "cache.flush", false},
+	"invalid pattern":            {[]string{"config.["}, "config.reload", false},
+}
+
+func TestTopicMatches(t *testing.T) {
+	for name, tc := range topicMatchesTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := topicMatches(tc.topicPatterns, tc.topic); actual != tc.want {
+				t.Errorf("topicMatches(%v, %q) = %v, want %v", tc.topicPatterns, tc.topic, actual, tc.want)
+			}
+		})
+	}
+}
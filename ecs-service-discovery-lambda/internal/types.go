@@ -1,7 +1,23 @@
 package internal
 
+import "encoding/json"
+
+// Protocols supported by the Notify API
+const (
+	ProtocolHTTP  = "http"
+	ProtocolHTTPS = "https"
+)
+
 type EcsNotify struct {
-	Cluster string `json:"cluster"`
+	NotificationId string            `json:"notification_id,omitempty"`
+	Cluster        string            `json:"cluster"`
+	Clusters       []string          `json:"clusters,omitempty"`
+	ClusterTags    map[string]string `json:"cluster_tags,omitempty"`
+	RoleArn        string            `json:"role_arn,omitempty"`
+	Regions        []string          `json:"regions,omitempty"`
+	Topic          string            `json:"topic,omitempty"`
+	Selector       *ServiceSelector  `json:"selector,omitempty"`
+	Payload        json.RawMessage   `json:"payload,omitempty"`
 }
 
 func NewEcsNotify() *EcsNotify {
@@ -9,9 +25,11 @@ func NewEcsNotify() *EcsNotify {
 }
 
 type EcsService struct {
-	Cluster        string `json:"cluster"`
-	Service        string `json:"service"`
-	TaskDefinition string `json:"task_definition"`
+	Cluster              string            `json:"cluster"`
+	Service              string            `json:"service"`
+	TaskDefinition       string            `json:"task_definition"`
+	TaskDefinitionFamily string            `json:"task_definition_family"`
+	Tags                 map[string]string `json:"tags,omitempty"`
 }
 
 func NewEcsService() *EcsService {
@@ -19,10 +37,20 @@ func NewEcsService() *EcsService {
 }
 
 type ServiceMessage struct {
-	Cluster               string `json:"cluster"`
-	Service               string `json:"service"`
-	NotifyMeContainerPort string `json:"notify_me_container_port"`
-	NotifyMeAPIUri        string `json:"notify_me_api_uri"`
+	NotificationId        string          `json:"notification_id"`
+	Cluster               string          `json:"cluster"`
+	Service               string          `json:"service"`
+	NotifyMeContainerPort string          `json:"notify_me_container_port"`
+	NotifyMeAPIUri        string          `json:"notify_me_api_uri"`
+	NotifyMeHTTPMethod    string          `json:"notify_me_http_method"`
+	NotifyMeProtocol      string          `json:"notify_me_protocol"`
+	NotifyMeTLSServerName string          `json:"notify_me_tls_server_name,omitempty"`
+	NotifyMeAuthAudience  string          `json:"notify_me_auth_audience,omitempty"`
+	NotifyMeAuthScope     string          `json:"notify_me_auth_scope,omitempty"`
+	RoleArn               string          `json:"role_arn,omitempty"`
+	Region                string          `json:"region,omitempty"`
+	Topic                 string          `json:"topic,omitempty"`
+	Payload               json.RawMessage `json:"payload,omitempty"`
 }
 
 func NewServiceMessage() *ServiceMessage {
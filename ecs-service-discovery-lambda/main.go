@@ -3,22 +3,32 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 
 	"github.com/jittakal/ecs-task-notifier/ecs-service-discovery-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/requeue"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
-func HandleRequest(ctx context.Context, event *events.SQSEvent) error {
+// AWS Service factory, replaced by fakes in tests
+var newAWSService = internal.NewAWSServiceFromConfig
+
+// HandleRequest processes SQS messages and reports failed messages only
+// as batch item failures, so that successful messages are not retried
+func HandleRequest(ctx context.Context, event *events.SQSEvent) (events.SQSEventResponse, error) {
 	requestId := internal.RequestIdFromContext(ctx)
+	response := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
 
-	awsService, err := internal.NewAWSService(ctx)
+	awsService, err := newAWSService(ctx)
 	if err != nil {
-		return err
+		return response, err
 	}
 
 	// Accessing environment variables
@@ -26,53 +36,338 @@ func HandleRequest(ctx context.Context, event *events.SQSEvent) error {
 	sqsQueueURL, keyNotExists := os.LookupEnv("SQS_QUEUE_URL")
 	if !keyNotExists {
 		slog.Error("Environment variable value is missing", "Key", "SQS_QUEUE_URL")
-		return fmt.Errorf("environment key missing: %v", "SQS_QUEUE_URL")
+		return response, fmt.Errorf("environment key missing: %v", "SQS_QUEUE_URL")
+	}
+
+	// Optional DynamoDB table to audit notifications
+	awsService = awsService.WithAuditTable(os.Getenv("AUDIT_TABLE_NAME"))
+
+	// Optional dead letter queue of messages failed with permanent errors
+	deadLetterQueueURL := os.Getenv("DEAD_LETTER_QUEUE_URL")
+
+	// Optional comma separated workload account roles of clusters e.g. cluster-a=arn:aws:iam::123456789012:role/name
+	if clusterRolesValue, ok := os.LookupEnv("CLUSTER_ROLE_ARNS"); ok {
+		clusterRoles, clusterRolesErr := internal.ParseClusterRoles(clusterRolesValue)
+		if clusterRolesErr != nil {
+			slog.Error("Invalid environment variable value", "Key", "CLUSTER_ROLE_ARNS", "errorMessage", clusterRolesErr)
+			return response, clusterRolesErr
+		}
+		awsService = awsService.WithClusterRoles(clusterRoles)
 	}
 
+	// Optional comma separated service queue URLs of other regions e.g. us-west-2=https://sqs.us-west-2.amazonaws.com/123456789012/name
+	regionQueueURLs, regionQueueURLsErr := internal.ParseRegionQueueURLs(os.Getenv("REGION_SQS_QUEUE_URLS"))
+	if regionQueueURLsErr != nil {
+		slog.Error("Invalid environment variable value", "Key", "REGION_SQS_QUEUE_URLS", "errorMessage", regionQueueURLsErr)
+		return response, regionQueueURLsErr
+	}
+
+	// Optional notification queue the Lambda consumes, failed clusters and regions of a
+	// notification are re-enqueued to it so that notified clusters are not notified again
+	notificationQueueURL := os.Getenv("NOTIFICATION_QUEUE_URL")
+
 	for _, record := range event.Records {
-		slog.Info("Received Message Details", "requestId", requestId, "messageId", record.MessageId, "messageBody", record.Body)
+		if err := handleRecord(ctx, awsService, sqsQueueURL, regionQueueURLs, notificationQueueURL, record); err != nil {
+			slog.Error("Failed to process message", "requestId", requestId, "messageId", record.MessageId, "errorMessage", err)
+			if failure.AcknowledgePermanentFailure(ctx, awsService.SQSClient(), requestId, deadLetterQueueURL, record, err) {
+				continue
+			}
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: record.MessageId,
+			})
+		}
+	}
+
+	taskDefinitionCacheStats := internal.TaskDefinitionCacheStats()
+	slog.Info("Task definition cache statistics", "requestId", requestId,
+		"hits", taskDefinitionCacheStats.Hits, "misses", taskDefinitionCacheStats.Misses)
+	return response, nil
+}
+
+// Discover ECS services of the notification message regions and clusters and
+// publish a message for each subscribed ECS service
+func handleRecord(ctx context.Context, awsService *internal.AWSService, sqsQueueURL string, regionQueueURLs map[string]string, notificationQueueURL string, record events.SQSMessage) (err error) {
+	requestId := internal.RequestIdFromContext(ctx)
+	slog.Info("Received Message Details", "requestId", requestId, "messageId", record.MessageId, "messageBody", record.Body)
 
-		var ecsNotifyMessage internal.EcsNotify
-		// Unmarshal the JSON string into the Person struct
-		err := json.Unmarshal([]byte(record.Body), &ecsNotifyMessage)
+	// Audited before unmarshalling, invalid messages are audited by SQS message Id
+	requeueCount := internal.RequeueCount(record)
+	auditRecord := internal.NewAuditRecord(record.MessageId, "").WithRequeueCount(requeueCount)
+	defer func() {
 		if err != nil {
-			slog.Error("Failed to Unmarshal Message to struct", "requestId", requestId, "errorMessage", err)
-			return err
+			auditRecord.Error = err.Error()
+			auditRecord.Permanent = failure.IsPermanent(err)
+		}
+		awsService.PutAuditRecord(ctx, auditRecord)
+	}()
+
+	var ecsNotifyMessage internal.EcsNotify
+	// Unmarshal the JSON string into the EcsNotify struct
+	err = json.Unmarshal([]byte(record.Body), &ecsNotifyMessage)
+	if err != nil {
+		slog.Error("Failed to Unmarshal Message to struct", "requestId", requestId, "errorMessage", err)
+		return failure.Permanent(err)
+	}
+	// Messages without payload are delivered as published, the Notify API
+	// receives the originating event
+	if len(ecsNotifyMessage.Payload) == 0 {
+		ecsNotifyMessage.Payload = json.RawMessage(record.Body)
+	}
+
+	// Notification Id is generated at ingestion unless given by the publisher,
+	// SQS message Id stays the same across retries of the message
+	notificationId := ecsNotifyMessage.NotificationId
+	if notificationId == "" {
+		notificationId = record.MessageId
+	}
+
+	auditRecord.NotificationId = notificationId
+	auditRecord.Cluster = ecsNotifyMessage.Cluster
+	auditRecord.Topic = ecsNotifyMessage.Topic
+	auditRecord.RoleArn = ecsNotifyMessage.RoleArn
+
+	// Home region unless the notification names target regions
+	var regions []string
+	for _, region := range ecsNotifyMessage.Regions {
+		if !slices.Contains(regions, region) {
+			regions = append(regions, region)
+		}
+	}
+	auditRecord.Regions = regions
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+
+	// Fan out per region and cluster, a failed region or cluster does not hold back the others
+	var notifiedServices []*internal.ServiceMessage
+	var notifiedClusters []string
+	var permanentErrs, transientErrs []error
+	for _, region := range regions {
+		serviceMessages, clusters, regionErrs := notifyRegion(ctx, awsService, sqsQueueURL, regionQueueURLs, &ecsNotifyMessage, notificationId, region)
+		notifiedServices = append(notifiedServices, serviceMessages...)
+		notifiedClusters = append(notifiedClusters, clusters...)
+		for _, regionErr := range regionErrs {
+			if failure.IsPermanent(regionErr) {
+				permanentErrs = append(permanentErrs, regionErr)
+			} else {
+				transientErrs = append(transientErrs, regionErr)
+			}
+		}
+	}
+	auditRecord.WithClusters(notifiedClusters)
+	auditRecord.WithServices(notifiedServices)
+
+	// Retry transient failures, permanent failures fail every retry anyway. Failed clusters
+	// and regions are re-enqueued on their own, retrying the message would notify the
+	// clusters and regions notified already once more
+	if len(transientErrs) > 0 {
+		if !requeueFailures(ctx, awsService, notificationQueueURL, &ecsNotifyMessage, notificationId, requeueCount, transientErrs) {
+			return errors.Join(transientErrs...)
+		}
+	}
+	return errors.Join(permanentErrs...)
+}
+
+// Part of a notification failed with a transient error
+type failedTarget struct {
+	// Region of the failure, the home region when empty
+	region string
+	// Cluster of the failure, all clusters of the notification in the region when empty
+	cluster string
+	// Services of the cluster left to publish, all selected services when empty
+	services []string
+	err      error
+}
+
+func (target *failedTarget) Error() string {
+	return target.err.Error()
+}
+
+func (target *failedTarget) Unwrap() error {
+	return target.err
+}
+
+// Notification messages of the failed targets, one per region of failed clusters and
+// one per cluster failed with services left to publish
+func requeueMessages(ecsNotifyMessage *internal.EcsNotify, notificationId string, targets []*failedTarget) []*internal.EcsNotify {
+	var messages []*internal.EcsNotify
+	clusterMessages := make(map[string]*internal.EcsNotify)
+	for _, target := range targets {
+		message := *ecsNotifyMessage
+		message.NotificationId = notificationId
+		message.Regions = nil
+		if target.region != "" {
+			message.Regions = []string{target.region}
+		}
+
+		switch {
+		case target.cluster == "":
+			// Clusters of the region were not resolved, retried as notified
+		case len(target.services) > 0:
+			// Services already published are left out by the selector
+			message.Cluster, message.Clusters, message.ClusterTags = target.cluster, nil, nil
+			selector := internal.ServiceSelector{}
+			if ecsNotifyMessage.Selector != nil {
+				selector = *ecsNotifyMessage.Selector
+			}
+			selector.Services = target.services
+			message.Selector = &selector
+		default:
+			// Resolved clusters of the region are retried by name
+			if regionMessage, ok := clusterMessages[target.region]; ok {
+				regionMessage.Clusters = append(regionMessage.Clusters, target.cluster)
+				continue
+			}
+			message.Cluster, message.Clusters, message.ClusterTags = "", []string{target.cluster}, nil
+			clusterMessages[target.region] = &message
+		}
+		messages = append(messages, &message)
+	}
+	return messages
+}
+
+// Re-enqueue the failed targets of the notification, false when the message has to be
+// retried instead: without notification queue, once re-enqueues are exhausted, on
+// unexpected failures or when re-enqueueing failed
+func requeueFailures(ctx context.Context, awsService *internal.AWSService, notificationQueueURL string, ecsNotifyMessage *internal.EcsNotify, notificationId string, requeueCount int, errs []error) bool {
+	requestId := internal.RequestIdFromContext(ctx)
+	if notificationQueueURL == "" || requeueCount >= internal.MaxRequeues {
+		return false
+	}
+
+	var targets []*failedTarget
+	for _, err := range errs {
+		var target *failedTarget
+		if !errors.As(err, &target) {
+			return false
+		}
+		targets = append(targets, target)
+	}
+
+	// Messages re-enqueued before a failed re-enqueue are delivered as well, the
+	// retried message notifies their targets once more
+	delay := requeue.Delay(requeueCount)
+	for _, message := range requeueMessages(ecsNotifyMessage, notificationId, targets) {
+		requeueMsgId, requeueErr := awsService.RequeueNotification(ctx, notificationQueueURL, message, requeueCount+1, delay)
+		if requeueErr != nil {
+			return false
+		}
+		slog.Info("Failed clusters re-enqueued", "requestId", requestId, "notificationId", notificationId,
+			"messageId", *requeueMsgId, "regions", message.Regions, "cluster", message.Cluster, "clusters", message.Clusters, "delay", delay)
+	}
+	return true
+}
+
+// Resolve the clusters of the region and notify each cluster. The published
+// messages, the clusters and the failures of the region are returned
+func notifyRegion(ctx context.Context, awsService *internal.AWSService, sqsQueueURL string, regionQueueURLs map[string]string, ecsNotifyMessage *internal.EcsNotify, notificationId string, region string) ([]*internal.ServiceMessage, []string, []error) {
+	requestId := internal.RequestIdFromContext(ctx)
+
+	// Transient failures are retried for the region or cluster only
+	failed := func(cluster string, services []string, err error) []error {
+		if failure.IsPermanent(err) {
+			return []error{err}
 		}
-		ecsClusterName := ecsNotifyMessage.Cluster
-
-		services, listServiceErr := awsService.ListECSServices(ctx, ecsClusterName)
-		if listServiceErr != nil {
-			// TODO Add code block to check if ECS cluster exists
-			// Check if the error is of type ClusterNotFoundException
-
-			// var clusterNotFoundErr *types.ClusterNotFoundException
-			// if errors.As(listServiceErr, &clusterNotFoundErr) {
-			// Handle the specific error
-			//	log.Printf("ECS cluster not found:", aws.ToString(clusterNotFoundErr.Message))
-			//	return []*EcsService{}, nil
-			// }
-			return listServiceErr
+		return []error{&failedTarget{region: region, cluster: cluster, services: services, err: err}}
+	}
+
+	// ECS and SQS of another region are called with clients of that region
+	regionService, regionErr := awsService.ForRegion(region)
+	if regionErr != nil {
+		return nil, nil, failed("", nil, regionErr)
+	}
+
+	// Service messages of another region are published to the queue of that region,
+	// so that tasks are notified from within their region
+	queueURL := sqsQueueURL
+	if regionService != awsService {
+		var ok bool
+		if queueURL, ok = regionQueueURLs[region]; !ok {
+			return nil, nil, []error{failure.Permanent(fmt.Errorf("queue URL of region %s not configured", region))}
 		}
-		slog.Info("Total number of services", "length", len(services))
+	}
 
-		filteredServices, filterServiceErr := awsService.FilterECSServices(ctx, services)
-		if filterServiceErr != nil {
-			return filterServiceErr
+	// Clusters of a workload account are listed with the role of the notification
+	roleService, roleErr := regionService.ForRole(ecsNotifyMessage.RoleArn)
+	if roleErr != nil {
+		return nil, nil, failed("", nil, roleErr)
+	}
+
+	// Single cluster, list of clusters or globs e.g. "*" broadcasting to all clusters
+	clusters, resolveErr := roleService.ResolveECSClusters(ctx, ecsNotifyMessage)
+	if resolveErr != nil {
+		return nil, nil, failed("", nil, resolveErr)
+	}
+	slog.Info("Total number of clusters", "region", regionService.Region(), "length", len(clusters))
+
+	// Fan out per cluster, a failed cluster does not hold back the others
+	var notifiedServices []*internal.ServiceMessage
+	var clusterErrs []error
+	for _, ecsClusterName := range clusters {
+		serviceMessages, unpublished, clusterErr := notifyCluster(ctx, regionService, queueURL, ecsNotifyMessage, notificationId, ecsClusterName)
+		notifiedServices = append(notifiedServices, serviceMessages...)
+		if clusterErr != nil {
+			slog.Error("Failed to notify cluster", "requestId", requestId, "region", regionService.Region(), "cluster", ecsClusterName, "errorMessage", clusterErr)
+			clusterErrs = append(clusterErrs, failed(ecsClusterName, unpublished, clusterErr)...)
 		}
-		slog.Info("Total number of filtered services", "length", len(filteredServices))
+	}
+	return notifiedServices, clusters, clusterErrs
+}
+
+// Discover ECS services of the cluster and publish a message for each subscribed
+// ECS service, the published messages and the services left to publish are returned
+func notifyCluster(ctx context.Context, awsService *internal.AWSService, sqsQueueURL string, ecsNotifyMessage *internal.EcsNotify, notificationId string, ecsClusterName string) ([]*internal.ServiceMessage, []string, error) {
+	requestId := internal.RequestIdFromContext(ctx)
+
+	// Services of a workload account cluster are discovered with credentials of the assumed role
+	roleArn := awsService.ClusterRoleArn(ecsClusterName, ecsNotifyMessage.RoleArn)
+	clusterService, roleErr := awsService.ForRole(roleArn)
+	if roleErr != nil {
+		return nil, nil, roleErr
+	}
+
+	services, listServiceErr := clusterService.ListECSServices(ctx, ecsClusterName)
+	if listServiceErr != nil {
+		// ClusterNotFoundException is a client fault, classified as permanent error
+		return nil, nil, listServiceErr
+	}
+	slog.Info("Total number of services", "cluster", ecsClusterName, "length", len(services))
+
+	// Optional selector targeting services of the cluster, applied before task definitions are described
+	services, selectErr := internal.SelectECSServices(services, ecsNotifyMessage.Selector)
+	if selectErr != nil {
+		return nil, nil, selectErr
+	}
+	slog.Info("Total number of selected services", "cluster", ecsClusterName, "length", len(services))
+
+	filteredServices, filterServiceErr := clusterService.FilterECSServices(ctx, services, ecsNotifyMessage.Topic)
+	if filterServiceErr != nil {
+		return nil, nil, filterServiceErr
+	}
+	slog.Info("Total number of filtered services", "cluster", ecsClusterName, "length", len(filteredServices))
 
-		for _, serviceMessage := range filteredServices {
-			svcMsgId, publishErr := awsService.PublishServiceMessage(ctx, sqsQueueURL, serviceMessage)
+	var publishedServices []*internal.ServiceMessage
+	for i, serviceMessage := range filteredServices {
+		// Carry the notification Id and event payload through to the Notify API
+		serviceMessage.NotificationId = notificationId
+		serviceMessage.Payload = ecsNotifyMessage.Payload
+		// Tasks of the service are discovered with the same role in the same region
+		serviceMessage.RoleArn = roleArn
+		serviceMessage.Region = awsService.Region()
+		svcMsgId, publishErr := awsService.PublishServiceMessage(ctx, sqsQueueURL, serviceMessage)
 
-			if publishErr != nil {
-				return publishErr // put message on retry
+		if publishErr != nil {
+			var unpublished []string
+			for _, unpublishedService := range filteredServices[i:] {
+				unpublished = append(unpublished, unpublishedService.Service)
 			}
-			slog.Info("Message published successfully", "requestId", requestId, "messageId", *svcMsgId)
+			return publishedServices, unpublished, publishErr // put message on retry
 		}
+		slog.Info("Message published successfully", "requestId", requestId, "notificationId", notificationId, "messageId", *svcMsgId)
+		publishedServices = append(publishedServices, serviceMessage)
 	}
 
-	return nil
+	return publishedServices, nil, nil
 }
 
 func main() {
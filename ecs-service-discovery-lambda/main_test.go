@@ -2,16 +2,371 @@ package main
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
 	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/smithy-go"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-discovery-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
 )
 
-func TestHandler(t *testing.T) {
-	ctx := context.TODO()
+var notifyLabels = map[string]string{
+	"NOTIFY_ME_CONTAINER_PORT": "8080",
+	"NOTIFY_ME_API_URI":        "/v1.0/notify",
+}
+
+// Replace AWS Service factory with fakes for the duration of the test
+func useFakeAWSService(t *testing.T, ecsClient *fake.ECS, sqsClient *fake.SQS) {
+	original := newAWSService
+	newAWSService = func(ctx context.Context) (*internal.AWSService, error) {
+		return internal.NewAWSService(ecsClient, sqsClient, fake.NewDynamoDB()), nil
+	}
+	t.Cleanup(func() { newAWSService = original })
+}
+
+func TestHandlerMissingQueueURL(t *testing.T) {
+	useFakeAWSService(t, fake.NewECS(), fake.NewSQS())
+	// Setenv restores the original value on cleanup
+	t.Setenv("SQS_QUEUE_URL", "")
+	os.Unsetenv("SQS_QUEUE_URL")
+
+	if _, err := HandleRequest(context.TODO(), &events.SQSEvent{}); err == nil {
+		t.Error("HandleRequest() expected missing environment key error")
+	}
+}
+
+var handlerTests = map[string]struct {
+	records         []events.SQSMessage
+	sendErr         error
+	listErr         error
+	deadLetterQueue string
+	wantFailures    []string
+	wantMessages    int
+	wantDeadLetters int
+}{
+	"all messages processed": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a"}`},
+			{MessageId: "m2", Body: `{"cluster":"cluster-b"}`},
+		},
+		nil, nil, "", []string{}, 4, 0,
+	},
+	"invalid json and unknown cluster acknowledged": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":`},
+			{MessageId: "m2", Body: `{"cluster":"cluster-a"}`},
+			{MessageId: "m3", Body: `{"cluster":"missing"}`},
+		},
+		nil, nil, "", []string{}, 2, 0,
+	},
+	"permanent errors routed to dead letter queue": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":`},
+			{MessageId: "m2", Body: `{"cluster":"missing"}`},
+		},
+		nil, nil, "dlq-url", []string{}, 0, 2,
+	},
+	"selector targets services": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a","selector":{"service_name_pattern":"service-*","services":["service-2","unsubscribed"]}}`},
+		},
+		nil, nil, "", []string{}, 1, 0,
+	},
+	"invalid selector acknowledged": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a","selector":{"service_name_regex":"("}}`},
+		},
+		nil, nil, "", []string{}, 0, 0,
+	},
+	"topic targets subscribed services": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-b","topic":"cache.flush"}`},
+			{MessageId: "m2", Body: `{"cluster":"cluster-b","topic":"config.reload"}`},
+		},
+		nil, nil, "", []string{}, 3, 0,
+	},
+	"broadcast to all clusters": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"*"}`},
+		},
+		nil, nil, "", []string{}, 4, 0,
+	},
+	"unknown cluster of list acknowledged after fan out": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"clusters":["missing","cluster-b"]}`},
+		},
+		nil, nil, "", []string{}, 2, 0,
+	},
+	"publish error fails message": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-b"}`},
+		},
+		errors.New("AccessDenied"), nil, "", []string{"m1"}, 0, 0,
+	},
+	"throttled listing fails message": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a"}`},
+		},
+		nil, &smithy.GenericAPIError{Code: "ThrottlingException"}, "dlq-url", []string{"m1"}, 0, 0,
+	},
+}
+
+func TestHandleRequest(t *testing.T) {
+	for name, tc := range handlerTests {
+		t.Run(name, func(t *testing.T) {
+			ecsClient, sqsClient := fake.NewECS(), fake.NewSQS()
+			ecsClient.AddService("cluster-a", "service-1", notifyLabels)
+			ecsClient.AddService("cluster-a", "service-2", notifyLabels)
+			ecsClient.AddService("cluster-a", "unsubscribed", map[string]string{})
+			ecsClient.AddService("cluster-b", "service-3", notifyLabels)
+			ecsClient.AddService("cluster-b", "config-service", map[string]string{
+				"NOTIFY_ME_CONTAINER_PORT": "8080",
+				"NOTIFY_ME_API_URI":        "/v1.0/notify",
+				"NOTIFY_ME_TOPICS":         "config.*",
+			})
+			sqsClient.Err = tc.sendErr
+			if tc.listErr != nil {
+				ecsClient.Errors["ListServices"] = tc.listErr
+			}
+			useFakeAWSService(t, ecsClient, sqsClient)
+			t.Setenv("SQS_QUEUE_URL", "queue-url")
+			t.Setenv("DEAD_LETTER_QUEUE_URL", tc.deadLetterQueue)
+
+			response, err := HandleRequest(context.TODO(), &events.SQSEvent{Records: tc.records})
+			if err != nil {
+				t.Fatalf("HandleRequest() error = %v", err)
+			}
+
+			failures := []string{}
+			for _, failure := range response.BatchItemFailures {
+				failures = append(failures, failure.ItemIdentifier)
+			}
+			if !reflect.DeepEqual(failures, tc.wantFailures) {
+				t.Errorf("BatchItemFailures = %v, want %v", failures, tc.wantFailures)
+			}
+			if len(sqsClient.Messages["queue-url"]) != tc.wantMessages {
+				t.Errorf("published messages = %d, want %d", len(sqsClient.Messages["queue-url"]), tc.wantMessages)
+			}
+			if len(sqsClient.Messages["dlq-url"]) != tc.wantDeadLetters {
+				t.Errorf("dead letter messages = %d, want %d", len(sqsClient.Messages["dlq-url"]), tc.wantDeadLetters)
+			}
+		})
+	}
+}
+
+func TestHandleRequestAssumesRole(t *testing.T) {
+	const workloadRoleArn = "arn:aws:iam::210987654321:role/ECSTaskNotifierWorkloadRole"
+	ecsClient, workloadECS, sqsClient := fake.NewECS(), fake.NewECS(), fake.NewSQS()
+	ecsClient.AddService("cluster-a", "service-1", notifyLabels)
+	workloadECS.AddService("workload-cluster", "workload-service", notifyLabels)
+
+	original := newAWSService
+	newAWSService = func(ctx context.Context) (*internal.AWSService, error) {
+		return internal.NewAWSService(ecsClient, sqsClient, fake.NewDynamoDB()).
+			WithRoleClients(func(roleArn string, region string) *internal.RoleClients {
+				return &internal.RoleClients{ECSClient: workloadECS}
+			}), nil
+	}
+	t.Cleanup(func() { newAWSService = original })
+	t.Setenv("SQS_QUEUE_URL", "queue-url")
+	t.Setenv("CLUSTER_ROLE_ARNS", "mapped-cluster="+workloadRoleArn)
+	workloadECS.AddService("mapped-cluster", "mapped-service", notifyLabels)
+
+	response, err := HandleRequest(context.TODO(), &events.SQSEvent{Records: []events.SQSMessage{
+		{MessageId: "m1", Body: `{"cluster":"workload-cluster","role_arn":"` + workloadRoleArn + `"}`},
+		{MessageId: "m2", Body: `{"cluster":"mapped-cluster"}`},
+		{MessageId: "m3", Body: `{"cluster":"cluster-a"}`},
+	}})
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("HandleRequest() = %v, %v", response, err)
+	}
+
+	roleArns := map[string]string{}
+	for _, message := range sqsClient.Messages["queue-url"] {
+		var serviceMessage internal.ServiceMessage
+		if err := json.Unmarshal([]byte(message), &serviceMessage); err != nil {
+			t.Fatal(err)
+		}
+		roleArns[serviceMessage.Service] = serviceMessage.RoleArn
+	}
+	want := map[string]string{"workload-service": workloadRoleArn, "mapped-service": workloadRoleArn, "service-1": ""}
+	if !reflect.DeepEqual(roleArns, want) {
+		t.Errorf("published role ARNs = %v, want %v", roleArns, want)
+	}
+}
+
+func TestHandleRequestRegions(t *testing.T) {
+	ecsClient, sqsClient := fake.NewECS(), fake.NewSQS()
+	westECS, westSQS := fake.NewECS(), fake.NewSQS()
+	ecsClient.AddService("cluster-a", "home-service", notifyLabels)
+	westECS.AddService("cluster-a", "west-service", notifyLabels)
+
+	original := newAWSService
+	newAWSService = func(ctx context.Context) (*internal.AWSService, error) {
+		return internal.NewAWSService(ecsClient, sqsClient, fake.NewDynamoDB()).
+			WithRoleClients(func(roleArn string, region string) *internal.RoleClients {
+				if region == "us-west-2" {
+					return &internal.RoleClients{ECSClient: westECS, SQSClient: westSQS}
+				}
+				return &internal.RoleClients{ECSClient: fake.NewECS(), SQSClient: fake.NewSQS()}
+			}), nil
+	}
+	t.Cleanup(func() { newAWSService = original })
+	t.Setenv("SQS_QUEUE_URL", "queue-url")
+	t.Setenv("REGION_SQS_QUEUE_URLS", "us-west-2=west-queue-url")
+
+	// Region without queue URL is a permanent error, not holding back the other regions
+	response, err := HandleRequest(context.TODO(), &events.SQSEvent{Records: []events.SQSMessage{
+		{MessageId: "m1", Body: `{"cluster":"cluster-a","regions":["us-west-2","eu-west-1"]}`},
+	}})
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("HandleRequest() = %v, %v", response, err)
+	}
+
+	if len(sqsClient.Messages["queue-url"]) != 0 || len(westSQS.Messages["west-queue-url"]) != 1 {
+		t.Fatalf("published messages = %v, west %v", sqsClient.Messages, westSQS.Messages)
+	}
+	var serviceMessage internal.ServiceMessage
+	if err := json.Unmarshal([]byte(westSQS.Messages["west-queue-url"][0]), &serviceMessage); err != nil {
+		t.Fatal(err)
+	}
+	if serviceMessage.Service != "west-service" || serviceMessage.Region != "us-west-2" {
+		t.Errorf("published service message = %+v, want west-service of us-west-2", serviceMessage)
+	}
+}
+
+func TestHandleRequestPayload(t *testing.T) {
+	ecsClient, sqsClient := fake.NewECS(), fake.NewSQS()
+	ecsClient.AddService("cluster-a", "service-1", notifyLabels)
+	useFakeAWSService(t, ecsClient, sqsClient)
+	t.Setenv("SQS_QUEUE_URL", "queue-url")
+
+	// Message without payload is delivered as published
+	records := []events.SQSMessage{
+		{MessageId: "m1", Body: `{"cluster":"cluster-a","payload":{"key":"app.yaml"}}`},
+		{MessageId: "m2", Body: `{"cluster":"cluster-a","source":"config","key":"app.yaml"}`},
+	}
+	response, err := HandleRequest(context.TODO(), &events.SQSEvent{Records: records})
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("HandleRequest() = %v, %v", response, err)
+	}
+
+	payloads := map[string]string{}
+	for _, message := range sqsClient.Messages["queue-url"] {
+		var serviceMessage internal.ServiceMessage
+		if err := json.Unmarshal([]byte(message), &serviceMessage); err != nil {
+			t.Fatal(err)
+		}
+		payloads[serviceMessage.NotificationId] = string(serviceMessage.Payload)
+	}
+	want := map[string]string{"m1": `{"key":"app.yaml"}`, "m2": records[1].Body}
+	if !reflect.DeepEqual(payloads, want) {
+		t.Errorf("published payloads = %v, want %v", payloads, want)
+	}
+}
+
+// Transient failure of one cluster re-enqueues that cluster only, the message is acknowledged
+func TestHandleRequestRequeuesFailedClusters(t *testing.T) {
+	ecsClient, sqsClient := fake.NewECS(), fake.NewSQS()
+	ecsClient.AddService("cluster-a", "service-1", notifyLabels)
+	ecsClient.AddService("cluster-b", "service-2", notifyLabels)
+	ecsClient.ClusterErrors["cluster-a"] = &smithy.GenericAPIError{Code: "ThrottlingException"}
+	useFakeAWSService(t, ecsClient, sqsClient)
+	t.Setenv("SQS_QUEUE_URL", "queue-url")
+	t.Setenv("NOTIFICATION_QUEUE_URL", "notification-queue-url")
+
+	record := events.SQSMessage{MessageId: "m1", Body: `{"clusters":["cluster-a","cluster-b"],"topic":"cache.flush"}`}
+	response, err := HandleRequest(context.TODO(), &events.SQSEvent{Records: []events.SQSMessage{record}})
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("HandleRequest() = %v, %v", response, err)
+	}
+	if len(sqsClient.Messages["queue-url"]) != 1 {
+		t.Errorf("published messages = %d, want 1", len(sqsClient.Messages["queue-url"]))
+	}
+
+	requeued := sqsClient.Messages["notification-queue-url"]
+	if len(requeued) != 1 {
+		t.Fatalf("re-enqueued messages = %d, want 1", len(requeued))
+	}
+	var ecsNotify internal.EcsNotify
+	if err := json.Unmarshal([]byte(requeued[0]), &ecsNotify); err != nil {
+		t.Fatal(err)
+	}
+	// Payload of the original message is delivered to the failed cluster
+	want := internal.EcsNotify{NotificationId: "m1", Clusters: []string{"cluster-a"}, Topic: "cache.flush", Payload: json.RawMessage(record.Body)}
+	if !reflect.DeepEqual(ecsNotify, want) {
+		t.Errorf("re-enqueued message = %+v, want %+v", ecsNotify, want)
+	}
+
+	// Once re-enqueues are exhausted the message is retried
+	record.MessageAttributes = map[string]events.SQSMessageAttribute{
+		internal.RequeueCountAttribute: {StringValue: aws.String(strconv.Itoa(internal.MaxRequeues)), DataType: "Number"},
+	}
+	response, err = HandleRequest(context.TODO(), &events.SQSEvent{Records: []events.SQSMessage{record}})
+	if err != nil || len(response.BatchItemFailures) != 1 {
+		t.Errorf("exhausted re-enqueues BatchItemFailures = %v, error = %v", response.BatchItemFailures, err)
+	}
+}
+
+var requeueMessagesTests = map[string]struct {
+	targets []*failedTarget
+	want    []*internal.EcsNotify
+}{
+	"region": {
+		[]*failedTarget{{region: "us-west-2"}},
+		[]*internal.EcsNotify{{NotificationId: "n1", Cluster: "*", ClusterTags: map[string]string{"env": "prod"}, Regions: []string{"us-west-2"}}},
+	},
+	"clusters of a region": {
+		[]*failedTarget{{region: "us-west-2", cluster: "c1"}, {cluster: "c2"}, {region: "us-west-2", cluster: "c3"}},
+		[]*internal.EcsNotify{
+			{NotificationId: "n1", Clusters: []string{"c1", "c3"}, Regions: []string{"us-west-2"}},
+			{NotificationId: "n1", Clusters: []string{"c2"}},
+		},
+	},
+	"services of a cluster": {
+		[]*failedTarget{{cluster: "c1", services: []string{"s2", "s3"}}},
+		[]*internal.EcsNotify{{NotificationId: "n1", Cluster: "c1", Selector: &internal.ServiceSelector{Services: []string{"s2", "s3"}}}},
+	},
+}
+
+func TestRequeueMessages(t *testing.T) {
+	ecsNotifyMessage := &internal.EcsNotify{Cluster: "*", ClusterTags: map[string]string{"env": "prod"}, Regions: []string{"us-west-2", "eu-west-1"}}
+	for name, tc := range requeueMessagesTests {
+		t.Run(name, func(t *testing.T) {
+			actual := requeueMessages(ecsNotifyMessage, "n1", tc.targets)
+			if !reflect.DeepEqual(actual, tc.want) {
+				t.Errorf("requeueMessages() = %v, want %v", actual, tc.want)
+			}
+		})
+	}
+}
 
-	err := HandleRequest(ctx, nil)
+func TestHandleRecordAuditsInvalidMessage(t *testing.T) {
+	dynamodbClient := fake.NewDynamoDB()
+	awsService := internal.NewAWSService(fake.NewECS(), fake.NewSQS(), dynamodbClient).WithAuditTable("audit-table")
 
-	if err != nil {
-		log.Fatal(err)
+	record := events.SQSMessage{MessageId: "m1", Body: `{"cluster":`}
+	if err := handleRecord(context.TODO(), awsService, "queue-url", nil, "", record); !failure.IsPermanent(err) {
+		t.Fatalf("handleRecord() error = %v, want permanent error", err)
+	}
+
+	if len(dynamodbClient.Items) != 1 {
+		t.Fatalf("audit records = %d, want 1", len(dynamodbClient.Items))
+	}
+	var auditRecord internal.AuditRecord
+	if err := attributevalue.UnmarshalMap(dynamodbClient.Items[0], &auditRecord); err != nil {
+		t.Fatal(err)
+	}
+	if auditRecord.NotificationId != record.MessageId || auditRecord.Stage != internal.AuditStageServiceDiscovery ||
+		!auditRecord.Permanent || auditRecord.Error == "" {
+		t.Errorf("audit record = %+v, want permanent error audited by message Id", auditRecord)
 	}
 }
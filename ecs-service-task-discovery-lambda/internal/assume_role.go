@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/assumerole"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/cache"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// AWS clients of a workload account role in a region, calling with credentials of
+// the assumed role. Clients of the notifier account without role
+type RoleClients struct {
+	ECSClient ECSClient
+	EC2Client EC2Client
+}
+
+// Build AWS clients of the workload account role in the region, the default region
+// of the config without region. Fakes are injected in tests
+type RoleClientsFactory func(roleArn string, region string) *RoleClients
+
+// Clients by role ARN and region, kept across warm invocations
+var roleClientsCache = cache.New[assumerole.Key, *RoleClients](0)
+
+// Role clients factory assuming roles with the STS client of the config,
+// clients are built once per role and region
+func newRoleClientsFactory(cfg aws.Config) RoleClientsFactory {
+	return assumerole.NewFactory(cfg, roleClientsCache, func(roleCfg aws.Config) *RoleClients {
+		return &RoleClients{ECSClient: ecs.NewFromConfig(roleCfg), EC2Client: ec2.NewFromConfig(roleCfg)}
+	})
+}
+
+// Factory of role and region clients, cross-account and cross-region notifications fail without factory
+func (awsService *AWSService) WithRoleClients(roleClients RoleClientsFactory) *AWSService {
+	awsService.roleClients = roleClients
+	return awsService
+}
+
+// AWS Service calling ECS and EC2 with credentials of the assumed workload account role,
+// the service itself without role. SQS and DynamoDB clients stay in the notifier account.
+// Invalid role ARNs fail every retry, reported as permanent error
+func (awsService *AWSService) ForRole(roleArn string) (*AWSService, error) {
+	if roleArn == "" {
+		return awsService, nil
+	}
+	if err := assumerole.ValidateRoleArn(roleArn); err != nil {
+		return nil, failure.Permanent(err)
+	}
+	if awsService.roleClients == nil {
+		return nil, failure.Permanent(fmt.Errorf("cross-account role %s not supported", roleArn))
+	}
+
+	// ECS and EC2 of the region of the service e.g. the region of the service message
+	clients := awsService.roleClients(roleArn, awsService.region)
+	roleService := *awsService
+	roleService.roleArn = roleArn
+	roleService.ecsClient = clients.ECSClient
+	roleService.ec2Client = clients.EC2Client
+	return &roleService, nil
+}
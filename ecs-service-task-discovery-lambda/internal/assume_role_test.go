@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+const workloadRoleArn = "arn:aws:iam::210987654321:role/ECSTaskNotifierWorkloadRole"
+
+var forRoleTests = map[string]struct {
+	roleArn      string
+	noFactory    bool
+	wantWorkload bool
+	wantErr      bool
+}{
+	"no role":         {"", false, false, false},
+	"workload role":   {workloadRoleArn, false, true, false},
+	"invalid arn":     {"ECSTaskNotifierWorkloadRole", false, false, true},
+	"not a role":      {"arn:aws:iam::210987654321:user/notifier", false, false, true},
+	"without factory": {workloadRoleArn, true, false, true},
+}
+
+func TestForRole(t *testing.T) {
+	for name, tc := range forRoleTests {
+		t.Run(name, func(t *testing.T) {
+			ecsClient, ec2Client := newFakeCluster()
+			awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB())
+
+			// Workload account cluster hosting a bridge task
+			workloadECS, workloadEC2 := fake.NewECS(), fake.NewEC2()
+			workloadECS.AddContainerInstance("workload-cluster", "ci-w", "i-w")
+			workloadEC2.Instances["i-w"] = "10.1.0.1"
+			workloadECS.AddTask("workload-cluster", "svc-w", fake.BridgeTask("task-w", "ci-w", 8080, 32768, types.HealthStatusHealthy))
+			if !tc.noFactory {
+				awsService = awsService.WithRoleClients(func(roleArn string, region string) *RoleClients {
+					return &RoleClients{ECSClient: workloadECS, EC2Client: workloadEC2}
+				})
+			}
+
+			roleService, err := awsService.ForRole(tc.roleArn)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ForRole() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if !failure.IsPermanent(err) {
+					t.Errorf("ForRole() error = %v, want permanent error", err)
+				}
+				return
+			}
+
+			serviceMessage := &ServiceMessage{Cluster: "workload-cluster", Service: "svc-w", NotifyMeContainerPort: "8080"}
+			actual, err := roleService.DiscoverServiceTasks(context.TODO(), serviceMessage)
+			discovered := err == nil && len(actual) == 1 && actual[0].NotifyMeHostAddress == "10.1.0.1"
+			if discovered != tc.wantWorkload {
+				t.Errorf("DiscoverServiceTasks() = %v, %v, want workload tasks %v", actual, err, tc.wantWorkload)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const (
+	// Notification stage audited by this Lambda
+	AuditStageTaskDiscovery = "TASK_DISCOVERY"
+
+	// Audit records expire from the DynamoDB table after retention period
+	auditRecordRetention = 30 * 24 * time.Hour
+)
+
+// Audit record of the ECS service task discovery stage of a notification
+type AuditRecord struct {
+	NotificationId string   `dynamodbav:"notification_id"`
+	AuditKey       string   `dynamodbav:"audit_key"`
+	Stage          string   `dynamodbav:"stage"`
+	RequestId      string   `dynamodbav:"request_id"`
+	Region         string   `dynamodbav:"region,omitempty"`
+	Cluster        string   `dynamodbav:"cluster"`
+	Service        string   `dynamodbav:"service"`
+	RoleArn        string   `dynamodbav:"role_arn,omitempty"`
+	Tasks          []string `dynamodbav:"tasks,omitempty,stringset"`
+	TaskCount      int      `dynamodbav:"task_count"`
+	RequeueCount   int      `dynamodbav:"requeue_count,omitempty"`
+	Error          string   `dynamodbav:"error,omitempty"`
+	Permanent      bool     `dynamodbav:"permanent,omitempty"`
+	CreatedAt      string   `dynamodbav:"created_at"`
+	ExpiresAt      int64    `dynamodbav:"expires_at"`
+}
+
+// Audit record of a message before unmarshalling, audited by SQS message Id
+// as the notification Id of an invalid message is unknown
+func NewMessageAuditRecord(messageId string) *AuditRecord {
+	now := time.Now().UTC()
+	return &AuditRecord{
+		NotificationId: messageId,
+		AuditKey:       AuditStageTaskDiscovery,
+		Stage:          AuditStageTaskDiscovery,
+		CreatedAt:      now.Format(time.RFC3339Nano),
+		ExpiresAt:      now.Add(auditRecordRetention).Unix(),
+	}
+}
+
+// Audit record of the service message, tasks are discovered in the region. Services
+// of the same name in several regions or workload accounts are audited apart
+func NewAuditRecord(serviceMessage *ServiceMessage, region string) *AuditRecord {
+	auditKey := strings.Join([]string{AuditStageTaskDiscovery, region, roleAccountId(serviceMessage.RoleArn),
+		serviceMessage.Cluster, serviceMessage.Service}, "#")
+
+	now := time.Now().UTC()
+	return &AuditRecord{
+		NotificationId: serviceMessage.NotificationId,
+		AuditKey:       auditKey,
+		Stage:          AuditStageTaskDiscovery,
+		Region:         region,
+		Cluster:        serviceMessage.Cluster,
+		Service:        serviceMessage.Service,
+		RoleArn:        serviceMessage.RoleArn,
+		CreatedAt:      now.Format(time.RFC3339Nano),
+		ExpiresAt:      now.Add(auditRecordRetention).Unix(),
+	}
+}
+
+// Record re-enqueues of the service message, audited apart from the first attempt
+// of the service message e.g. TASK_DISCOVERY#us-east-1##cluster#service#1
+func (auditRecord *AuditRecord) WithRequeueCount(requeueCount int) *AuditRecord {
+	auditRecord.RequeueCount = requeueCount
+	if requeueCount > 0 {
+		auditRecord.AuditKey += "#" + strconv.Itoa(requeueCount)
+	}
+	return auditRecord
+}
+
+// Account Id of the workload account role, empty in the notifier account without role.
+// Invalid role ARNs are kept as is
+func roleAccountId(roleArn string) string {
+	parsed, err := arn.Parse(roleArn)
+	if err != nil {
+		return roleArn
+	}
+	return parsed.AccountID
+}
+
+// Record discovered ECS tasks to notify
+func (auditRecord *AuditRecord) WithTasks(taskNotifyMessages []*TaskNotifyMessage) *AuditRecord {
+	auditRecord.Tasks = nil
+	for _, taskNotifyMessage := range taskNotifyMessages {
+		// Endpoints of the same task e.g. IPv4 and IPv6 bindings, string sets hold distinct values
+		if !slices.Contains(auditRecord.Tasks, taskNotifyMessage.NotifyTaskArn) {
+			auditRecord.Tasks = append(auditRecord.Tasks, taskNotifyMessage.NotifyTaskArn)
+		}
+	}
+	auditRecord.TaskCount = len(auditRecord.Tasks)
+	return auditRecord
+}
+
+// Audit table name, auditing is disabled without table name
+func (awsService *AWSService) WithAuditTable(tableName string) *AWSService {
+	awsService.auditTableName = tableName
+	return awsService
+}
+
+// Persist audit record to DynamoDB, failure to audit does not fail the notification
+func (awsService *AWSService) PutAuditRecord(ctx context.Context, auditRecord *AuditRecord) {
+	if awsService.auditTableName == "" || auditRecord.NotificationId == "" {
+		return
+	}
+
+	requestId := RequestIdFromContext(ctx)
+	auditRecord.RequestId = requestId
+
+	item, err := attributevalue.MarshalMap(auditRecord)
+	if err != nil {
+		slog.Error("failed to marshal audit record", "requestId", requestId, "errorMessage", err)
+		return
+	}
+
+	_, err = awsService.dynamodbClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(awsService.auditTableName),
+		Item:      item,
+	})
+	if err != nil {
+		slog.Error("failed to put audit record", "requestId", requestId, "notificationId", auditRecord.NotificationId, "errorMessage", err)
+	}
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/dynamodblocal"
+)
+
+func TestAuditRecordWithTasks(t *testing.T) {
+	serviceMessage := &ServiceMessage{NotificationId: "notification-1", Cluster: "ecs_cluster_name", Service: "svc-a"}
+	auditRecord := NewAuditRecord(serviceMessage, "us-east-1").
+		WithTasks([]*TaskNotifyMessage{{NotifyTaskArn: "task-1"}, {NotifyTaskArn: "task-2"}, {NotifyTaskArn: "task-2"}})
+
+	if !reflect.DeepEqual(auditRecord.Tasks, []string{"task-1", "task-2"}) || auditRecord.TaskCount != 2 {
+		t.Errorf("tasks = %v, count = %d", auditRecord.Tasks, auditRecord.TaskCount)
+	}
+	if auditRecord.AuditKey != "TASK_DISCOVERY#us-east-1##ecs_cluster_name#svc-a" || auditRecord.ExpiresAt == 0 {
+		t.Errorf("unexpected audit record %+v", auditRecord)
+	}
+}
+
+func TestAuditKeyOfRegionAndRole(t *testing.T) {
+	workloadRoleArn := "arn:aws:iam::210987654321:role/ECSTaskNotifierWorkloadRole"
+	serviceMessages := map[string]*ServiceMessage{
+		"TASK_DISCOVERY#us-east-1##ecs_cluster_name#svc-a":             {Cluster: "ecs_cluster_name", Service: "svc-a"},
+		"TASK_DISCOVERY#us-west-2##ecs_cluster_name#svc-a":             {Cluster: "ecs_cluster_name", Service: "svc-a", Region: "us-west-2"},
+		"TASK_DISCOVERY#us-east-1#210987654321#ecs_cluster_name#svc-a": {Cluster: "ecs_cluster_name", Service: "svc-a", RoleArn: workloadRoleArn},
+	}
+
+	for want, serviceMessage := range serviceMessages {
+		auditRecord := NewAuditRecord(serviceMessage, cmp.Or(serviceMessage.Region, "us-east-1"))
+		if auditRecord.AuditKey != want {
+			t.Errorf("AuditKey = %q, want %q", auditRecord.AuditKey, want)
+		}
+	}
+}
+
+func TestAuditRecordWithRequeueCount(t *testing.T) {
+	serviceMessage := &ServiceMessage{NotificationId: "notification-1", Cluster: "ecs_cluster_name", Service: "svc-a"}
+
+	if auditKey := NewAuditRecord(serviceMessage, "us-east-1").WithRequeueCount(0).AuditKey; auditKey != "TASK_DISCOVERY#us-east-1##ecs_cluster_name#svc-a" {
+		t.Errorf("AuditKey = %q, want key of the first attempt", auditKey)
+	}
+	auditRecord := NewAuditRecord(serviceMessage, "us-east-1").WithRequeueCount(2)
+	if auditRecord.AuditKey != "TASK_DISCOVERY#us-east-1##ecs_cluster_name#svc-a#2" || auditRecord.RequeueCount != 2 {
+		t.Errorf("unexpected audit record %+v", auditRecord)
+	}
+}
+
+var putAuditRecordTests = map[string]struct {
+	tableName      string
+	notificationId string
+	putErr         error
+	wantItems      int
+}{
+	"audit disabled":          {"", "notification-1", nil, 0},
+	"audit record put":        {"audit-table", "notification-1", nil, 1},
+	"missing notification id": {"audit-table", "", nil, 0},
+	"put failure tolerated":   {"audit-table", "notification-1", errors.New("ProvisionedThroughputExceeded"), 0},
+}
+
+func TestPutAuditRecord(t *testing.T) {
+	for name, tc := range putAuditRecordTests {
+		t.Run(name, func(t *testing.T) {
+			dynamodbClient := fake.NewDynamoDB()
+			dynamodbClient.Err = tc.putErr
+			awsService := NewAWSService(fake.NewECS(), fake.NewEC2(), fake.NewSQS(), dynamodbClient).WithAuditTable(tc.tableName)
+
+			serviceMessage := &ServiceMessage{NotificationId: tc.notificationId, Cluster: "ecs_cluster_name", Service: "svc-a"}
+			awsService.PutAuditRecord(context.TODO(), NewAuditRecord(serviceMessage, "us-east-1"))
+			if len(dynamodbClient.Items) != tc.wantItems {
+				t.Errorf("items = %d, want %d", len(dynamodbClient.Items), tc.wantItems)
+			}
+		})
+	}
+}
+
+// Runs against DynamoDB Local, see package dynamodblocal
+func TestPutAuditRecordDynamoDBLocal(t *testing.T) {
+	ctx := context.TODO()
+	dynamodbClient := dynamodblocal.NewAuditTable(t)
+	awsService := NewAWSService(nil, nil, nil, dynamodbClient).WithAuditTable(dynamodblocal.AuditTableName)
+
+	serviceMessage := &ServiceMessage{NotificationId: "notification-1", Cluster: "ecs_cluster_name", Service: "svc-a"}
+	auditRecord := NewAuditRecord(serviceMessage, "us-east-1").
+		WithTasks([]*TaskNotifyMessage{{NotifyTaskArn: "task-1"}})
+	awsService.PutAuditRecord(ctx, auditRecord)
+
+	var actual AuditRecord
+	dynamodblocal.GetAuditRecord(t, dynamodbClient, "notification-1", auditRecord.AuditKey, &actual)
+	if actual.Service != "svc-a" || actual.TaskCount != 1 {
+		t.Errorf("unexpected audit record %+v", actual)
+	}
+}
@@ -4,17 +4,26 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"log/slog"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/cache"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/describe"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
 )
 
 // Algorithm
@@ -25,6 +34,7 @@ import (
 // Match containerPort with InputPort
 // Get Host - Private IP Address
 // Get Host Port Address
+// awsvpc network mode - Task ENI Private IP Address and containerPort
 
 // Protocol - Private IP address - Host Port - Notify URI
 // http://private-ip-address:hostport/notify-uri
@@ -39,132 +49,339 @@ func RequestIdFromContext(ctx context.Context) string {
 }
 
 type AWSService struct {
-	ecsClient *ecs.Client
-	ec2Client *ec2.Client
-	sqsClient *sqs.Client
+	ecsClient      ECSClient
+	ec2Client      EC2Client
+	sqsClient      SQSClient
+	dynamodbClient DynamoDBClient
+	launchTypes    map[types.LaunchType]bool
+	auditTableName string
+	roleClients    RoleClientsFactory
+	roleArn        string
+	region         string
 }
 
-func NewAWSService(ctx context.Context) (*AWSService, error) {
-	requestId := RequestIdFromContext(ctx)
+// Build AWS Service on top of given clients, fakes are injected in tests
+func NewAWSService(ecsClient ECSClient, ec2Client EC2Client, sqsClient SQSClient, dynamodbClient DynamoDBClient) *AWSService {
 	awsService := &AWSService{}
 
+	return awsService.WithLaunchTypes(DefaultLaunchTypes).
+		withEcsClient(ecsClient).
+		withEc2Client(ec2Client).
+		withSQSClient(sqsClient).
+		withDynamoDBClient(dynamodbClient)
+}
+
+// Build AWS Service with clients of AWS default config
+func NewAWSServiceFromConfig(ctx context.Context) (*AWSService, error) {
+	requestId := RequestIdFromContext(ctx)
+
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		slog.Error("Failed to load default config", "requestId", requestId, "errorMessage", err)
 		return nil, err
 	}
 
-	awsService = awsService.withEcsClient(cfg).
-		withEc2Client(cfg).
-		withSQSClient(cfg)
-
-	return awsService, nil
+	return NewAWSService(ecs.NewFromConfig(cfg), ec2.NewFromConfig(cfg),
+		sqs.NewFromConfig(cfg), dynamodb.NewFromConfig(cfg)).
+		withRegion(cfg.Region).
+		WithRoleClients(newRoleClientsFactory(cfg)), nil
 }
 
-func (awsService *AWSService) withEcsClient(cfg aws.Config) *AWSService {
-	ecsClient := ecs.NewFromConfig(cfg)
+func (awsService *AWSService) withEcsClient(ecsClient ECSClient) *AWSService {
 	awsService.ecsClient = ecsClient
 	return awsService
 }
 
-func (awsService *AWSService) withEc2Client(cfg aws.Config) *AWSService {
-	ec2Client := ec2.NewFromConfig(cfg)
+func (awsService *AWSService) withRegion(region string) *AWSService {
+	awsService.region = region
+	return awsService
+}
+
+func (awsService *AWSService) withEc2Client(ec2Client EC2Client) *AWSService {
 	awsService.ec2Client = ec2Client
 	return awsService
 }
 
-func (awsService *AWSService) withSQSClient(cfg aws.Config) *AWSService {
-	sqsClient := sqs.NewFromConfig(cfg)
+func (awsService *AWSService) withSQSClient(sqsClient SQSClient) *AWSService {
 	awsService.sqsClient = sqsClient
 	return awsService
 }
 
-// Get EC2 Instance Proviate IP Address
-func (awsService *AWSService) ec2PrivateAddress(ctx context.Context, instanceId string) (*string, error) {
+// SQS client of the service, e.g. to route messages to the dead letter queue
+func (awsService *AWSService) SQSClient() SQSClient {
+	return awsService.sqsClient
+}
 
-	requestId := RequestIdFromContext(ctx)
-	instances, err := awsService.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceId},
-	})
-	if err != nil {
-		slog.Error("failed to describe ec2 instances details", "requestId", requestId, "errorMessage", err)
-		return nil, err
+func (awsService *AWSService) withDynamoDBClient(dynamodbClient DynamoDBClient) *AWSService {
+	awsService.dynamodbClient = dynamodbClient
+	return awsService
+}
+
+// Launch types of tasks to discover, unless configured otherwise. Tasks of other
+// launch types e.g. EXTERNAL have no private address to notify
+var DefaultLaunchTypes = []types.LaunchType{types.LaunchTypeEc2, types.LaunchTypeFargate}
+
+// Parse comma separated list of launch types e.g. EC2,FARGATE, rejecting launch types
+// other than the supported EC2 and FARGATE
+func ParseLaunchTypes(value string) ([]types.LaunchType, error) {
+	var launchTypes []types.LaunchType
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		launchType := types.LaunchType(item)
+		if !isSupportedLaunchType(launchType) {
+			return nil, fmt.Errorf("unsupported launch type: %v, expected EC2 or FARGATE", item)
+		}
+		launchTypes = append(launchTypes, launchType)
 	}
 
-	var privateIPs []string
-	for _, reservation := range instances.Reservations {
-		for _, instance := range reservation.Instances {
-			// Assumed simple networking with only one private IP address
-			// Changes might required as per netwroking
-			for _, networkInterface := range instance.NetworkInterfaces {
-				privateIPs = append(privateIPs, aws.ToString(networkInterface.PrivateIpAddress))
-			}
+	if len(launchTypes) == 0 {
+		return nil, errors.New("no launch type configured")
+	}
+	return launchTypes, nil
+}
+
+func isSupportedLaunchType(launchType types.LaunchType) bool {
+	for _, supported := range DefaultLaunchTypes {
+		if launchType == supported {
+			return true
 		}
 	}
+	return false
+}
 
-	if len(privateIPs) == 0 {
-		return nil, errors.New("private ip address not found")
+// Launch types of tasks to discover
+func (awsService *AWSService) WithLaunchTypes(launchTypes []types.LaunchType) *AWSService {
+	awsService.launchTypes = make(map[types.LaunchType]bool)
+	for _, launchType := range launchTypes {
+		awsService.launchTypes[launchType] = true
 	}
-	return &privateIPs[0], nil
+	return awsService
 }
 
-// Get List of Container Instances and its Private IP Addresses
-func (awsService *AWSService) listContainerInstances(ctx context.Context, cluster string) (map[string]string, error) {
+// Launch type of a task, tasks launched through a capacity provider
+// strategy might not report launch type. Tasks of neither a Fargate capacity
+// provider nor a container instance have no known launch type and are not discovered
+func taskLaunchType(task types.Task) types.LaunchType {
+	if task.LaunchType != "" {
+		return task.LaunchType
+	}
 
-	requestId := RequestIdFromContext(ctx)
-	listContainerInstancesInput := &ecs.ListContainerInstancesInput{
-		Cluster: aws.String(cluster),
+	capacityProvider := aws.ToString(task.CapacityProviderName)
+	if capacityProvider == "FARGATE" || capacityProvider == "FARGATE_SPOT" {
+		return types.LaunchTypeFargate
 	}
-	containerInstanceIpAddresses := make(map[string]string)
+	if aws.ToString(task.ContainerInstanceArn) != "" {
+		// Auto Scaling group capacity provider
+		return types.LaunchTypeEc2
+	}
+	return ""
+}
 
-	paginator := ecs.NewListContainerInstancesPaginator(awsService.ecsClient, listContainerInstancesInput)
-	for paginator.HasMorePages() {
-		containerInstances, ciErr := paginator.NextPage(ctx)
-		if ciErr != nil {
-			slog.Error("failed to paginate list of container instances", "requestId", requestId, "errorMessage", ciErr)
-			return nil, ciErr
-		}
+// Limits of batched describe requests, DescribeTasks and DescribeContainerInstances
+// accept up to 100 ARNs and an EC2 filter up to 200 values
+const (
+	describeTasksBatchSize              = 100
+	describeContainerInstancesBatchSize = 100
+	describeInstancesBatchSize          = 200
+)
+
+// Get Private IP Addresses of EC2 instances by instance Id, batched into one
+// DescribeInstances call per chunk. The instance-id filter skips terminated
+// instances instead of failing the whole batch
+func (awsService *AWSService) ec2PrivateAddresses(ctx context.Context, instanceIds []string) (map[string]string, error) {
 
-		containerInstanceDetails, cidErr := awsService.ecsClient.DescribeContainerInstances(ctx, &ecs.DescribeContainerInstancesInput{
-			Cluster:            aws.String(cluster),
-			ContainerInstances: containerInstances.ContainerInstanceArns,
+	requestId := RequestIdFromContext(ctx)
+	privateAddresses := make(map[string]string)
+	for _, batch := range describe.Chunks(instanceIds, describeInstancesBatchSize) {
+		paginator := ec2.NewDescribeInstancesPaginator(awsService.ec2Client, &ec2.DescribeInstancesInput{
+			Filters: []ec2types.Filter{{Name: aws.String("instance-id"), Values: batch}},
 		})
-		if cidErr != nil {
-			slog.Error("failed to describe container instance details", "requestId", requestId, "errorMessage", cidErr)
-			return nil, cidErr
+		for paginator.HasMorePages() {
+			instances, err := paginator.NextPage(ctx)
+			if err != nil {
+				slog.Error("failed to describe ec2 instances details", "requestId", requestId, "errorMessage", err)
+				return nil, err
+			}
+
+			for _, reservation := range instances.Reservations {
+				for _, instance := range reservation.Instances {
+					// Assumed simple networking with only one private IP address
+					// Changes might required as per netwroking
+					for _, networkInterface := range instance.NetworkInterfaces {
+						if privateAddress := aws.ToString(networkInterface.PrivateIpAddress); privateAddress != "" {
+							privateAddresses[aws.ToString(instance.InstanceId)] = privateAddress
+							break
+						}
+					}
+				}
+			}
 		}
+	}
+	return privateAddresses, nil
+}
+
+// Container instance private IP addresses are re-resolved after TTL
+const containerInstanceAddressTTL = 10 * time.Minute
+
+type containerInstanceKey struct {
+	cluster              string
+	containerInstanceArn string
+}
+
+// Container instance private IP addresses, cached across warm invocations
+var containerInstanceAddressCache = cache.New[containerInstanceKey, string](containerInstanceAddressTTL)
 
-		for _, instance := range containerInstanceDetails.ContainerInstances {
-			// TODO Explore way of getting direct private IP Address
-			privateAddress, err := awsService.ec2PrivateAddress(ctx, *instance.Ec2InstanceId)
+// Container instance address cache hits and misses since the last call
+func ContainerInstanceCacheStats() cache.Stats {
+	return containerInstanceAddressCache.TakeStats()
+}
+
+// Get Private IP Addresses of the given container instances by container instance ARN,
+// uncached container instances are described in batches and their EC2 instances resolved at once
+func (awsService *AWSService) containerInstanceAddresses(ctx context.Context, cluster string, containerInstanceArns []string) (map[string]string, error) {
+
+	requestId := RequestIdFromContext(ctx)
+	containerInstanceIpAddresses := make(map[string]string)
+	var uncachedArns []string
+	for _, containerInstanceArn := range containerInstanceArns {
+		if privateAddress, ok := containerInstanceAddressCache.Get(containerInstanceKey{cluster, containerInstanceArn}); ok {
+			containerInstanceIpAddresses[containerInstanceArn] = privateAddress
+		} else {
+			uncachedArns = append(uncachedArns, containerInstanceArn)
+		}
+	}
+	if len(uncachedArns) == 0 {
+		return containerInstanceIpAddresses, nil
+	}
+
+	containerInstances, cidErr := describe.InChunks(ctx, uncachedArns, describeContainerInstancesBatchSize,
+		func(ctx context.Context, chunk []string) ([]types.ContainerInstance, error) {
+			containerInstanceDetails, err := awsService.ecsClient.DescribeContainerInstances(ctx, &ecs.DescribeContainerInstancesInput{
+				Cluster:            aws.String(cluster),
+				ContainerInstances: chunk,
+			})
 			if err != nil {
-				slog.Error("failed to get private IP address", "requestId", requestId, "errorMessage", err)
-				// Continue with other container instances
-				// TODO - Pending Error Handling on Missing Private IP Address
-				// return nil, err
-			} else {
-				slog.Info("Received privateAddress", "requestId", requestId, "ipAddress", *privateAddress)
-				containerInstanceIpAddresses[*instance.ContainerInstanceArn] = *privateAddress
+				return nil, err
 			}
+			return containerInstanceDetails.ContainerInstances,
+				describe.FailuresErr(RequestIdFromContext(ctx), "DescribeContainerInstances", containerInstanceDetails.Failures)
+		})
+	if cidErr != nil {
+		slog.Error("failed to describe container instance details", "requestId", requestId, "errorMessage", cidErr)
+		return nil, cidErr
+	}
+
+	// EC2 instance Id by container instance ARN
+	ec2InstanceIds := make(map[string]string)
+	var instanceIds []string
+	for _, instance := range containerInstances {
+		if instance.Ec2InstanceId == nil {
+			// External instances are not backed by EC2
+			continue
+		}
+		ec2InstanceIds[aws.ToString(instance.ContainerInstanceArn)] = *instance.Ec2InstanceId
+		instanceIds = append(instanceIds, *instance.Ec2InstanceId)
+	}
+
+	privateAddresses, err := awsService.ec2PrivateAddresses(ctx, instanceIds)
+	if err != nil {
+		return nil, err
+	}
+	for containerInstanceArn, instanceId := range ec2InstanceIds {
+		privateAddress, ok := privateAddresses[instanceId]
+		if !ok {
+			// Continue with other container instances
+			slog.Error("failed to get private IP address", "requestId", requestId, "instanceId", instanceId)
+			continue
 		}
+		containerInstanceIpAddresses[containerInstanceArn] = privateAddress
+		containerInstanceAddressCache.Put(containerInstanceKey{cluster, containerInstanceArn}, privateAddress)
 	}
 	return containerInstanceIpAddresses, nil
 }
 
+// Distinct container instances hosting bridge or host network mode tasks,
+// awsvpc tasks are reached through their ENI
+func hostContainerInstanceArns(tasks []types.Task) []string {
+	seen := make(map[string]bool)
+	var containerInstanceArns []string
+	for _, task := range tasks {
+		containerInstanceArn := aws.ToString(task.ContainerInstanceArn)
+		if _, isAwsvpc := taskENIPrivateAddress(task); isAwsvpc || containerInstanceArn == "" || seen[containerInstanceArn] {
+			continue
+		}
+		seen[containerInstanceArn] = true
+		containerInstanceArns = append(containerInstanceArns, containerInstanceArn)
+	}
+	return containerInstanceArns
+}
+
+// Address and port on which a task serves the Notify API
+type taskEndpoint struct {
+	address string
+	port    int32
+}
+
+// Get Private IP Address of the task ENI, present only in awsvpc network mode
+func taskENIPrivateAddress(task types.Task) (string, bool) {
+	for _, attachment := range task.Attachments {
+		if aws.ToString(attachment.Type) != "ElasticNetworkInterface" ||
+			aws.ToString(attachment.Status) != "ATTACHED" {
+			continue
+		}
+		for _, detail := range attachment.Details {
+			if aws.ToString(detail.Name) == "privateIPv4Address" && aws.ToString(detail.Value) != "" {
+				return aws.ToString(detail.Value), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Get Notify API endpoints of a running task
+// awsvpc - task ENI private IP address and container port
+// bridge/host - container instance private IP address and host port
+func taskEndpoints(task types.Task, containerPort int32, ciIPAddresses map[string]string) []taskEndpoint {
+	var endpoints []taskEndpoint
+
+	eniAddress, isAwsvpc := taskENIPrivateAddress(task)
+	for _, container := range task.Containers {
+		if container.HealthStatus != types.HealthStatusHealthy ||
+			aws.ToString(container.LastStatus) != string(types.DesiredStatusRunning) {
+			continue
+		}
+
+		if isAwsvpc {
+			// All containers share the task ENI, notify the task once
+			return append(endpoints, taskEndpoint{address: eniAddress, port: containerPort})
+		}
+
+		// Iterate over containers matching containerPort
+		// Extract HostPort
+		for _, networkBinding := range container.NetworkBindings {
+			if aws.ToInt32(networkBinding.ContainerPort) == containerPort {
+				if ipAddress, ok := ciIPAddresses[aws.ToString(task.ContainerInstanceArn)]; ok {
+					endpoints = append(endpoints, taskEndpoint{address: ipAddress, port: aws.ToInt32(networkBinding.HostPort)})
+				}
+			}
+		}
+	}
+	return endpoints
+}
+
 // List of all ECS Tasks of an ECS Service
 func (awsService *AWSService) DiscoverServiceTasks(ctx context.Context, serviceMessage *ServiceMessage) ([]*TaskNotifyMessage, error) {
 
 	requestId := RequestIdFromContext(ctx)
-	// container instance IP Addresses
-	ciIPAddresses, ciIPAddressesErr := awsService.listContainerInstances(ctx, serviceMessage.Cluster)
-	if ciIPAddressesErr != nil {
-		return nil, ciIPAddressesErr
-	}
-
 	containerPort, containerPortErr := strconv.ParseInt(serviceMessage.NotifyMeContainerPort, 10, 32)
 	if containerPortErr != nil {
 		slog.Error("failed to parse container port from string to int", "requestId", requestId, "errorMessage", containerPortErr)
-		return nil, containerPortErr
+		// Invalid NOTIFY_ME_CONTAINER_PORT label fails every retry
+		return nil, failure.Permanent(containerPortErr)
 	}
 
 	listTasksInput := &ecs.ListTasksInput{
@@ -173,53 +390,73 @@ func (awsService *AWSService) DiscoverServiceTasks(ctx context.Context, serviceM
 	}
 
 	paginator := ecs.NewListTasksPaginator(awsService.ecsClient, listTasksInput)
-	var discoveredTasks []*TaskNotifyMessage
-
+	var taskArns []string
 	for paginator.HasMorePages() {
 		listTaskPage, err := paginator.NextPage(ctx)
 		if err != nil {
 			return nil, err
 		}
+		taskArns = append(taskArns, listTaskPage.TaskArns...)
+	}
 
-		descTaskOutput, descTaskErr := awsService.ecsClient.DescribeTasks(ctx, &ecs.DescribeTasksInput{
-			Cluster: aws.String(serviceMessage.Cluster),
-			Tasks:   listTaskPage.TaskArns,
+	tasks, descTaskErr := describe.InChunks(ctx, taskArns, describeTasksBatchSize,
+		func(ctx context.Context, chunk []string) ([]types.Task, error) {
+			descTaskOutput, err := awsService.ecsClient.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+				Cluster: aws.String(serviceMessage.Cluster),
+				Tasks:   chunk,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return descTaskOutput.Tasks, describe.FailuresErr(RequestIdFromContext(ctx), "DescribeTasks", descTaskOutput.Failures)
 		})
+	if descTaskErr != nil {
+		slog.Error("failed to describe tasks", "requestId", requestId, "errorMessage", descTaskErr)
+		return nil, descTaskErr
+	}
 
-		if descTaskErr != nil {
-			return nil, descTaskErr
-		}
-
-		for _, task := range descTaskOutput.Tasks {
-			log.Printf("Task: %v", aws.ToString(task.TaskArn))
-			// Task should be running and LaunchType is of Type EC2
-			if aws.ToString(task.LastStatus) == string(types.DesiredStatusRunning) &&
-				task.LaunchType == types.LaunchTypeEc2 {
-				// Iterate over containers matching containerPort
-				// Extract HostPort
-
-				// task.ContainerInstanceArn
-				for _, container := range task.Containers {
-					if container.HealthStatus == types.HealthStatusHealthy &&
-						aws.ToString(container.LastStatus) == string(types.DesiredStatusRunning) {
-						for _, networkBinding := range container.NetworkBindings {
-							if aws.ToInt32(networkBinding.ContainerPort) == int32(containerPort) {
-								if ipAddress, ok := ciIPAddresses[*task.ContainerInstanceArn]; ok {
-									taskNotifyMessage := NewTaskNotifyMessage()
-									taskNotifyMessage.NotifyTaskArn = *task.TaskArn
-									taskNotifyMessage.NotifyMeHostAddress = ipAddress
-									taskNotifyMessage.NotifyMeHostPort = strconv.Itoa(int(aws.ToInt32(networkBinding.HostPort)))
-									taskNotifyMessage.NotifyMeAPIUri = serviceMessage.NotifyMeAPIUri
-
-									discoveredTasks = append(discoveredTasks, taskNotifyMessage)
-								}
-							}
-						}
-					}
-				}
-			}
+	var runningTasks []types.Task
+	for _, task := range tasks {
+		log.Printf("Task: %v", aws.ToString(task.TaskArn))
+		// Tasks of a re-enqueued message are the tasks left to publish
+		if len(serviceMessage.Tasks) > 0 && !slices.Contains(serviceMessage.Tasks, aws.ToString(task.TaskArn)) {
+			continue
+		}
+		// Task should be running and LaunchType is one of configured launch types
+		if aws.ToString(task.LastStatus) == string(types.DesiredStatusRunning) &&
+			awsService.launchTypes[taskLaunchType(task)] {
+			runningTasks = append(runningTasks, task)
 		}
+	}
 
+	// container instance IP Addresses of the service tasks only,
+	// Fargate and awsvpc tasks need no container instances
+	ciIPAddresses, ciIPAddressesErr := awsService.containerInstanceAddresses(ctx, serviceMessage.Cluster, hostContainerInstanceArns(runningTasks))
+	if ciIPAddressesErr != nil {
+		return nil, ciIPAddressesErr
+	}
+
+	var discoveredTasks []*TaskNotifyMessage
+	for _, task := range runningTasks {
+		for _, endpoint := range taskEndpoints(task, int32(containerPort), ciIPAddresses) {
+			taskNotifyMessage := NewTaskNotifyMessage()
+			taskNotifyMessage.NotificationId = serviceMessage.NotificationId
+			taskNotifyMessage.Cluster = serviceMessage.Cluster
+			taskNotifyMessage.Service = serviceMessage.Service
+			taskNotifyMessage.NotifyTaskArn = aws.ToString(task.TaskArn)
+			taskNotifyMessage.NotifyMeHostAddress = endpoint.address
+			taskNotifyMessage.NotifyMeHostPort = strconv.Itoa(int(endpoint.port))
+			taskNotifyMessage.NotifyMeAPIUri = serviceMessage.NotifyMeAPIUri
+			taskNotifyMessage.NotifyMeHTTPMethod = serviceMessage.NotifyMeHTTPMethod
+			taskNotifyMessage.NotifyMeProtocol = serviceMessage.NotifyMeProtocol
+			taskNotifyMessage.NotifyMeTLSServerName = serviceMessage.NotifyMeTLSServerName
+			taskNotifyMessage.NotifyMeAuthAudience = serviceMessage.NotifyMeAuthAudience
+			taskNotifyMessage.NotifyMeAuthScope = serviceMessage.NotifyMeAuthScope
+			taskNotifyMessage.Topic = serviceMessage.Topic
+			taskNotifyMessage.Payload = serviceMessage.Payload
+
+			discoveredTasks = append(discoveredTasks, taskNotifyMessage)
+		}
 	}
 	slog.Info("total number of tasks discovered", "lenght", len(discoveredTasks))
 
@@ -2,63 +2,457 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/cache"
 )
 
-var listContainerInstances = map[string]struct {
-	cluster string
-}{
-	"test case 1": {"ecs_cluster_name_1"},
-	"test case 2": {"ecs_cluster_name_2"},
+// Cluster with two EC2 container instances, one external instance and
+// bridge, awsvpc and Fargate tasks of service svc-a, starting with empty caches
+func newFakeCluster() (*fake.ECS, *fake.EC2) {
+	containerInstanceAddressCache.Clear()
+	ecsClient, ec2Client := fake.NewECS(), fake.NewEC2()
+	ecsClient.AddContainerInstance("cluster-a", "ci-1", "i-1")
+	ecsClient.AddContainerInstance("cluster-a", "ci-2", "i-2")
+	ecsClient.AddContainerInstance("cluster-a", "ci-external", "")
+	ec2Client.Instances["i-1"] = "10.0.0.1"
+	ec2Client.Instances["i-2"] = "10.0.0.2"
+
+	ecsClient.AddTask("cluster-a", "svc-a", fake.BridgeTask("task-bridge-1", "ci-1", 8080, 32768, types.HealthStatusHealthy))
+	ecsClient.AddTask("cluster-a", "svc-a", fake.BridgeTask("task-bridge-2", "ci-2", 8080, 32769, types.HealthStatusHealthy))
+	ecsClient.AddTask("cluster-a", "svc-a", fake.BridgeTask("task-unhealthy", "ci-1", 8080, 32770, types.HealthStatusUnhealthy))
+	ecsClient.AddTask("cluster-a", "svc-a", fake.AwsvpcTask("task-awsvpc", types.LaunchTypeEc2, "10.0.1.10", types.HealthStatusHealthy))
+	ecsClient.AddTask("cluster-a", "svc-a", fake.AwsvpcTask("task-fargate", types.LaunchTypeFargate, "10.0.2.20", types.HealthStatusHealthy))
+	ecsClient.AddTask("cluster-a", "svc-b", fake.AwsvpcTask("task-other", types.LaunchTypeFargate, "10.0.2.30", types.HealthStatusHealthy))
+	return ecsClient, ec2Client
 }
 
-func TestListContainerInstances(t *testing.T) {
-	ctx := context.TODO()
-	awsService, err := NewAWSService(ctx)
+func TestContainerInstanceAddresses(t *testing.T) {
+	ecsClient, ec2Client := newFakeCluster()
+	ecsClient.AddContainerInstance("cluster-a", "ci-terminated", "i-terminated")
+	awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB())
+
+	actual, err := awsService.containerInstanceAddresses(context.TODO(), "cluster-a",
+		[]string{"ci-1", "ci-2", "ci-external", "ci-terminated"})
 	if err != nil {
-		t.Fail()
+		t.Fatalf("containerInstanceAddresses() error = %v", err)
 	}
+	want := map[string]string{"ci-1": "10.0.0.1", "ci-2": "10.0.0.2"}
+	if !reflect.DeepEqual(actual, want) {
+		t.Errorf("containerInstanceAddresses() = %v, want %v", actual, want)
+	}
+	wantRequests := [][]string{{"i-1", "i-2", "i-terminated"}}
+	if !reflect.DeepEqual(ec2Client.Requests, wantRequests) {
+		t.Errorf("DescribeInstances requests = %v, want %v", ec2Client.Requests, wantRequests)
+	}
+}
 
-	for name, tc := range listContainerInstances {
-		t.Run(name, func(t *testing.T) {
-			actual, err := awsService.listContainerInstances(ctx, tc.cluster)
-			if err != nil {
-				t.Fail()
-			} else if len(actual) <= 0 {
-				t.Fail()
-			}
-		})
+func TestContainerInstanceAddressesCached(t *testing.T) {
+	ecsClient, ec2Client := newFakeCluster()
+	awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB())
+
+	for range 2 {
+		actual, err := awsService.containerInstanceAddresses(context.TODO(), "cluster-a", []string{"ci-1", "ci-2"})
+		if err != nil {
+			t.Fatalf("containerInstanceAddresses() error = %v", err)
+		}
+		if want := map[string]string{"ci-1": "10.0.0.1", "ci-2": "10.0.0.2"}; !reflect.DeepEqual(actual, want) {
+			t.Errorf("containerInstanceAddresses() = %v, want %v", actual, want)
+		}
+	}
+	if ecsClient.Calls["DescribeContainerInstances"] != 1 || len(ec2Client.Requests) != 1 {
+		t.Errorf("DescribeContainerInstances calls = %d, DescribeInstances calls = %d, want 1 each",
+			ecsClient.Calls["DescribeContainerInstances"], len(ec2Client.Requests))
+	}
+	if stats := ContainerInstanceCacheStats(); stats != (cache.Stats{Hits: 2, Misses: 2}) {
+		t.Errorf("ContainerInstanceCacheStats() = %+v, want 2 hits and 2 misses", stats)
+	}
+}
+
+func TestContainerInstanceAddressesBatches(t *testing.T) {
+	containerInstanceAddressCache.Clear()
+	ecsClient, ec2Client := fake.NewECS(), fake.NewEC2()
+	var containerInstanceArns []string
+	for i := range 250 {
+		containerInstanceArn, instanceId := fmt.Sprintf("ci-%d", i), fmt.Sprintf("i-%d", i)
+		ecsClient.AddContainerInstance("cluster-a", containerInstanceArn, instanceId)
+		ec2Client.Instances[instanceId] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		containerInstanceArns = append(containerInstanceArns, containerInstanceArn)
+	}
+	awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB())
+
+	actual, err := awsService.containerInstanceAddresses(context.TODO(), "cluster-a", containerInstanceArns)
+	if err != nil {
+		t.Fatalf("containerInstanceAddresses() error = %v", err)
+	}
+	if len(actual) != 250 {
+		t.Errorf("containerInstanceAddresses() resolved %d addresses, want 250", len(actual))
+	}
+	if ecsClient.Calls["DescribeContainerInstances"] != 3 {
+		t.Errorf("DescribeContainerInstances calls = %d, want 3", ecsClient.Calls["DescribeContainerInstances"])
+	}
+	if len(ec2Client.Requests) != 2 || len(ec2Client.Requests[0]) != describeInstancesBatchSize {
+		t.Errorf("DescribeInstances calls = %d, want 2 of at most %d instances", len(ec2Client.Requests), describeInstancesBatchSize)
 	}
 }
 
 var discoverServiceTasks = map[string]struct {
-	cluster string
-	service string
-	port    string
+	cluster     string
+	service     string
+	port        string
+	launchTypes []types.LaunchType
+	pageSize    int
+	want        map[string]string
+	wantErr     bool
 }{
-	"test case 1": {"ecs_cluster_name", "ecs_service_name", "ecs_task_container_port"},
+	"ec2 and fargate tasks": {
+		"cluster-a", "svc-a", "8080", DefaultLaunchTypes, 0,
+		map[string]string{
+			"task-bridge-1": "10.0.0.1:32768",
+			"task-bridge-2": "10.0.0.2:32769",
+			"task-awsvpc":   "10.0.1.10:8080",
+			"task-fargate":  "10.0.2.20:8080",
+		},
+		false,
+	},
+	"paginated tasks": {
+		"cluster-a", "svc-a", "8080", DefaultLaunchTypes, 2,
+		map[string]string{
+			"task-bridge-1": "10.0.0.1:32768",
+			"task-bridge-2": "10.0.0.2:32769",
+			"task-awsvpc":   "10.0.1.10:8080",
+			"task-fargate":  "10.0.2.20:8080",
+		},
+		false,
+	},
+	"fargate tasks only": {
+		"cluster-a", "svc-a", "8080", []types.LaunchType{types.LaunchTypeFargate}, 0,
+		map[string]string{"task-fargate": "10.0.2.20:8080"},
+		false,
+	},
+	"other container port": {
+		"cluster-a", "svc-a", "9090", DefaultLaunchTypes, 0,
+		map[string]string{"task-awsvpc": "10.0.1.10:9090", "task-fargate": "10.0.2.20:9090"},
+		false,
+	},
+	"service without tasks":  {"cluster-a", "svc-c", "8080", DefaultLaunchTypes, 0, map[string]string{}, false},
+	"invalid container port": {"cluster-a", "svc-a", "http", DefaultLaunchTypes, 0, nil, true},
+	"cluster not found":      {"missing", "svc-a", "8080", DefaultLaunchTypes, 0, nil, true},
 }
 
 func TestDiscoverTasks(t *testing.T) {
-	ctx := context.TODO()
-	awsService, err := NewAWSService(ctx)
-	if err != nil {
-		t.Fail()
-	}
-
 	for name, tc := range discoverServiceTasks {
 		t.Run(name, func(t *testing.T) {
-			var serviceMessage *ServiceMessage = NewServiceMessage()
+			ecsClient, ec2Client := newFakeCluster()
+			ecsClient.PageSize = tc.pageSize
+			awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB()).
+				WithLaunchTypes(tc.launchTypes)
+
+			serviceMessage := NewServiceMessage()
+			serviceMessage.NotificationId = "notification-1"
 			serviceMessage.Cluster = tc.cluster
 			serviceMessage.Service = tc.service
 			serviceMessage.NotifyMeContainerPort = tc.port
+			serviceMessage.NotifyMeProtocol = "https"
+			serviceMessage.NotifyMeTLSServerName = "notify.svc.internal"
+			serviceMessage.NotifyMeAuthScope = "notify:write"
+			serviceMessage.Topic = "config.reload"
+			serviceMessage.Payload = json.RawMessage(`{"event":"refresh"}`)
+
+			actual, err := awsService.DiscoverServiceTasks(context.TODO(), serviceMessage)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("DiscoverServiceTasks() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			endpoints := make(map[string]string)
+			for _, taskNotifyMessage := range actual {
+				endpoints[taskNotifyMessage.NotifyTaskArn] = taskNotifyMessage.NotifyMeHostAddress + ":" + taskNotifyMessage.NotifyMeHostPort
+				if taskNotifyMessage.NotificationId != "notification-1" || string(taskNotifyMessage.Payload) != `{"event":"refresh"}` ||
+					taskNotifyMessage.NotifyMeProtocol != "https" || taskNotifyMessage.NotifyMeTLSServerName != "notify.svc.internal" ||
+					taskNotifyMessage.NotifyMeAuthScope != "notify:write" || taskNotifyMessage.Topic != "config.reload" {
+					t.Errorf("task notify message not carrying notification %+v", taskNotifyMessage)
+				}
+			}
+			if !reflect.DeepEqual(endpoints, tc.want) {
+				t.Errorf("DiscoverServiceTasks() = %v, want %v", endpoints, tc.want)
+			}
+		})
+	}
+}
+
+var describeTasksTests = map[string]struct {
+	failures      map[string]string
+	wantTasks     int
+	wantDescribes int
+	wantErr       bool
+}{
+	"chunked to describe limit":  {nil, 250, 3, false},
+	"missing tasks skipped":      {map[string]string{"task-003": "MISSING", "task-217": "MISSING"}, 248, 3, false},
+	"other failures fail":        {map[string]string{"task-217": "ACCESS_DENIED"}, 0, 3, true},
+	"missing container instance": {map[string]string{"ci-1": "MISSING"}, 125, 3, false},
+	"container instance failure": {map[string]string{"ci-1": "ACCESS_DENIED"}, 0, 3, true},
+}
+
+func TestDiscoverTasksDescribeChunks(t *testing.T) {
+	for name, tc := range describeTasksTests {
+		t.Run(name, func(t *testing.T) {
+			ecsClient, ec2Client := newFakeCluster()
+			// Single ListTasks page beyond the DescribeTasks limit, tasks spread over ci-1 and ci-2
+			ecsClient.PageSize = 250
+			for i := range 250 {
+				containerInstanceArn := fmt.Sprintf("ci-%d", i%2+1)
+				ecsClient.AddTask("cluster-a", "svc-d", fake.BridgeTask(fmt.Sprintf("task-%03d", i), containerInstanceArn, 8080, 32768, types.HealthStatusHealthy))
+			}
+			for arn, reason := range tc.failures {
+				ecsClient.DescribeFailures[arn] = reason
+			}
+			awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB())
+
+			serviceMessage := &ServiceMessage{Cluster: "cluster-a", Service: "svc-d", NotifyMeContainerPort: "8080"}
+			actual, err := awsService.DiscoverServiceTasks(context.TODO(), serviceMessage)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("DiscoverServiceTasks() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if len(actual) != tc.wantTasks {
+				t.Errorf("DiscoverServiceTasks() tasks = %d, want %d", len(actual), tc.wantTasks)
+			}
+			if ecsClient.Calls["DescribeTasks"] != tc.wantDescribes {
+				t.Errorf("DescribeTasks calls = %d, want %d", ecsClient.Calls["DescribeTasks"], tc.wantDescribes)
+			}
+		})
+	}
+}
+
+func TestDiscoverTasksSkipsContainerInstancesForFargate(t *testing.T) {
+	ecsClient, ec2Client := newFakeCluster()
+	awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB()).
+		WithLaunchTypes([]types.LaunchType{types.LaunchTypeFargate})
+
+	serviceMessage := &ServiceMessage{Cluster: "cluster-a", Service: "svc-a", NotifyMeContainerPort: "8080"}
+	if _, err := awsService.DiscoverServiceTasks(context.TODO(), serviceMessage); err != nil {
+		t.Fatalf("DiscoverServiceTasks() error = %v", err)
+	}
+	if ecsClient.Calls["DescribeContainerInstances"] != 0 || len(ec2Client.Requests) != 0 {
+		t.Errorf("container instances described for Fargate only launch types")
+	}
+}
+
+func TestPublishServiceMessage(t *testing.T) {
+	sqsClient := fake.NewSQS()
+	awsService := NewAWSService(fake.NewECS(), fake.NewEC2(), sqsClient, fake.NewDynamoDB())
+
+	taskNotifyMessage := &TaskNotifyMessage{NotificationId: "notification-1", NotifyTaskArn: "task-1"}
+	if _, err := awsService.PublishServiceMessage(context.TODO(), "queue-url", taskNotifyMessage); err != nil {
+		t.Fatalf("PublishServiceMessage() error = %v", err)
+	}
+
+	var actual TaskNotifyMessage
+	if err := json.Unmarshal([]byte(sqsClient.Messages["queue-url"][0]), &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&actual, taskNotifyMessage) {
+		t.Errorf("published message = %+v, want %+v", actual, *taskNotifyMessage)
+	}
 
-			actual, err := awsService.DiscoverServiceTasks(ctx, serviceMessage)
-			if err != nil {
-				t.Fail()
-			} else if len(actual) <= 0 {
-				t.Fail()
+	sqsClient.Err = errors.New("AccessDenied")
+	if _, err := awsService.PublishServiceMessage(context.TODO(), "queue-url", taskNotifyMessage); err == nil {
+		t.Error("PublishServiceMessage() expected send error")
+	}
+}
+
+func eniAttachment(status, privateIP string) types.Attachment {
+	return types.Attachment{
+		Type:   aws.String("ElasticNetworkInterface"),
+		Status: aws.String(status),
+		Details: []types.KeyValuePair{
+			{Name: aws.String("subnetId"), Value: aws.String("subnet-1")},
+			{Name: aws.String("privateIPv4Address"), Value: aws.String(privateIP)},
+		},
+	}
+}
+
+func runningContainer(health types.HealthStatus, bindings ...types.NetworkBinding) types.Container {
+	return types.Container{
+		HealthStatus:    health,
+		LastStatus:      aws.String("RUNNING"),
+		NetworkBindings: bindings,
+	}
+}
+
+var taskEndpointsTests = map[string]struct {
+	task types.Task
+	want []taskEndpoint
+}{
+	"bridge host port binding": {
+		types.Task{
+			ContainerInstanceArn: aws.String("ci-1"),
+			Containers: []types.Container{runningContainer(types.HealthStatusHealthy,
+				types.NetworkBinding{ContainerPort: aws.Int32(8080), HostPort: aws.Int32(32768)})},
+		},
+		[]taskEndpoint{{"10.0.0.1", 32768}},
+	},
+	"bridge unknown container instance": {
+		types.Task{
+			ContainerInstanceArn: aws.String("ci-2"),
+			Containers: []types.Container{runningContainer(types.HealthStatusHealthy,
+				types.NetworkBinding{ContainerPort: aws.Int32(8080), HostPort: aws.Int32(32768)})},
+		},
+		nil,
+	},
+	"bridge other container port": {
+		types.Task{
+			ContainerInstanceArn: aws.String("ci-1"),
+			Containers: []types.Container{runningContainer(types.HealthStatusHealthy,
+				types.NetworkBinding{ContainerPort: aws.Int32(9090), HostPort: aws.Int32(32768)})},
+		},
+		nil,
+	},
+	"awsvpc task eni address": {
+		types.Task{
+			ContainerInstanceArn: aws.String("ci-1"),
+			Attachments:          []types.Attachment{eniAttachment("ATTACHED", "10.0.1.15")},
+			Containers: []types.Container{
+				runningContainer(types.HealthStatusHealthy),
+				runningContainer(types.HealthStatusHealthy),
+			},
+		},
+		[]taskEndpoint{{"10.0.1.15", 8080}},
+	},
+	"awsvpc eni not attached": {
+		types.Task{
+			Attachments: []types.Attachment{eniAttachment("PRECREATED", "10.0.1.15")},
+			Containers:  []types.Container{runningContainer(types.HealthStatusHealthy)},
+		},
+		nil,
+	},
+	"awsvpc unhealthy container": {
+		types.Task{
+			Attachments: []types.Attachment{eniAttachment("ATTACHED", "10.0.1.15")},
+			Containers:  []types.Container{runningContainer(types.HealthStatusUnhealthy)},
+		},
+		nil,
+	},
+}
+
+func TestTaskEndpoints(t *testing.T) {
+	ciIPAddresses := map[string]string{"ci-1": "10.0.0.1"}
+
+	for name, tc := range taskEndpointsTests {
+		t.Run(name, func(t *testing.T) {
+			actual := taskEndpoints(tc.task, 8080, ciIPAddresses)
+			if !reflect.DeepEqual(actual, tc.want) {
+				t.Errorf("taskEndpoints() = %v, want %v", actual, tc.want)
 			}
 		})
 	}
 }
+
+var parseLaunchTypesTests = map[string]struct {
+	value   string
+	want    []types.LaunchType
+	wantErr bool
+}{
+	"single launch type":   {"EC2", []types.LaunchType{types.LaunchTypeEc2}, false},
+	"multiple launch type": {" ec2, fargate ", []types.LaunchType{types.LaunchTypeEc2, types.LaunchTypeFargate}, false},
+	"unknown launch type":  {"EC2,LAMBDA", nil, true},
+	"external launch type": {"EC2,EXTERNAL", nil, true},
+	"empty value":          {" , ", nil, true},
+}
+
+func TestParseLaunchTypes(t *testing.T) {
+	for name, tc := range parseLaunchTypesTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseLaunchTypes(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseLaunchTypes() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(actual, tc.want) {
+				t.Errorf("ParseLaunchTypes() = %v, want %v", actual, tc.want)
+			}
+		})
+	}
+}
+
+var taskLaunchTypeTests = map[string]struct {
+	task types.Task
+	want types.LaunchType
+}{
+	"ec2 launch type":     {types.Task{LaunchType: types.LaunchTypeEc2}, types.LaunchTypeEc2},
+	"fargate launch type": {types.Task{LaunchType: types.LaunchTypeFargate}, types.LaunchTypeFargate},
+	"fargate spot capacity provider": {
+		types.Task{CapacityProviderName: aws.String("FARGATE_SPOT")}, types.LaunchTypeFargate,
+	},
+	"asg capacity provider": {
+		types.Task{CapacityProviderName: aws.String("asg-cp"), ContainerInstanceArn: aws.String("ci-1")}, types.LaunchTypeEc2,
+	},
+	"external launch type": {types.Task{LaunchType: types.LaunchTypeExternal}, types.LaunchTypeExternal},
+	"unknown launch type":  {types.Task{CapacityProviderName: aws.String("asg-cp")}, ""},
+}
+
+func TestTaskLaunchType(t *testing.T) {
+	for name, tc := range taskLaunchTypeTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := taskLaunchType(tc.task); actual != tc.want {
+				t.Errorf("taskLaunchType() = %v, want %v", actual, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverTasksDescribesHostContainerInstancesOnly(t *testing.T) {
+	ecsClient, ec2Client := newFakeCluster()
+	awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB())
+
+	serviceMessage := &ServiceMessage{Cluster: "cluster-a", Service: "svc-a", NotifyMeContainerPort: "8080"}
+	if _, err := awsService.DiscoverServiceTasks(context.TODO(), serviceMessage); err != nil {
+		t.Fatalf("DiscoverServiceTasks() error = %v", err)
+	}
+	// ci-1 hosts two tasks of svc-a, ci-external hosts none
+	wantRequests := [][]string{{"i-1", "i-2"}}
+	if !reflect.DeepEqual(ec2Client.Requests, wantRequests) {
+		t.Errorf("DescribeInstances requests = %v, want %v", ec2Client.Requests, wantRequests)
+	}
+}
+
+// Cluster of 1000 container instances with a service of 50 bridge tasks
+// spread over 25 of them, reports ECS and EC2 API calls per discovery
+func BenchmarkDiscoverServiceTasks(b *testing.B) {
+	b.Cleanup(containerInstanceAddressCache.Clear)
+	ecsClient, ec2Client := fake.NewECS(), fake.NewEC2()
+	for i := range 1000 {
+		instanceId := fmt.Sprintf("i-%d", i)
+		ecsClient.AddContainerInstance("cluster-a", fmt.Sprintf("ci-%d", i), instanceId)
+		ec2Client.Instances[instanceId] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+	for i := range 50 {
+		ecsClient.AddTask("cluster-a", "svc-a", fake.BridgeTask(fmt.Sprintf("task-%d", i), fmt.Sprintf("ci-%d", i%25), 8080, int32(32768+i), types.HealthStatusHealthy))
+	}
+	awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB())
+	serviceMessage := &ServiceMessage{Cluster: "cluster-a", Service: "svc-a", NotifyMeContainerPort: "8080"}
+
+	for range b.N {
+		// Cold container, every discovery resolves container instances
+		containerInstanceAddressCache.Clear()
+		taskNotifyMessages, err := awsService.DiscoverServiceTasks(context.TODO(), serviceMessage)
+		if err != nil || len(taskNotifyMessages) != 50 {
+			b.Fatalf("DiscoverServiceTasks() = %d tasks, error = %v", len(taskNotifyMessages), err)
+		}
+	}
+
+	ecsCalls := 0
+	for _, calls := range ecsClient.Calls {
+		ecsCalls += calls
+	}
+	b.ReportMetric(float64(ecsCalls)/float64(b.N), "ecs-calls/op")
+	b.ReportMetric(float64(len(ec2Client.Requests))/float64(b.N), "ec2-calls/op")
+}
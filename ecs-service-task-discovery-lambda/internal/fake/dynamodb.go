@@ -0,0 +1,34 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// In-memory DynamoDB recording put items
+type DynamoDB struct {
+	mu sync.Mutex
+
+	// Put items in call order
+	Items []map[string]types.AttributeValue
+	// Error returned by PutItem
+	Err error
+}
+
+func NewDynamoDB() *DynamoDB {
+	return &DynamoDB{}
+}
+
+func (fake *DynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if fake.Err != nil {
+		return nil, fake.Err
+	}
+	fake.Items = append(fake.Items, params.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
@@ -0,0 +1,68 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// In-memory EC2 instances and their private IP addresses
+type EC2 struct {
+	mu sync.Mutex
+
+	// Private IP address by EC2 instance Id
+	Instances map[string]string
+	// Error returned by DescribeInstances
+	Err error
+	// Instance Ids requested by each DescribeInstances call,
+	// either as InstanceIds or as values of the instance-id filter
+	Requests [][]string
+}
+
+func NewEC2() *EC2 {
+	return &EC2{Instances: make(map[string]string)}
+}
+
+func (fake *EC2) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	instanceIds := params.InstanceIds
+	for _, filter := range params.Filters {
+		if aws.ToString(filter.Name) == "instance-id" {
+			instanceIds = append(instanceIds, filter.Values...)
+		}
+	}
+	fake.Requests = append(fake.Requests, instanceIds)
+	if fake.Err != nil {
+		return nil, fake.Err
+	}
+
+	reservation := types.Reservation{}
+	for _, instanceId := range instanceIds {
+		privateIP, ok := fake.Instances[instanceId]
+		if !ok && len(params.InstanceIds) > 0 {
+			// EC2 fails the whole request when any instance Id is unknown
+			return nil, &smithy.GenericAPIError{
+				Code:    "InvalidInstanceID.NotFound",
+				Message: "The instance ID '" + instanceId + "' does not exist",
+			}
+		}
+		if !ok {
+			// Filters match existing instances only
+			continue
+		}
+		reservation.Instances = append(reservation.Instances, types.Instance{
+			InstanceId:       aws.String(instanceId),
+			PrivateIpAddress: aws.String(privateIP),
+			NetworkInterfaces: []types.InstanceNetworkInterface{
+				{PrivateIpAddress: aws.String(privateIP)},
+			},
+		})
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{reservation}}, nil
+}
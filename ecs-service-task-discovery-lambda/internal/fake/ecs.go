@@ -0,0 +1,244 @@
+// Package fake provides in-memory fakes of the AWS clients used by the
+// ECS service task discovery Lambda, for unit tests without AWS access
+package fake
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Maximum ARNs accepted by DescribeTasks and DescribeContainerInstances
+const maxDescribeArns = 100
+
+// In-memory ECS cluster tasks and container instances
+type ECS struct {
+	mu sync.Mutex
+
+	// Tasks by cluster name, task group "service:<service name>" links task to service
+	Tasks map[string][]types.Task
+	// Container instances by cluster name
+	ContainerInstances map[string][]types.ContainerInstance
+	// Items returned per List page, 100 unless set
+	PageSize int
+	// Errors returned by operation name e.g. "DescribeTasks"
+	Errors map[string]error
+	// Failure reasons reported by describe calls by ARN, e.g. "MISSING"
+	DescribeFailures map[string]string
+	// Number of calls by operation name
+	Calls map[string]int
+}
+
+func NewECS() *ECS {
+	return &ECS{
+		Tasks:              make(map[string][]types.Task),
+		ContainerInstances: make(map[string][]types.ContainerInstance),
+		Errors:             make(map[string]error),
+		DescribeFailures:   make(map[string]string),
+		Calls:              make(map[string]int),
+	}
+}
+
+// Add cluster without tasks and container instances
+func (fake *ECS) AddCluster(cluster string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, ok := fake.Tasks[cluster]; !ok {
+		fake.Tasks[cluster] = nil
+	}
+}
+
+// Add container instance backed by EC2 instance to the cluster
+func (fake *ECS) AddContainerInstance(cluster string, containerInstanceArn string, ec2InstanceId string) {
+	fake.AddCluster(cluster)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	containerInstance := types.ContainerInstance{ContainerInstanceArn: aws.String(containerInstanceArn)}
+	if ec2InstanceId != "" {
+		containerInstance.Ec2InstanceId = aws.String(ec2InstanceId)
+	}
+	fake.ContainerInstances[cluster] = append(fake.ContainerInstances[cluster], containerInstance)
+}
+
+// Add task of the service to the cluster
+func (fake *ECS) AddTask(cluster string, service string, task types.Task) {
+	fake.AddCluster(cluster)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	task.Group = aws.String("service:" + service)
+	fake.Tasks[cluster] = append(fake.Tasks[cluster], task)
+}
+
+func (fake *ECS) call(operation string) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.Calls[operation]++
+	return fake.Errors[operation]
+}
+
+func (fake *ECS) pageSize() int {
+	if fake.PageSize <= 0 {
+		return 100
+	}
+	return fake.PageSize
+}
+
+// Page of items starting at next token, next token is the offset of the following page
+func page(items []string, nextToken *string, pageSize int) ([]string, *string) {
+	start := 0
+	if nextToken != nil {
+		start, _ = strconv.Atoi(aws.ToString(nextToken))
+	}
+	start = min(start, len(items))
+	end := min(start+pageSize, len(items))
+	if end < len(items) {
+		return items[start:end], aws.String(strconv.Itoa(end))
+	}
+	return items[start:end], nil
+}
+
+func clusterNotFound() error {
+	return &types.ClusterNotFoundException{Message: aws.String("Cluster not found.")}
+}
+
+func invalidDescribeArns(count int) error {
+	if count == 0 || count > maxDescribeArns {
+		return &types.InvalidParameterException{Message: aws.String("must contain between 1 and 100 items.")}
+	}
+	return nil
+}
+
+func (fake *ECS) ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	if err := fake.call("ListTasks"); err != nil {
+		return nil, err
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	tasks, ok := fake.Tasks[aws.ToString(params.Cluster)]
+	if !ok {
+		return nil, clusterNotFound()
+	}
+
+	var taskArns []string
+	for _, task := range tasks {
+		if params.ServiceName == nil || aws.ToString(task.Group) == "service:"+aws.ToString(params.ServiceName) {
+			taskArns = append(taskArns, aws.ToString(task.TaskArn))
+		}
+	}
+
+	pageArns, nextToken := page(taskArns, params.NextToken, fake.pageSize())
+	return &ecs.ListTasksOutput{TaskArns: pageArns, NextToken: nextToken}, nil
+}
+
+func (fake *ECS) DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	if err := fake.call("DescribeTasks"); err != nil {
+		return nil, err
+	}
+	if err := invalidDescribeArns(len(params.Tasks)); err != nil {
+		return nil, err
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	output := &ecs.DescribeTasksOutput{}
+	for _, taskArn := range params.Tasks {
+		if reason, ok := fake.DescribeFailures[taskArn]; ok {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(taskArn), Reason: aws.String(reason)})
+			continue
+		}
+		found := false
+		for _, task := range fake.Tasks[aws.ToString(params.Cluster)] {
+			if aws.ToString(task.TaskArn) == taskArn {
+				output.Tasks = append(output.Tasks, task)
+				found = true
+				break
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(taskArn), Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+func (fake *ECS) DescribeContainerInstances(ctx context.Context, params *ecs.DescribeContainerInstancesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeContainerInstancesOutput, error) {
+	if err := fake.call("DescribeContainerInstances"); err != nil {
+		return nil, err
+	}
+	if err := invalidDescribeArns(len(params.ContainerInstances)); err != nil {
+		return nil, err
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	output := &ecs.DescribeContainerInstancesOutput{}
+	for _, containerInstanceArn := range params.ContainerInstances {
+		if reason, ok := fake.DescribeFailures[containerInstanceArn]; ok {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(containerInstanceArn), Reason: aws.String(reason)})
+			continue
+		}
+		found := false
+		for _, containerInstance := range fake.ContainerInstances[aws.ToString(params.Cluster)] {
+			if aws.ToString(containerInstance.ContainerInstanceArn) == containerInstanceArn {
+				output.ContainerInstances = append(output.ContainerInstances, containerInstance)
+				found = true
+				break
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(containerInstanceArn), Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+// Running EC2 launched bridge network mode task with container port bound to host port
+func BridgeTask(taskArn string, containerInstanceArn string, containerPort int32, hostPort int32, health types.HealthStatus) types.Task {
+	return types.Task{
+		TaskArn:              aws.String(taskArn),
+		LastStatus:           aws.String("RUNNING"),
+		LaunchType:           types.LaunchTypeEc2,
+		ContainerInstanceArn: aws.String(containerInstanceArn),
+		Containers: []types.Container{{
+			HealthStatus: health,
+			LastStatus:   aws.String("RUNNING"),
+			NetworkBindings: []types.NetworkBinding{{
+				ContainerPort: aws.Int32(containerPort),
+				HostPort:      aws.Int32(hostPort),
+			}},
+		}},
+	}
+}
+
+// Running awsvpc network mode task with ENI private IP address
+func AwsvpcTask(taskArn string, launchType types.LaunchType, privateIP string, health types.HealthStatus) types.Task {
+	return types.Task{
+		TaskArn:    aws.String(taskArn),
+		LastStatus: aws.String("RUNNING"),
+		LaunchType: launchType,
+		Attachments: []types.Attachment{{
+			Type:   aws.String("ElasticNetworkInterface"),
+			Status: aws.String("ATTACHED"),
+			Details: []types.KeyValuePair{
+				{Name: aws.String("privateIPv4Address"), Value: aws.String(privateIP)},
+			},
+		}},
+		Containers: []types.Container{{
+			HealthStatus: health,
+			LastStatus:   aws.String("RUNNING"),
+		}},
+	}
+}
@@ -0,0 +1,45 @@
+package fake
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// In-memory SQS recording sent message bodies by queue URL
+type SQS struct {
+	mu sync.Mutex
+
+	// Sent message bodies by queue URL
+	Messages map[string][]string
+	// Error returned by SendMessage
+	Err error
+	// SendMessage fails once this many messages are sent, when Err is set
+	FailAfter int
+	// Queue URL failing with Err, every queue when empty
+	ErrQueueURL string
+}
+
+func NewSQS() *SQS {
+	return &SQS{Messages: make(map[string][]string)}
+}
+
+func (fake *SQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	sent := 0
+	for _, messages := range fake.Messages {
+		sent += len(messages)
+	}
+	queueURL := aws.ToString(params.QueueUrl)
+	if fake.Err != nil && sent >= fake.FailAfter && (fake.ErrQueueURL == "" || fake.ErrQueueURL == queueURL) {
+		return nil, fake.Err
+	}
+
+	fake.Messages[queueURL] = append(fake.Messages[queueURL], aws.ToString(params.MessageBody))
+	return &sqs.SendMessageOutput{MessageId: aws.String("message-" + strconv.Itoa(sent+1))}, nil
+}
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// AWS region name e.g. us-east-1 or us-gov-west-1
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// Region of the AWS Service, default region of the config unless built for a region
+func (awsService *AWSService) Region() string {
+	return awsService.region
+}
+
+// AWS Service calling ECS and EC2 in the region of the service, the service itself in
+// its own region. Task messages are published to the queue of the notifier region.
+// Invalid regions fail every retry, reported as permanent error
+func (awsService *AWSService) ForRegion(region string) (*AWSService, error) {
+	if region == "" || region == awsService.region {
+		return awsService, nil
+	}
+	if !regionPattern.MatchString(region) {
+		return nil, failure.Permanent(fmt.Errorf("invalid region %q", region))
+	}
+	if awsService.roleClients == nil {
+		return nil, failure.Permanent(fmt.Errorf("cross-region notification to %s not supported", region))
+	}
+
+	clients := awsService.roleClients(awsService.roleArn, region)
+	regionService := *awsService
+	regionService.region = region
+	regionService.ecsClient = clients.ECSClient
+	regionService.ec2Client = clients.EC2Client
+	return &regionService, nil
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/assumerole"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+func TestForRegion(t *testing.T) {
+	ecsClient, ec2Client := newFakeCluster()
+	sqsClient := fake.NewSQS()
+	built := map[assumerole.Key]*RoleClients{}
+	awsService := NewAWSService(ecsClient, ec2Client, sqsClient, fake.NewDynamoDB()).
+		withRegion("us-east-1").
+		WithRoleClients(func(roleArn string, region string) *RoleClients {
+			key := assumerole.Key{RoleArn: roleArn, Region: region}
+			if built[key] == nil {
+				built[key] = &RoleClients{ECSClient: fake.NewECS(), EC2Client: fake.NewEC2()}
+			}
+			return built[key]
+		})
+
+	for _, region := range []string{"", "us-east-1"} {
+		if regionService, err := awsService.ForRegion(region); err != nil || regionService != awsService {
+			t.Errorf("ForRegion(%q) = %v, %v, want the service itself", region, regionService, err)
+		}
+	}
+
+	regionService, err := awsService.ForRegion("us-west-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	regionClients := built[assumerole.Key{Region: "us-west-2"}]
+	if regionService.Region() != "us-west-2" || regionService.ecsClient != regionClients.ECSClient || regionService.ec2Client != regionClients.EC2Client {
+		t.Errorf("ForRegion() clients not of region us-west-2")
+	}
+	if regionService.sqsClient != sqsClient {
+		t.Errorf("ForRegion() SQS client not of the notifier region")
+	}
+
+	// Workload account role in the region
+	roleService, err := regionService.ForRole(workloadRoleArn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roleService.ecsClient != built[assumerole.Key{RoleArn: workloadRoleArn, Region: "us-west-2"}].ECSClient {
+		t.Errorf("ForRole() clients not of workload role in region us-west-2")
+	}
+}
+
+var forRegionErrorTests = map[string]struct {
+	region    string
+	noFactory bool
+}{
+	"invalid region":  {"us-west-2/../", false},
+	"region endpoint": {"sqs.us-west-2.amazonaws.com", false},
+	"without factory": {"us-west-2", true},
+}
+
+func TestForRegionErrors(t *testing.T) {
+	for name, tc := range forRegionErrorTests {
+		t.Run(name, func(t *testing.T) {
+			ecsClient, ec2Client := newFakeCluster()
+			awsService := NewAWSService(ecsClient, ec2Client, fake.NewSQS(), fake.NewDynamoDB()).withRegion("us-east-1")
+			if !tc.noFactory {
+				awsService = awsService.WithRoleClients(func(roleArn string, region string) *RoleClients {
+					return &RoleClients{ECSClient: fake.NewECS(), EC2Client: fake.NewEC2()}
+				})
+			}
+			if _, err := awsService.ForRegion(tc.region); !failure.IsPermanent(err) {
+				t.Errorf("ForRegion(%q) error = %v, want permanent error", tc.region, err)
+			}
+		})
+	}
+}
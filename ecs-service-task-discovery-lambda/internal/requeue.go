@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/requeue"
+)
+
+const (
+	// Message attribute counting re-enqueues of the service message
+	RequeueCountAttribute = "TaskDiscoveryRequeueCount"
+
+	// Re-enqueues of the tasks left to publish of a service, the message is
+	// retried by SQS and reaches the dead letter queue afterwards
+	MaxRequeues = 3
+)
+
+// Re-enqueues of the SQS message so far
+func RequeueCount(record events.SQSMessage) int {
+	return requeue.Count(record, RequeueCountAttribute)
+}
+
+// Send service message of the tasks left to publish back to the service
+// queue to be delivered after delay
+func (awsService *AWSService) RequeueServiceMessage(ctx context.Context, sqsQueueURL string, serviceMessage *ServiceMessage, requeueCount int, delay time.Duration) (*string, error) {
+	return requeue.Send(ctx, awsService.sqsClient, RequestIdFromContext(ctx), sqsQueueURL, serviceMessage, RequeueCountAttribute, requeueCount, delay)
+}
@@ -1,61 +1,171 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jittakal/ecs-task-notifier/ecs-service-task-discovery-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/requeue"
 )
 
-func HandleRequest(ctx context.Context, event *events.SQSEvent) error {
+// AWS Service factory, replaced by fakes in tests
+var newAWSService = internal.NewAWSServiceFromConfig
+
+// HandleRequest processes SQS messages and reports failed messages only
+// as batch item failures, so that successful messages are not retried
+func HandleRequest(ctx context.Context, event *events.SQSEvent) (events.SQSEventResponse, error) {
 	requestId := internal.RequestIdFromContext(ctx)
+	response := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
 
-	awsService, err := internal.NewAWSService(ctx)
+	awsService, err := newAWSService(ctx)
 	if err != nil {
-		return err
+		return response, err
 	}
 
 	// Accessing environment variables
 	sqsQueueURL, keyNotExists := os.LookupEnv("SQS_QUEUE_URL")
 	if !keyNotExists {
 		slog.Error("Environment variable value is missing", "Key", "SQS_QUEUE_URL")
-		return fmt.Errorf("environment key missing: %v", "SQS_QUEUE_URL")
+		return response, fmt.Errorf("environment key missing: %v", "SQS_QUEUE_URL")
+	}
+
+	// Optional comma separated launch types of tasks to discover e.g. EC2,FARGATE
+	if launchTypesValue, ok := os.LookupEnv("ECS_TASK_LAUNCH_TYPES"); ok {
+		launchTypes, launchTypesErr := internal.ParseLaunchTypes(launchTypesValue)
+		if launchTypesErr != nil {
+			slog.Error("Invalid environment variable value", "Key", "ECS_TASK_LAUNCH_TYPES", "errorMessage", launchTypesErr)
+			return response, launchTypesErr
+		}
+		awsService = awsService.WithLaunchTypes(launchTypes)
 	}
 
+	// Optional DynamoDB table to audit notifications
+	awsService = awsService.WithAuditTable(os.Getenv("AUDIT_TABLE_NAME"))
+
+	// Optional dead letter queue of messages failed with permanent errors
+	deadLetterQueueURL := os.Getenv("DEAD_LETTER_QUEUE_URL")
+
+	// Optional service queue the Lambda consumes, tasks left to publish of a service are
+	// re-enqueued to it so that published tasks are not published again
+	serviceQueueURL := os.Getenv("SERVICE_QUEUE_URL")
+
 	for _, record := range event.Records {
-		slog.Info("Received Message Details", "requestId", requestId, "messageId", record.MessageId, "messageBody", record.Body)
+		if err := handleRecord(ctx, awsService, sqsQueueURL, serviceQueueURL, record); err != nil {
+			slog.Error("Failed to process message", "requestId", requestId, "messageId", record.MessageId, "errorMessage", err)
+			if failure.AcknowledgePermanentFailure(ctx, awsService.SQSClient(), requestId, deadLetterQueueURL, record, err) {
+				continue
+			}
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: record.MessageId,
+			})
+		}
+	}
+
+	containerInstanceCacheStats := internal.ContainerInstanceCacheStats()
+	slog.Info("Container instance cache statistics", "requestId", requestId,
+		"hits", containerInstanceCacheStats.Hits, "misses", containerInstanceCacheStats.Misses)
+	return response, nil
+}
 
-		var serviceMessage internal.ServiceMessage
-		// Unmarshal the JSON string into the Person struct
-		err := json.Unmarshal([]byte(record.Body), &serviceMessage)
+// Discover running tasks of the ECS service message and
+// publish a message for each task to notify
+func handleRecord(ctx context.Context, awsService *internal.AWSService, sqsQueueURL string, serviceQueueURL string, record events.SQSMessage) (err error) {
+	requestId := internal.RequestIdFromContext(ctx)
+	slog.Info("Received Message Details", "requestId", requestId, "messageId", record.MessageId, "messageBody", record.Body)
+
+	// Audited before unmarshalling, invalid messages are audited by SQS message Id
+	auditRecord := internal.NewMessageAuditRecord(record.MessageId)
+	defer func() {
 		if err != nil {
-			slog.Error("Failed to Unmarshal Message to struct", "requestId", requestId, "errorMessage", err)
-			return err
+			auditRecord.Error = err.Error()
+			auditRecord.Permanent = failure.IsPermanent(err)
 		}
-		slog.Info("ECS service details", "serviceName", serviceMessage.Service)
+		awsService.PutAuditRecord(ctx, auditRecord)
+	}()
 
-		taskNotifyMessages, discoverTaskErr := awsService.DiscoverServiceTasks(ctx, &serviceMessage)
-		if discoverTaskErr != nil {
-			return discoverTaskErr
-		}
+	var serviceMessage internal.ServiceMessage
+	// Unmarshal the JSON string into the ServiceMessage struct
+	err = json.Unmarshal([]byte(record.Body), &serviceMessage)
+	if err != nil {
+		slog.Error("Failed to Unmarshal Message to struct", "requestId", requestId, "errorMessage", err)
+		return failure.Permanent(err)
+	}
+	slog.Info("ECS service details", "notificationId", serviceMessage.NotificationId, "serviceName", serviceMessage.Service)
+	requeueCount := internal.RequeueCount(record)
+	auditRecord = internal.NewAuditRecord(&serviceMessage, cmp.Or(serviceMessage.Region, awsService.Region())).WithRequeueCount(requeueCount)
+
+	// Tasks of a service in another region or workload account are discovered with
+	// clients of the region and credentials of the assumed role
+	regionService, regionErr := awsService.ForRegion(serviceMessage.Region)
+	if regionErr != nil {
+		return regionErr
+	}
+	roleService, roleErr := regionService.ForRole(serviceMessage.RoleArn)
+	if roleErr != nil {
+		return roleErr
+	}
 
-		for _, taskNotifyMessage := range taskNotifyMessages {
-			taskMsgId, publishErr := awsService.PublishServiceMessage(ctx, sqsQueueURL, taskNotifyMessage)
+	taskNotifyMessages, discoverTaskErr := roleService.DiscoverServiceTasks(ctx, &serviceMessage)
+	if discoverTaskErr != nil {
+		return discoverTaskErr
+	}
+	auditRecord.WithTasks(taskNotifyMessages)
+
+	for i, taskNotifyMessage := range taskNotifyMessages {
+		taskMsgId, publishErr := awsService.PublishServiceMessage(ctx, sqsQueueURL, taskNotifyMessage)
 
-			if publishErr != nil {
+		if publishErr != nil {
+			// Tasks left to publish are re-enqueued on their own, retrying the message
+			// would publish the tasks published already once more
+			if failure.IsPermanent(publishErr) ||
+				!requeueTasks(ctx, awsService, serviceQueueURL, &serviceMessage, taskNotifyMessages[i:], requeueCount) {
 				return publishErr // put message on retry
 			}
-			slog.Info("Message published successfully", "requestId", requestId, "messageId", *taskMsgId)
+			auditRecord.Error = publishErr.Error()
+			return nil
 		}
+		slog.Info("Message published successfully", "requestId", requestId, "notificationId", serviceMessage.NotificationId, "messageId", *taskMsgId)
 	}
 	return nil
 }
 
+// Re-enqueue the tasks left to publish of the service message, false when the message
+// has to be retried instead: without service queue, once re-enqueues are exhausted or
+// when re-enqueueing failed
+func requeueTasks(ctx context.Context, awsService *internal.AWSService, serviceQueueURL string, serviceMessage *internal.ServiceMessage, unpublished []*internal.TaskNotifyMessage, requeueCount int) bool {
+	requestId := internal.RequestIdFromContext(ctx)
+	if serviceQueueURL == "" || requeueCount >= internal.MaxRequeues {
+		return false
+	}
+
+	// Endpoints of a task are re-enqueued together, published endpoints of the
+	// failed task are published once more
+	message := *serviceMessage
+	message.Tasks = nil
+	for _, taskNotifyMessage := range unpublished {
+		if !slices.Contains(message.Tasks, taskNotifyMessage.NotifyTaskArn) {
+			message.Tasks = append(message.Tasks, taskNotifyMessage.NotifyTaskArn)
+		}
+	}
+
+	delay := requeue.Delay(requeueCount)
+	requeueMsgId, requeueErr := awsService.RequeueServiceMessage(ctx, serviceQueueURL, &message, requeueCount+1, delay)
+	if requeueErr != nil {
+		return false
+	}
+	slog.Info("Tasks left to publish re-enqueued", "requestId", requestId, "notificationId", serviceMessage.NotificationId,
+		"messageId", *requeueMsgId, "tasks", message.Tasks, "delay", delay)
+	return true
+}
+
 func main() {
 	lambda.Start(HandleRequest)
 }
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-discovery-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-discovery-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+// Replace AWS Service factory with fakes for the duration of the test
+func useFakeAWSService(t *testing.T, ecsClient *fake.ECS, sqsClient *fake.SQS) {
+	original := newAWSService
+	newAWSService = func(ctx context.Context) (*internal.AWSService, error) {
+		ec2Client := fake.NewEC2()
+		// Workload account clusters are faked by the same clients
+		return internal.NewAWSService(ecsClient, ec2Client, sqsClient, fake.NewDynamoDB()).
+			WithRoleClients(func(roleArn string, region string) *internal.RoleClients {
+				return &internal.RoleClients{ECSClient: ecsClient, EC2Client: ec2Client}
+			}), nil
+	}
+	t.Cleanup(func() { newAWSService = original })
+}
+
+func TestHandlerMissingQueueURL(t *testing.T) {
+	useFakeAWSService(t, fake.NewECS(), fake.NewSQS())
+	// Setenv restores the original value on cleanup
+	t.Setenv("SQS_QUEUE_URL", "")
+	os.Unsetenv("SQS_QUEUE_URL")
+
+	if _, err := HandleRequest(context.TODO(), &events.SQSEvent{}); err == nil {
+		t.Error("HandleRequest() expected missing environment key error")
+	}
+}
+
+func TestHandlerInvalidLaunchTypes(t *testing.T) {
+	useFakeAWSService(t, fake.NewECS(), fake.NewSQS())
+	t.Setenv("SQS_QUEUE_URL", "queue-url")
+	t.Setenv("ECS_TASK_LAUNCH_TYPES", "EC2,LAMBDA")
+
+	if _, err := HandleRequest(context.TODO(), &events.SQSEvent{}); err == nil {
+		t.Error("HandleRequest() expected invalid launch types error")
+	}
+}
+
+var handlerTests = map[string]struct {
+	records         []events.SQSMessage
+	sendErr         error
+	deadLetterQueue string
+	wantFailures    []string
+	wantMessages    int
+	wantDeadLetters int
+}{
+	"all messages processed": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a","service":"svc-a","notify_me_container_port":"8080"}`},
+			{MessageId: "m2", Body: `{"cluster":"cluster-a","service":"svc-b","notify_me_container_port":"8080"}`},
+		},
+		nil, "", []string{}, 3, 0,
+	},
+	"invalid json and unknown cluster acknowledged": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":`},
+			{MessageId: "m2", Body: `{"cluster":"cluster-a","service":"svc-a","notify_me_container_port":"8080"}`},
+			{MessageId: "m3", Body: `{"cluster":"missing","service":"svc-a","notify_me_container_port":"8080"}`},
+		},
+		nil, "", []string{}, 2, 0,
+	},
+	"permanent errors routed to dead letter queue": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":`},
+			{MessageId: "m2", Body: `{"cluster":"cluster-a","service":"svc-a","notify_me_container_port":"http"}`},
+		},
+		nil, "dlq-url", []string{}, 0, 2,
+	},
+	"workload account role": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a","service":"svc-a","notify_me_container_port":"8080","role_arn":"arn:aws:iam::210987654321:role/ECSTaskNotifierWorkloadRole"}`},
+		},
+		nil, "", []string{}, 2, 0,
+	},
+	"invalid role acknowledged": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a","service":"svc-a","notify_me_container_port":"8080","role_arn":"ECSTaskNotifierWorkloadRole"}`},
+		},
+		nil, "", []string{}, 0, 0,
+	},
+	"other region": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a","service":"svc-a","notify_me_container_port":"8080","region":"us-west-2"}`},
+		},
+		nil, "", []string{}, 2, 0,
+	},
+	"invalid region acknowledged": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a","service":"svc-a","notify_me_container_port":"8080","region":"sqs.us-west-2.amazonaws.com"}`},
+		},
+		nil, "", []string{}, 0, 0,
+	},
+	"publish error fails message": {
+		[]events.SQSMessage{
+			{MessageId: "m1", Body: `{"cluster":"cluster-a","service":"svc-a","notify_me_container_port":"8080"}`},
+		},
+		errors.New("AccessDenied"), "", []string{"m1"}, 0, 0,
+	},
+}
+
+func TestHandleRequest(t *testing.T) {
+	for name, tc := range handlerTests {
+		t.Run(name, func(t *testing.T) {
+			ecsClient, sqsClient := fake.NewECS(), fake.NewSQS()
+			ecsClient.AddTask("cluster-a", "svc-a", fake.AwsvpcTask("task-1", types.LaunchTypeFargate, "10.0.2.1", types.HealthStatusHealthy))
+			ecsClient.AddTask("cluster-a", "svc-a", fake.AwsvpcTask("task-2", types.LaunchTypeFargate, "10.0.2.2", types.HealthStatusHealthy))
+			ecsClient.AddTask("cluster-a", "svc-b", fake.AwsvpcTask("task-3", types.LaunchTypeFargate, "10.0.2.3", types.HealthStatusHealthy))
+			sqsClient.Err = tc.sendErr
+			useFakeAWSService(t, ecsClient, sqsClient)
+			t.Setenv("SQS_QUEUE_URL", "queue-url")
+			t.Setenv("DEAD_LETTER_QUEUE_URL", tc.deadLetterQueue)
+
+			response, err := HandleRequest(context.TODO(), &events.SQSEvent{Records: tc.records})
+			if err != nil {
+				t.Fatalf("HandleRequest() error = %v", err)
+			}
+
+			failures := []string{}
+			for _, failure := range response.BatchItemFailures {
+				failures = append(failures, failure.ItemIdentifier)
+			}
+			if !reflect.DeepEqual(failures, tc.wantFailures) {
+				t.Errorf("BatchItemFailures = %v, want %v", failures, tc.wantFailures)
+			}
+			if len(sqsClient.Messages["queue-url"]) != tc.wantMessages {
+				t.Errorf("published messages = %d, want %d", len(sqsClient.Messages["queue-url"]), tc.wantMessages)
+			}
+			if len(sqsClient.Messages["dlq-url"]) != tc.wantDeadLetters {
+				t.Errorf("dead letter messages = %d, want %d", len(sqsClient.Messages["dlq-url"]), tc.wantDeadLetters)
+			}
+		})
+	}
+}
+
+func TestHandleRequestRequeuesTasksLeftToPublish(t *testing.T) {
+	ecsClient, sqsClient := fake.NewECS(), fake.NewSQS()
+	ecsClient.AddTask("cluster-a", "svc-a", fake.AwsvpcTask("task-1", types.LaunchTypeFargate, "10.0.2.1", types.HealthStatusHealthy))
+	ecsClient.AddTask("cluster-a", "svc-a", fake.AwsvpcTask("task-2", types.LaunchTypeFargate, "10.0.2.2", types.HealthStatusHealthy))
+	ecsClient.AddTask("cluster-a", "svc-a", fake.AwsvpcTask("task-3", types.LaunchTypeFargate, "10.0.2.3", types.HealthStatusHealthy))
+	// Publishing fails after the first task, the service queue keeps accepting messages
+	sqsClient.Err, sqsClient.FailAfter, sqsClient.ErrQueueURL = &smithy.GenericAPIError{Code: "ThrottlingException"}, 1, "queue-url"
+	useFakeAWSService(t, ecsClient, sqsClient)
+	t.Setenv("SQS_QUEUE_URL", "queue-url")
+	t.Setenv("SERVICE_QUEUE_URL", "service-queue-url")
+
+	record := events.SQSMessage{MessageId: "m1", Body: `{"notification_id":"n1","cluster":"cluster-a","service":"svc-a","notify_me_container_port":"8080"}`}
+	response, err := HandleRequest(context.TODO(), &events.SQSEvent{Records: []events.SQSMessage{record}})
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("HandleRequest() = %v, %v", response, err)
+	}
+	if len(sqsClient.Messages["queue-url"]) != 1 {
+		t.Errorf("published messages = %d, want 1", len(sqsClient.Messages["queue-url"]))
+	}
+
+	requeued := sqsClient.Messages["service-queue-url"]
+	if len(requeued) != 1 {
+		t.Fatalf("re-enqueued messages = %d, want 1", len(requeued))
+	}
+	var serviceMessage internal.ServiceMessage
+	if err := json.Unmarshal([]byte(requeued[0]), &serviceMessage); err != nil {
+		t.Fatal(err)
+	}
+	if serviceMessage.NotificationId != "n1" || !reflect.DeepEqual(serviceMessage.Tasks, []string{"task-2", "task-3"}) {
+		t.Errorf("re-enqueued message = %+v, want tasks left to publish", serviceMessage)
+	}
+
+	// The re-enqueued message publishes the tasks left only
+	sqsClient.Err = nil
+	requeuedRecord := events.SQSMessage{MessageId: "m2", Body: requeued[0], MessageAttributes: map[string]events.SQSMessageAttribute{
+		internal.RequeueCountAttribute: {StringValue: aws.String("1"), DataType: "Number"},
+	}}
+	response, err = HandleRequest(context.TODO(), &events.SQSEvent{Records: []events.SQSMessage{requeuedRecord}})
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("HandleRequest() = %v, %v", response, err)
+	}
+	var taskArns []string
+	for _, body := range sqsClient.Messages["queue-url"][1:] {
+		var taskNotifyMessage internal.TaskNotifyMessage
+		if err := json.Unmarshal([]byte(body), &taskNotifyMessage); err != nil {
+			t.Fatal(err)
+		}
+		taskArns = append(taskArns, taskNotifyMessage.NotifyTaskArn)
+	}
+	if !reflect.DeepEqual(taskArns, []string{"task-2", "task-3"}) {
+		t.Errorf("published tasks of the re-enqueued message = %v, want task-2 and task-3", taskArns)
+	}
+
+	// Once re-enqueues are exhausted the message is retried
+	sqsClient.Err, sqsClient.FailAfter = &smithy.GenericAPIError{Code: "ThrottlingException"}, 0
+	record.MessageAttributes = map[string]events.SQSMessageAttribute{
+		internal.RequeueCountAttribute: {StringValue: aws.String(strconv.Itoa(internal.MaxRequeues)), DataType: "Number"},
+	}
+	response, err = HandleRequest(context.TODO(), &events.SQSEvent{Records: []events.SQSMessage{record}})
+	if err != nil || len(response.BatchItemFailures) != 1 {
+		t.Errorf("exhausted re-enqueues BatchItemFailures = %v, error = %v", response.BatchItemFailures, err)
+	}
+}
+
+func TestHandleRecordAuditsInvalidMessage(t *testing.T) {
+	dynamodbClient := fake.NewDynamoDB()
+	awsService := internal.NewAWSService(fake.NewECS(), fake.NewEC2(), fake.NewSQS(), dynamodbClient).WithAuditTable("audit-table")
+
+	record := events.SQSMessage{MessageId: "m1", Body: `{"cluster":`}
+	if err := handleRecord(context.TODO(), awsService, "queue-url", "", record); !failure.IsPermanent(err) {
+		t.Fatalf("handleRecord() error = %v, want permanent error", err)
+	}
+
+	if len(dynamodbClient.Items) != 1 {
+		t.Fatalf("audit records = %d, want 1", len(dynamodbClient.Items))
+	}
+	var auditRecord internal.AuditRecord
+	if err := attributevalue.UnmarshalMap(dynamodbClient.Items[0], &auditRecord); err != nil {
+		t.Fatal(err)
+	}
+	if auditRecord.NotificationId != record.MessageId || auditRecord.Stage != internal.AuditStageTaskDiscovery ||
+		!auditRecord.Permanent || auditRecord.Error == "" {
+		t.Errorf("audit record = %+v, want permanent error audited by message Id", auditRecord)
+	}
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const (
+	// Notification stage audited by this Lambda
+	AuditStageTaskNotify = "TASK_NOTIFY"
+
+	// Audit records expire from the DynamoDB table after retention period
+	auditRecordRetention = 30 * 24 * time.Hour
+)
+
+// Audit record of the Notify API call of an ECS task
+type AuditRecord struct {
+	NotificationId       string `dynamodbav:"notification_id"`
+	AuditKey             string `dynamodbav:"audit_key"`
+	Stage                string `dynamodbav:"stage"`
+	RequestId            string `dynamodbav:"request_id"`
+	Cluster              string `dynamodbav:"cluster"`
+	Service              string `dynamodbav:"service"`
+	TaskArn              string `dynamodbav:"task_arn"`
+	URL                  string `dynamodbav:"url"`
+	Method               string `dynamodbav:"method"`
+	StatusCode           int    `dynamodbav:"status_code"`
+	LatencyMs            int64  `dynamodbav:"latency_ms"`
+	Attempt              int    `dynamodbav:"attempt"`
+	Attempts             int    `dynamodbav:"attempts"`
+	RequeueCount         int    `dynamodbav:"requeue_count"`
+	RequeuedDelaySeconds int    `dynamodbav:"requeued_delay_seconds,omitempty"`
+	Error                string `dynamodbav:"error,omitempty"`
+	Permanent            bool   `dynamodbav:"permanent,omitempty"`
+	CreatedAt            string `dynamodbav:"created_at"`
+	ExpiresAt            int64  `dynamodbav:"expires_at"`
+}
+
+// Audit record of a message before unmarshalling, audited by SQS message Id
+// as the notification Id of an invalid message is unknown
+func NewMessageAuditRecord(messageId string) *AuditRecord {
+	now := time.Now().UTC()
+	return &AuditRecord{
+		NotificationId: messageId,
+		AuditKey:       AuditStageTaskNotify,
+		Stage:          AuditStageTaskNotify,
+		CreatedAt:      now.Format(time.RFC3339Nano),
+		ExpiresAt:      now.Add(auditRecordRetention).Unix(),
+	}
+}
+
+func NewAuditRecord(tnm *TaskNotifyMessage) *AuditRecord {
+	now := time.Now().UTC()
+	return &AuditRecord{
+		NotificationId: tnm.NotificationId,
+		AuditKey:       AuditStageTaskNotify + "#" + tnm.NotifyTaskArn + "#" + net.JoinHostPort(tnm.NotifyMeHostAddress, tnm.NotifyMeHostPort),
+		Stage:          AuditStageTaskNotify,
+		Cluster:        tnm.Cluster,
+		Service:        tnm.Service,
+		TaskArn:        tnm.NotifyTaskArn,
+		URL:            tnm.NotifyURL(),
+		Method:         tnm.HTTPMethod(),
+		CreatedAt:      now.Format(time.RFC3339Nano),
+		ExpiresAt:      now.Add(auditRecordRetention).Unix(),
+	}
+}
+
+// Audit table name, auditing is disabled without table name
+func (awsService *AWSService) WithAuditTable(tableName string) *AWSService {
+	awsService.auditTableName = tableName
+	return awsService
+}
+
+// Persist audit record to DynamoDB, failure to audit does not fail the notification
+func (awsService *AWSService) PutAuditRecord(ctx context.Context, auditRecord *AuditRecord) {
+	if awsService.auditTableName == "" || auditRecord.NotificationId == "" {
+		return
+	}
+
+	requestId := RequestIdFromContext(ctx)
+	auditRecord.RequestId = requestId
+
+	item, err := attributevalue.MarshalMap(auditRecord)
+	if err != nil {
+		slog.Error("failed to marshal audit record", "requestId", requestId, "errorMessage", err)
+		return
+	}
+
+	_, err = awsService.dynamodbClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(awsService.auditTableName),
+		Item:      item,
+	})
+	if err != nil {
+		slog.Error("failed to put audit record", "requestId", requestId, "notificationId", auditRecord.NotificationId, "errorMessage", err)
+	}
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/dynamodblocal"
+)
+
+func TestNewAuditRecord(t *testing.T) {
+	auditRecord := NewAuditRecord(testTaskNotifyMessage())
+
+	if auditRecord.URL != "http://10.0.0.1:8080/v1.0/notify" || auditRecord.Method != "POST" {
+		t.Errorf("url = %v, method = %v", auditRecord.URL, auditRecord.Method)
+	}
+	if auditRecord.AuditKey != "TASK_NOTIFY#task-1#10.0.0.1:8080" || auditRecord.ExpiresAt == 0 {
+		t.Errorf("unexpected audit record %+v", auditRecord)
+	}
+}
+
+// Runs against DynamoDB Local, see package dynamodblocal
+func TestPutAuditRecordDynamoDBLocal(t *testing.T) {
+	ctx := context.TODO()
+	dynamodbClient := dynamodblocal.NewAuditTable(t)
+	awsService := NewAWSService(dynamodbClient, nil, nil, nil).WithAuditTable(dynamodblocal.AuditTableName)
+
+	auditRecord := NewAuditRecord(testTaskNotifyMessage())
+	auditRecord.StatusCode = 200
+	awsService.PutAuditRecord(ctx, auditRecord)
+
+	var actual AuditRecord
+	dynamodblocal.GetAuditRecord(t, dynamodbClient, "notification-1", auditRecord.AuditKey, &actual)
+	if actual.TaskArn != "task-1" || actual.StatusCode != 200 {
+		t.Errorf("unexpected audit record %+v", actual)
+	}
+}
+
+func testTaskNotifyMessage() *TaskNotifyMessage {
+	tnm := NewTaskNotifyMessage()
+	tnm.NotificationId = "notification-1"
+	tnm.NotifyTaskArn = "task-1"
+	tnm.NotifyMeHostAddress = "10.0.0.1"
+	tnm.NotifyMeHostPort = "8080"
+	tnm.NotifyMeAPIUri = "/v1.0/notify"
+	return tnm
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// Get AWSRequestId from Lambda Context Object
+func RequestIdFromContext(ctx context.Context) string {
+	var requestId string = "x"
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		requestId = lc.AwsRequestID
+	}
+	return requestId
+}
+
+type AWSService struct {
+	dynamodbClient       DynamoDBClient
+	sqsClient            SQSClient
+	secretsManagerClient SecretsManagerClient
+	ssmClient            SSMClient
+	auditTableName       string
+}
+
+func NewAWSService(dynamodbClient DynamoDBClient, sqsClient SQSClient, secretsManagerClient SecretsManagerClient, ssmClient SSMClient) *AWSService {
+	awsService := &AWSService{}
+	return awsService.
+		withDynamoDBClient(dynamodbClient).
+		withSQSClient(sqsClient).
+		withSecretsManagerClient(secretsManagerClient).
+		withSSMClient(ssmClient)
+}
+
+// AWS Service with clients of the default AWS config
+func NewAWSServiceFromConfig(ctx context.Context) (*AWSService, error) {
+	requestId := RequestIdFromContext(ctx)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		slog.Error("Failed to load default config", "requestId", requestId, "errorMessage", err)
+		return nil, err
+	}
+
+	return NewAWSService(dynamodb.NewFromConfig(cfg), sqs.NewFromConfig(cfg),
+		secretsmanager.NewFromConfig(cfg), ssm.NewFromConfig(cfg)), nil
+}
+
+func (awsService *AWSService) withDynamoDBClient(dynamodbClient DynamoDBClient) *AWSService {
+	awsService.dynamodbClient = dynamodbClient
+	return awsService
+}
+
+func (awsService *AWSService) withSQSClient(sqsClient SQSClient) *AWSService {
+	awsService.sqsClient = sqsClient
+	return awsService
+}
+
+// SQS client of the service, e.g. to route messages to the dead letter queue
+func (awsService *AWSService) SQSClient() SQSClient {
+	return awsService.sqsClient
+}
+
+func (awsService *AWSService) withSecretsManagerClient(secretsManagerClient SecretsManagerClient) *AWSService {
+	awsService.secretsManagerClient = secretsManagerClient
+	return awsService
+}
+
+func (awsService *AWSService) withSSMClient(ssmClient SSMClient) *AWSService {
+	awsService.ssmClient = ssmClient
+	return awsService
+}
@@ -0,0 +1,64 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// In-memory Secrets Manager secret strings
+type SecretsManager struct {
+	mu sync.Mutex
+
+	// Secret string by secret Id
+	Secrets map[string]string
+	// Number of GetSecretValue calls
+	Calls int
+}
+
+func NewSecretsManager() *SecretsManager {
+	return &SecretsManager{Secrets: make(map[string]string)}
+}
+
+func (fake *SecretsManager) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.Calls++
+	secret, ok := fake.Secrets[aws.ToString(params.SecretId)]
+	if !ok {
+		return nil, &smtypes.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")}
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(secret)}, nil
+}
+
+// In-memory SSM Parameter Store parameters
+type SSM struct {
+	mu sync.Mutex
+
+	// Parameter value by parameter name
+	Parameters map[string]string
+	// Number of GetParameter calls
+	Calls int
+}
+
+func NewSSM() *SSM {
+	return &SSM{Parameters: make(map[string]string)}
+}
+
+func (fake *SSM) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.Calls++
+	value, ok := fake.Parameters[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Name: params.Name, Value: aws.String(value)}}, nil
+}
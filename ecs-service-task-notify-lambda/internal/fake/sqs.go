@@ -0,0 +1,37 @@
+// Package fake provides in-memory fakes of the AWS clients used by the
+// ECS service task notify Lambda, for unit tests without AWS access
+package fake
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// In-memory SQS recording sent messages
+type SQS struct {
+	mu sync.Mutex
+
+	// Sent messages in call order
+	Messages []*sqs.SendMessageInput
+	// Error returned by SendMessage
+	Err error
+}
+
+func NewSQS() *SQS {
+	return &SQS{}
+}
+
+func (fake *SQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if fake.Err != nil {
+		return nil, fake.Err
+	}
+	fake.Messages = append(fake.Messages, params)
+	return &sqs.SendMessageOutput{MessageId: aws.String("message-" + strconv.Itoa(len(fake.Messages)))}, nil
+}
@@ -0,0 +1,339 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/signature"
+)
+
+// Notify API HTTP client timeouts, in-process retries and re-enqueue settings
+type NotifyConfig struct {
+	// Time to establish connection to the task
+	ConnectTimeout time.Duration
+	// Time to wait for Notify API response once request is sent
+	ReadTimeout time.Duration
+	// In-process attempts of the Notify API call
+	MaxAttempts int
+	// Backoff of the first retry, doubled for every following retry
+	BaseDelay time.Duration
+	// Upper bound of a single backoff
+	MaxDelay time.Duration
+	// Re-enqueues of the message once in-process attempts are exhausted
+	MaxRequeues int
+	// SQS delay of the first re-enqueue, doubled for every following re-enqueue
+	RequeueDelay time.Duration
+	// Records of a batch notified in parallel
+	Concurrency int
+}
+
+func DefaultNotifyConfig() NotifyConfig {
+	return NotifyConfig{
+		ConnectTimeout: 2 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		MaxAttempts:    3,
+		BaseDelay:      200 * time.Millisecond,
+		MaxDelay:       2 * time.Second,
+		MaxRequeues:    3,
+		RequeueDelay:   30 * time.Second,
+		Concurrency:    10,
+	}
+}
+
+// Notify config from environment variables, unset variables keep their default
+func ParseNotifyConfig(lookupEnv func(key string) (string, bool)) (NotifyConfig, error) {
+	notifyConfig := DefaultNotifyConfig()
+
+	durations := map[string]*time.Duration{
+		"NOTIFY_CONNECT_TIMEOUT":  &notifyConfig.ConnectTimeout,
+		"NOTIFY_READ_TIMEOUT":     &notifyConfig.ReadTimeout,
+		"NOTIFY_RETRY_BASE_DELAY": &notifyConfig.BaseDelay,
+		"NOTIFY_RETRY_MAX_DELAY":  &notifyConfig.MaxDelay,
+		"NOTIFY_REQUEUE_DELAY":    &notifyConfig.RequeueDelay,
+	}
+	for key, duration := range durations {
+		if value, ok := lookupEnv(key); ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				return notifyConfig, fmt.Errorf("invalid duration %s: %q", key, value)
+			}
+			*duration = parsed
+		}
+	}
+
+	counts := map[string]*int{
+		"NOTIFY_MAX_ATTEMPTS": &notifyConfig.MaxAttempts,
+		"NOTIFY_MAX_REQUEUES": &notifyConfig.MaxRequeues,
+		"NOTIFY_CONCURRENCY":  &notifyConfig.Concurrency,
+	}
+	for key, count := range counts {
+		if value, ok := lookupEnv(key); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return notifyConfig, fmt.Errorf("invalid count %s: %q", key, value)
+			}
+			*count = parsed
+		}
+	}
+	if notifyConfig.MaxAttempts == 0 {
+		return notifyConfig, errors.New("invalid count NOTIFY_MAX_ATTEMPTS: at least one attempt is required")
+	}
+	if notifyConfig.Concurrency == 0 {
+		return notifyConfig, errors.New("invalid count NOTIFY_CONCURRENCY: at least one worker is required")
+	}
+	return notifyConfig, nil
+}
+
+// Failed Notify API call
+type NotifyError struct {
+	// Status code of the last response, zero on connection errors
+	StatusCode int
+	// Retry-After of the last response, zero when not given
+	RetryAfter time.Duration
+	// Connection errors, 5xx and 429 responses are worth retrying later
+	Retryable bool
+	Err       error
+}
+
+func (notifyErr *NotifyError) Error() string {
+	return notifyErr.Err.Error()
+}
+
+func (notifyErr *NotifyError) Unwrap() error {
+	return notifyErr.Err
+}
+
+// Notify API errors not retried are permanent, other than 401 retried with a new access token
+func (notifyErr *NotifyError) Permanent() bool {
+	return !notifyErr.Retryable && notifyErr.StatusCode != http.StatusUnauthorized
+}
+
+// Outcome of the Notify API call
+type NotifyResult struct {
+	StatusCode int
+	Attempts   int
+}
+
+// Calls Notify API of ECS tasks with timeouts and jittered exponential backoff
+type Notifier struct {
+	httpClient *http.Client
+	transport  *http.Transport
+	config     NotifyConfig
+	// HTTP clients by TLS server name of services overriding the server name
+	mu                sync.Mutex
+	serverNameClients map[string]*http.Client
+	// Signs requests with the primary key when set
+	keySet *signature.KeySet
+	// Attaches bearer token to requests when set
+	tokenProvider *TokenProvider
+	// Waits between attempts, replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func NewNotifier(config NotifyConfig) *Notifier {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: config.ConnectTimeout}).DialContext
+	transport.TLSHandshakeTimeout = config.ConnectTimeout
+	transport.ResponseHeaderTimeout = config.ReadTimeout
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	return &Notifier{
+		httpClient:        newHTTPClient(transport, config),
+		transport:         transport,
+		config:            config,
+		serverNameClients: make(map[string]*http.Client),
+		sleep:             sleepContext,
+	}
+}
+
+func newHTTPClient(transport *http.Transport, config NotifyConfig) *http.Client {
+	return &http.Client{
+		Transport: transport,
+		// Bounds a single attempt including reading the response body
+		Timeout: config.ConnectTimeout + config.ReadTimeout,
+	}
+}
+
+// TLS config of https Notify API calls
+func (notifier *Notifier) WithTLSConfig(tlsConfig *tls.Config) *Notifier {
+	notifier.transport.TLSClientConfig = tlsConfig
+	return notifier
+}
+
+// HTTP client verifying the task certificate against the server name, when set
+func (notifier *Notifier) clientFor(serverName string) *http.Client {
+	if serverName == "" || notifier.transport.TLSClientConfig.ServerName == serverName {
+		return notifier.httpClient
+	}
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	client, ok := notifier.serverNameClients[serverName]
+	if !ok {
+		transport := notifier.transport.Clone()
+		transport.TLSClientConfig.ServerName = serverName
+		client = newHTTPClient(transport, notifier.config)
+		notifier.serverNameClients[serverName] = client
+	}
+	return client
+}
+
+// Sign Notify API requests with the primary key of the key set
+func (notifier *Notifier) WithKeySet(keySet *signature.KeySet) *Notifier {
+	notifier.keySet = keySet
+	return notifier
+}
+
+// Authenticate Notify API requests with OAuth2 bearer tokens of the provider
+func (notifier *Notifier) WithTokenProvider(tokenProvider *TokenProvider) *Notifier {
+	notifier.tokenProvider = tokenProvider
+	return notifier
+}
+
+func (notifier *Notifier) Config() NotifyConfig {
+	return notifier.config
+}
+
+// Release idle connections of the HTTP clients
+func (notifier *Notifier) CloseIdleConnections() {
+	notifier.httpClient.CloseIdleConnections()
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	for _, client := range notifier.serverNameClients {
+		client.CloseIdleConnections()
+	}
+}
+
+// Call Notify API of the task, retrying connection errors, 5xx and 429 responses
+func (notifier *Notifier) Notify(ctx context.Context, tnm *TaskNotifyMessage) (*NotifyResult, error) {
+	requestId := RequestIdFromContext(ctx)
+	result := &NotifyResult{}
+
+	for {
+		result.Attempts++
+		notifyErr := notifier.attempt(ctx, tnm, result)
+		if notifyErr == nil {
+			return result, nil
+		}
+		if !notifyErr.Retryable || result.Attempts >= notifier.config.MaxAttempts {
+			return result, notifyErr
+		}
+
+		delay, ok := notifier.retryDelay(ctx, result.Attempts, notifyErr.RetryAfter)
+		if !ok {
+			// Left to the re-enqueue, waiting would outlive the Retry-After bound or Lambda deadline
+			return result, notifyErr
+		}
+		slog.Warn("retrying notify API call", "requestId", requestId, "notificationId", tnm.NotificationId,
+			"attempt", result.Attempts, "delay", delay, "errorMessage", notifyErr)
+		if err := notifier.sleep(ctx, delay); err != nil {
+			return result, notifyErr
+		}
+	}
+}
+
+// Single Notify API call
+func (notifier *Notifier) attempt(ctx context.Context, tnm *TaskNotifyMessage, result *NotifyResult) *NotifyError {
+	req, err := NewNotifyRequest(ctx, tnm)
+	if err != nil {
+		return &NotifyError{Err: err}
+	}
+	if notifier.tokenProvider != nil {
+		accessToken, err := notifier.tokenProvider.Token(ctx, tnm.NotifyMeAuthAudience, tnm.NotifyMeAuthScope)
+		if err != nil {
+			return &NotifyError{Retryable: true, Err: fmt.Errorf("failed to fetch access token: %w", err)}
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	// Every attempt is signed with its own timestamp
+	if notifier.keySet != nil {
+		keyId, key := notifier.keySet.PrimaryKey()
+		if err := signature.SignRequest(req, keyId, key, time.Now()); err != nil {
+			return &NotifyError{Err: err}
+		}
+	}
+
+	resp, err := notifier.clientFor(tnm.NotifyMeTLSServerName).Do(req)
+	if err != nil {
+		return &NotifyError{Retryable: true, Err: err}
+	}
+	defer resp.Body.Close()
+	// Drain body to reuse the connection
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode == http.StatusUnauthorized && notifier.tokenProvider != nil {
+		// Revoked or rotated token, next attempt fetches a new one
+		notifier.tokenProvider.Invalidate(tnm.NotifyMeAuthAudience, tnm.NotifyMeAuthScope)
+	}
+	return &NotifyError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Retryable:  resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		Err:        fmt.Errorf("notify API responded with status code %d", resp.StatusCode),
+	}
+}
+
+// Delay before the next attempt, full jitter unless Retry-After is given
+func (notifier *Notifier) retryDelay(ctx context.Context, attempts int, retryAfter time.Duration) (time.Duration, bool) {
+	delay := retryAfter
+	if delay > notifier.config.MaxDelay {
+		return 0, false
+	}
+	if delay == 0 {
+		// Backoff of 16 attempts and more is the max delay, the shift overflows otherwise
+		backoff := notifier.config.MaxDelay
+		if attempts-1 < 16 {
+			backoff = min(notifier.config.MaxDelay, notifier.config.BaseDelay<<(attempts-1))
+		}
+		delay = rand.N(backoff + 1)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if time.Until(deadline) < delay+notifier.httpClient.Timeout {
+			return 0, false
+		}
+	}
+	return delay, true
+}
+
+// Retry-After header value in delay seconds or HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(0, time.Duration(seconds)*time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now))
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+var notifyTests = map[string]struct {
+	responses    []int
+	retryAfter   string
+	wantErr      bool
+	wantRetry    bool
+	wantAttempts int
+	wantDelays   []time.Duration
+}{
+	"first attempt succeeds":         {[]int{200}, "", false, false, 1, nil},
+	"server error retried":           {[]int{503, 500, 200}, "", false, false, 3, nil},
+	"attempts exhausted":             {[]int{503, 503, 503}, "", true, true, 3, nil},
+	"client error not retried":       {[]int{404}, "", true, false, 1, nil},
+	"too many requests honors delay": {[]int{429, 200}, "1", false, false, 2, []time.Duration{time.Second}},
+	"retry after beyond max delay":   {[]int{429}, "60", true, true, 1, []time.Duration{}},
+}
+
+func TestNotify(t *testing.T) {
+	for name, tc := range notifyTests {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(tc.responses[min(calls, len(tc.responses)-1)])
+				calls++
+			}))
+			defer server.Close()
+
+			notifier := NewNotifier(DefaultNotifyConfig())
+			delays := []time.Duration{}
+			notifier.sleep = func(ctx context.Context, d time.Duration) error {
+				delays = append(delays, d)
+				return nil
+			}
+
+			result, err := notifier.Notify(context.TODO(), serverTaskNotifyMessage(server))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Notify() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil && err.(*NotifyError).Retryable != tc.wantRetry {
+				t.Errorf("Retryable = %v, want %v", err.(*NotifyError).Retryable, tc.wantRetry)
+			}
+			if result.Attempts != tc.wantAttempts || calls != tc.wantAttempts {
+				t.Errorf("attempts = %d, calls = %d, want %d", result.Attempts, calls, tc.wantAttempts)
+			}
+			for _, delay := range delays {
+				if delay > DefaultNotifyConfig().MaxDelay {
+					t.Errorf("delay %v exceeds max delay", delay)
+				}
+			}
+			if tc.wantDelays != nil && !reflect.DeepEqual(delays, tc.wantDelays) {
+				t.Errorf("delays = %v, want %v", delays, tc.wantDelays)
+			}
+		})
+	}
+}
+
+func TestNotifyManyAttempts(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		calls++
+	}))
+	defer server.Close()
+
+	notifyConfig := DefaultNotifyConfig()
+	notifyConfig.MaxAttempts = 64
+	notifier := NewNotifier(notifyConfig)
+	delays := []time.Duration{}
+	notifier.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	result, err := notifier.Notify(context.TODO(), serverTaskNotifyMessage(server))
+	if err == nil || !err.(*NotifyError).Retryable {
+		t.Fatalf("Notify() error = %v, want retryable error", err)
+	}
+	if result.Attempts != 64 || calls != 64 {
+		t.Errorf("attempts = %d, calls = %d, want 64", result.Attempts, calls)
+	}
+	for _, delay := range delays {
+		if delay < 0 || delay > notifyConfig.MaxDelay {
+			t.Errorf("delay %v out of range of max delay %v", delay, notifyConfig.MaxDelay)
+		}
+	}
+}
+
+func TestNotifyReadTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	notifyConfig := DefaultNotifyConfig()
+	notifyConfig.ReadTimeout = 50 * time.Millisecond
+	notifyConfig.MaxAttempts = 1
+	notifier := NewNotifier(notifyConfig)
+
+	start := time.Now()
+	_, err := notifier.Notify(context.TODO(), serverTaskNotifyMessage(server))
+	if err == nil || !err.(*NotifyError).Retryable {
+		t.Fatalf("Notify() error = %v, want retryable timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Notify() took %v, read timeout not applied", elapsed)
+	}
+}
+
+func TestNotifyStopsRetryingNearDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(DefaultNotifyConfig())
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+
+	result, err := notifier.Notify(ctx, serverTaskNotifyMessage(server))
+	if err == nil || result.Attempts != 1 {
+		t.Errorf("Notify() attempts = %d, error = %v, want single attempt", result.Attempts, err)
+	}
+}
+
+var parseNotifyConfigTests = map[string]struct {
+	env     map[string]string
+	want    func(*NotifyConfig)
+	wantErr bool
+}{
+	"defaults": {map[string]string{}, func(*NotifyConfig) {}, false},
+	"overrides": {
+		map[string]string{"NOTIFY_CONNECT_TIMEOUT": "500ms", "NOTIFY_MAX_ATTEMPTS": "5", "NOTIFY_MAX_REQUEUES": "0", "NOTIFY_CONCURRENCY": "4"},
+		func(notifyConfig *NotifyConfig) {
+			notifyConfig.ConnectTimeout = 500 * time.Millisecond
+			notifyConfig.MaxAttempts = 5
+			notifyConfig.MaxRequeues = 0
+			notifyConfig.Concurrency = 4
+		},
+		false,
+	},
+	"invalid duration": {map[string]string{"NOTIFY_READ_TIMEOUT": "3"}, nil, true},
+	"invalid count":    {map[string]string{"NOTIFY_MAX_ATTEMPTS": "-1"}, nil, true},
+	"zero attempts":    {map[string]string{"NOTIFY_MAX_ATTEMPTS": "0"}, nil, true},
+	"zero concurrency": {map[string]string{"NOTIFY_CONCURRENCY": "0"}, nil, true},
+}
+
+func TestParseNotifyConfig(t *testing.T) {
+	for name, tc := range parseNotifyConfigTests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseNotifyConfig(func(key string) (string, bool) {
+				value, ok := tc.env[key]
+				return value, ok
+			})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseNotifyConfig() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			want := DefaultNotifyConfig()
+			tc.want(&want)
+			if actual != want {
+				t.Errorf("ParseNotifyConfig() = %+v, want %+v", actual, want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"5":                             5 * time.Second,
+		"-5":                            0,
+		"Mon, 01 Apr 2024 10:00:30 GMT": 30 * time.Second,
+		"Mon, 01 Apr 2024 09:00:00 GMT": 0,
+		"soon":                          0,
+	}
+	for value, want := range tests {
+		if actual := parseRetryAfter(value, now); actual != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, actual, want)
+		}
+	}
+}
+
+func serverTaskNotifyMessage(server *httptest.Server) *TaskNotifyMessage {
+	serverURL, _ := url.Parse(server.URL)
+	tnm := testTaskNotifyMessage()
+	tnm.NotifyMeHostAddress = serverURL.Hostname()
+	tnm.NotifyMeHostPort = serverURL.Port()
+	return tnm
+}
+
+var notifyErrorPermanentTests = map[string]struct {
+	err  error
+	want bool
+}{
+	"invalid message":  {fmt.Errorf("record: %w", failure.Permanent(&json.SyntaxError{})), true},
+	"client error":     {&NotifyError{StatusCode: 404}, true},
+	"invalid request":  {&NotifyError{Err: errors.New("invalid URL")}, true},
+	"unauthorized":     {&NotifyError{StatusCode: 401}, false},
+	"server error":     {&NotifyError{StatusCode: 503, Retryable: true}, false},
+	"connection error": {&NotifyError{Retryable: true, Err: errors.New("connection refused")}, false},
+}
+
+func TestNotifyErrorPermanent(t *testing.T) {
+	for name, tc := range notifyErrorPermanentTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := failure.IsPermanent(tc.err); actual != tc.want {
+				t.Errorf("IsPermanent(%v) = %v, want %v", tc.err, actual, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Request header carrying the notification Id, lets the Notify API detect duplicates
+const NotificationIdHeader = "X-Notification-Id"
+
+// Request header carrying the notification topic, lets one container route events internally
+const NotificationTopicHeader = "X-Notification-Topic"
+
+// Protocols supported by the Notify API
+const (
+	ProtocolHTTP  = "http"
+	ProtocolHTTPS = "https"
+)
+
+// Notify API URL of the ECS Task
+// Protocol - Private IP address - Host Port - Notify URI
+func (tnm *TaskNotifyMessage) NotifyURL() string {
+	return fmt.Sprintf("%s://%s%s", tnm.Protocol(),
+		net.JoinHostPort(tnm.NotifyMeHostAddress, tnm.NotifyMeHostPort), tnm.NotifyMeAPIUri)
+}
+
+// Protocol to call Notify API with, http unless configured otherwise
+func (tnm *TaskNotifyMessage) Protocol() string {
+	if tnm.NotifyMeProtocol == "" {
+		return ProtocolHTTP
+	}
+	return tnm.NotifyMeProtocol
+}
+
+// HTTP method to call Notify API with, POST unless configured otherwise
+func (tnm *TaskNotifyMessage) HTTPMethod() string {
+	if tnm.NotifyMeHTTPMethod == "" {
+		return http.MethodPost
+	}
+	return tnm.NotifyMeHTTPMethod
+}
+
+// Build Notify API request carrying the event payload as JSON body
+func NewNotifyRequest(ctx context.Context, tnm *TaskNotifyMessage) (*http.Request, error) {
+	method := tnm.HTTPMethod()
+
+	var body io.Reader
+	if method != http.MethodGet && method != http.MethodHead {
+		payload := tnm.Payload
+		if len(payload) == 0 {
+			payload = []byte("{}")
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, tnm.NotifyURL(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tnm.NotificationId != "" {
+		req.Header.Set(NotificationIdHeader, tnm.NotificationId)
+	}
+	if tnm.Topic != "" {
+		req.Header.Set(NotificationTopicHeader, tnm.Topic)
+	}
+	return req, nil
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+var notifyRequestTests = map[string]struct {
+	method      string
+	payload     string
+	wantMethod  string
+	wantBody    string
+	wantContent string
+}{
+	"default method posts payload": {"", `{"version":"2"}`, http.MethodPost, `{"version":"2"}`, "application/json"},
+	"default method empty payload": {"", "", http.MethodPost, `{}`, "application/json"},
+	"put method posts payload":     {http.MethodPut, `{"a":1}`, http.MethodPut, `{"a":1}`, "application/json"},
+	"get method has no body":       {http.MethodGet, `{"a":1}`, http.MethodGet, "", ""},
+}
+
+func TestNewNotifyRequest(t *testing.T) {
+	ctx := context.TODO()
+
+	for name, tc := range notifyRequestTests {
+		t.Run(name, func(t *testing.T) {
+			tnm := NewTaskNotifyMessage()
+			tnm.NotifyMeHostAddress = "10.0.0.1"
+			tnm.NotifyMeHostPort = "8080"
+			tnm.NotifyMeAPIUri = "/v1.0/notify"
+			tnm.NotifyMeHTTPMethod = tc.method
+			tnm.NotificationId = "notification-1"
+			tnm.Topic = "config.reload"
+			if tc.payload != "" {
+				tnm.Payload = json.RawMessage(tc.payload)
+			}
+
+			req, err := NewNotifyRequest(ctx, tnm)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if req.Method != tc.wantMethod {
+				t.Errorf("method = %s, want %s", req.Method, tc.wantMethod)
+			}
+			if req.URL.String() != "http://10.0.0.1:8080/v1.0/notify" {
+				t.Errorf("url = %s", req.URL.String())
+			}
+			if got := req.Header.Get(NotificationIdHeader); got != "notification-1" {
+				t.Errorf("notification id header = %q", got)
+			}
+			if got := req.Header.Get(NotificationTopicHeader); got != "config.reload" {
+				t.Errorf("notification topic header = %q", got)
+			}
+			if got := req.Header.Get("Content-Type"); got != tc.wantContent {
+				t.Errorf("content-type = %q, want %q", got, tc.wantContent)
+			}
+
+			var body []byte
+			if req.Body != nil {
+				body, _ = io.ReadAll(req.Body)
+			}
+			if string(body) != tc.wantBody {
+				t.Errorf("body = %q, want %q", body, tc.wantBody)
+			}
+		})
+	}
+}
+
+var notifyURLTests = map[string]struct {
+	protocol string
+	address  string
+	want     string
+}{
+	"default protocol": {"", "10.0.0.1", "http://10.0.0.1:8080/v1.0/notify"},
+	"https protocol":   {"https", "10.0.0.1", "https://10.0.0.1:8080/v1.0/notify"},
+	"ipv6 address":     {"https", "fd00::1", "https://[fd00::1]:8080/v1.0/notify"},
+}
+
+func TestNotifyURL(t *testing.T) {
+	for name, tc := range notifyURLTests {
+		t.Run(name, func(t *testing.T) {
+			tnm := &TaskNotifyMessage{NotifyMeProtocol: tc.protocol, NotifyMeHostAddress: tc.address,
+				NotifyMeHostPort: "8080", NotifyMeAPIUri: "/v1.0/notify"}
+			if actual := tnm.NotifyURL(); actual != tc.want {
+				t.Errorf("NotifyURL() = %s, want %s", actual, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Notify API authentication modes
+	AuthModeNone   = "none"
+	AuthModeOAuth2 = "oauth2"
+
+	// Access tokens are refreshed ahead of expiry, so that no request carries an expiring token
+	tokenExpiryDelta = 30 * time.Second
+	// Lifetime of access tokens issued without expires_in
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+// OAuth2 client credentials stored as JSON in Secrets Manager
+type ClientCredentials struct {
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// Get OAuth2 client credentials stored as JSON in the secret source
+func (awsService *AWSService) ClientCredentials(ctx context.Context, source SecretSource) (*ClientCredentials, error) {
+	value, err := awsService.SecretValue(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials ClientCredentials
+	if err := json.Unmarshal([]byte(value), &credentials); err != nil {
+		slog.Error("failed to unmarshal client credentials", "requestId", RequestIdFromContext(ctx), "errorMessage", err)
+		return nil, err
+	}
+	if credentials.ClientId == "" || credentials.ClientSecret == "" {
+		return nil, errors.New("client credentials without client_id or client_secret")
+	}
+	return &credentials, nil
+}
+
+// Fetches OAuth2 access tokens with the client credentials grant, per audience and scope
+type TokenProvider struct {
+	tokenURL    string
+	credentials ClientCredentials
+	// Audience and scope of services without docker labels
+	defaultAudience string
+	defaultScope    string
+	httpClient      *http.Client
+}
+
+func NewTokenProvider(tokenURL string, credentials ClientCredentials, defaultAudience string, defaultScope string) *TokenProvider {
+	return &TokenProvider{
+		tokenURL:        tokenURL,
+		credentials:     credentials,
+		defaultAudience: defaultAudience,
+		defaultScope:    defaultScope,
+		httpClient:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type tokenKey struct {
+	tokenURL string
+	clientId string
+	audience string
+	scope    string
+}
+
+type cachedToken struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+// Access tokens by token endpoint, client, audience and scope, cached across warm invocations
+var tokenCache = struct {
+	mu      sync.Mutex
+	entries map[tokenKey]cachedToken
+}{entries: make(map[tokenKey]cachedToken)}
+
+// Token requests in flight, a cache miss fetches one token per key
+var tokenFetches singleFlight[tokenKey, string]
+
+func (provider *TokenProvider) key(audience string, scope string) tokenKey {
+	if audience == "" {
+		audience = provider.defaultAudience
+	}
+	if scope == "" {
+		scope = provider.defaultScope
+	}
+	return tokenKey{tokenURL: provider.tokenURL, clientId: provider.credentials.ClientId, audience: audience, scope: scope}
+}
+
+// Get access token of the audience and scope, cached until shortly before expiry.
+// The cache is not locked while fetching, tokens of other keys are served meanwhile
+func (provider *TokenProvider) Token(ctx context.Context, audience string, scope string) (string, error) {
+	key := provider.key(audience, scope)
+
+	tokenCache.mu.Lock()
+	cached, ok := tokenCache.entries[key]
+	tokenCache.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.accessToken, nil
+	}
+
+	return tokenFetches.do(ctx, key, func() (string, error) {
+		token, err := provider.fetchToken(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		tokenCache.mu.Lock()
+		tokenCache.entries[key] = token
+		tokenCache.mu.Unlock()
+		return token.accessToken, nil
+	})
+}
+
+// Drop cached access token rejected by the Notify API
+func (provider *TokenProvider) Invalidate(audience string, scope string) {
+	tokenCache.mu.Lock()
+	defer tokenCache.mu.Unlock()
+	delete(tokenCache.entries, provider.key(audience, scope))
+}
+
+// OAuth2 token endpoint response
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (provider *TokenProvider) fetchToken(ctx context.Context, key tokenKey) (cachedToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if key.audience != "" {
+		form.Set("audience", key.audience)
+	}
+	if key.scope != "" {
+		form.Set("scope", key.scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return cachedToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(provider.credentials.ClientId), url.QueryEscape(provider.credentials.ClientSecret))
+
+	resp, err := provider.httpClient.Do(req)
+	if err != nil {
+		return cachedToken{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return cachedToken{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return cachedToken{}, fmt.Errorf("token endpoint responded with status code %d", resp.StatusCode)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return cachedToken{}, err
+	}
+	if token.AccessToken == "" || (token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer")) {
+		return cachedToken{}, fmt.Errorf("token endpoint returned no bearer token")
+	}
+
+	lifetime := defaultTokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+	return cachedToken{
+		accessToken: token.AccessToken,
+		expiresAt:   time.Now().Add(lifetime - min(tokenExpiryDelta, lifetime/2)),
+	}, nil
+}
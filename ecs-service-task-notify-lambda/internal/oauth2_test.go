@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/internal/fake"
+)
+
+// Token endpoint issuing numbered tokens per audience and scope
+func newTokenServer(t *testing.T, status int, tokenType string) (*httptest.Server, *int) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok || clientId != "notifier" || clientSecret != "s3cret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("%s|%s|%d", r.FormValue("audience"), r.FormValue("scope"), calls),
+			"token_type":   tokenType,
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func resetTokenCache(t *testing.T) {
+	tokenCache.mu.Lock()
+	defer tokenCache.mu.Unlock()
+	clear(tokenCache.entries)
+	t.Cleanup(func() {
+		tokenCache.mu.Lock()
+		defer tokenCache.mu.Unlock()
+		clear(tokenCache.entries)
+	})
+}
+
+func TestTokenProvider(t *testing.T) {
+	resetTokenCache(t)
+	server, calls := newTokenServer(t, http.StatusOK, "Bearer")
+	provider := NewTokenProvider(server.URL, ClientCredentials{ClientId: "notifier", ClientSecret: "s3cret"}, "default-aud", "")
+	ctx := context.TODO()
+
+	tokens := []struct {
+		audience string
+		scope    string
+		want     string
+	}{
+		{"", "", "default-aud||1"},
+		{"", "", "default-aud||1"},
+		{"svc-a", "notify:write", "svc-a|notify:write|2"},
+		{"svc-a", "notify:write", "svc-a|notify:write|2"},
+		{"default-aud", "", "default-aud||1"},
+	}
+	for _, token := range tokens {
+		actual, err := provider.Token(ctx, token.audience, token.scope)
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if actual != token.want {
+			t.Errorf("Token(%q, %q) = %s, want %s", token.audience, token.scope, actual, token.want)
+		}
+	}
+
+	provider.Invalidate("svc-a", "notify:write")
+	if actual, _ := provider.Token(ctx, "svc-a", "notify:write"); actual != "svc-a|notify:write|3" || *calls != 3 {
+		t.Errorf("Token() after Invalidate = %s, calls = %d", actual, *calls)
+	}
+}
+
+func TestTokenProviderFetchDoesNotBlockCachedTokens(t *testing.T) {
+	resetTokenCache(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("audience") == "slow" {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": r.FormValue("audience"), "expires_in": 3600})
+	}))
+	t.Cleanup(server.Close)
+	defer close(release)
+	provider := NewTokenProvider(server.URL, ClientCredentials{ClientId: "notifier", ClientSecret: "s3cret"}, "", "")
+	ctx := context.TODO()
+
+	if _, err := provider.Token(ctx, "fast", ""); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	go provider.Token(ctx, "slow", "")
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan string)
+	go func() {
+		token, _ := provider.Token(ctx, "fast", "")
+		done <- token
+	}()
+	select {
+	case token := <-done:
+		if token != "fast" {
+			t.Errorf("Token() = %s, want fast", token)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Token() of a cached audience blocked by a token fetch of another audience")
+	}
+}
+
+var tokenProviderErrorTests = map[string]struct {
+	status       int
+	tokenType    string
+	clientSecret string
+}{
+	"invalid client":     {http.StatusOK, "Bearer", "wrong"},
+	"endpoint error":     {http.StatusBadRequest, "Bearer", "s3cret"},
+	"not a bearer token": {http.StatusOK, "mac", "s3cret"},
+}
+
+func TestTokenProviderErrors(t *testing.T) {
+	for name, tc := range tokenProviderErrorTests {
+		t.Run(name, func(t *testing.T) {
+			resetTokenCache(t)
+			server, _ := newTokenServer(t, tc.status, tc.tokenType)
+			provider := NewTokenProvider(server.URL, ClientCredentials{ClientId: "notifier", ClientSecret: tc.clientSecret}, "", "")
+
+			if _, err := provider.Token(context.TODO(), "svc-a", ""); err == nil {
+				t.Error("Token() expected error")
+			}
+		})
+	}
+}
+
+func TestNotifyBearerToken(t *testing.T) {
+	resetTokenCache(t)
+	tokenServer, calls := newTokenServer(t, http.StatusOK, "Bearer")
+	provider := NewTokenProvider(tokenServer.URL, ClientCredentials{ClientId: "notifier", ClientSecret: "s3cret"}, "", "")
+
+	// Notify API rejects the first token as revoked
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer svc-a|notify:write|2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(DefaultNotifyConfig()).WithTokenProvider(provider)
+	tnm := serverTaskNotifyMessage(server)
+	tnm.NotifyMeAuthAudience = "svc-a"
+	tnm.NotifyMeAuthScope = "notify:write"
+
+	if _, err := notifier.Notify(context.TODO(), tnm); err == nil {
+		t.Fatal("Notify() expected unauthorized error")
+	}
+	if _, err := notifier.Notify(context.TODO(), tnm); err != nil {
+		t.Fatalf("Notify() with new token error = %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("token endpoint calls = %d, want 2", *calls)
+	}
+}
+
+var clientCredentialsTests = map[string]struct {
+	value   string
+	wantErr bool
+}{
+	"valid credentials":     {`{"client_id":"notifier","client_secret":"s3cret"}`, false},
+	"missing client secret": {`{"client_id":"notifier"}`, true},
+	"invalid json":          {`notifier:s3cret`, true},
+}
+
+func TestClientCredentials(t *testing.T) {
+	for name, tc := range clientCredentialsTests {
+		t.Run(name, func(t *testing.T) {
+			resetSecretValueCache(t)
+			secretsManagerClient := fake.NewSecretsManager()
+			secretsManagerClient.Secrets["oauth2-client"] = tc.value
+			awsService := NewAWSService(fake.NewDynamoDB(), fake.NewSQS(), secretsManagerClient, fake.NewSSM())
+
+			_, err := awsService.ClientCredentials(context.TODO(), SecretSource{SecretId: "oauth2-client"})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ClientCredentials() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// Message attribute counting re-enqueues of the task notify message
+	RequeueCountAttribute = "NotifyRequeueCount"
+
+	// SQS maximum message delay
+	maxRequeueDelay = 15 * time.Minute
+)
+
+// Re-enqueues of the SQS message so far
+func RequeueCount(record events.SQSMessage) int {
+	attribute, ok := record.MessageAttributes[RequeueCountAttribute]
+	if !ok || attribute.StringValue == nil {
+		return 0
+	}
+	requeueCount, _ := strconv.Atoi(*attribute.StringValue)
+	return requeueCount
+}
+
+// SQS delay of the re-enqueue, exponential on re-enqueues and at least Retry-After
+func RequeueDelay(config NotifyConfig, requeueCount int, retryAfter time.Duration) time.Duration {
+	delay := maxRequeueDelay
+	if requeueCount < 16 {
+		delay = min(maxRequeueDelay, config.RequeueDelay<<requeueCount)
+	}
+	return min(maxRequeueDelay, max(delay, retryAfter))
+}
+
+// Send task notify message back to the queue to be delivered after delay
+func (awsService *AWSService) RequeueMessage(ctx context.Context, sqsQueueURL string, messageBody string, requeueCount int, delay time.Duration) (*string, error) {
+	requestId := RequestIdFromContext(ctx)
+
+	sendMsgOutput, err := awsService.sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:     aws.String(sqsQueueURL),
+		MessageBody:  aws.String(messageBody),
+		DelaySeconds: int32(delay / time.Second),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			RequeueCountAttribute: {
+				DataType:    aws.String("Number"),
+				StringValue: aws.String(strconv.Itoa(requeueCount)),
+			},
+		},
+	})
+	if err != nil {
+		slog.Error("failed to re-enqueue message to SQS", "requestId", requestId, "errorMessage", err)
+		return nil, err
+	}
+	return sendMsgOutput.MessageId, nil
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/internal/fake"
+)
+
+var requeueDelayTests = map[string]struct {
+	requeueCount int
+	retryAfter   time.Duration
+	want         time.Duration
+}{
+	"first requeue":          {0, 0, 30 * time.Second},
+	"third requeue":          {2, 0, 2 * time.Minute},
+	"retry after longer":     {0, 90 * time.Second, 90 * time.Second},
+	"capped at max delay":    {10, 0, 15 * time.Minute},
+	"retry after capped too": {0, time.Hour, 15 * time.Minute},
+}
+
+func TestRequeueDelay(t *testing.T) {
+	for name, tc := range requeueDelayTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := RequeueDelay(DefaultNotifyConfig(), tc.requeueCount, tc.retryAfter); actual != tc.want {
+				t.Errorf("RequeueDelay() = %v, want %v", actual, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequeueMessage(t *testing.T) {
+	sqsClient := fake.NewSQS()
+	awsService := NewAWSService(fake.NewDynamoDB(), sqsClient, nil, nil)
+
+	if _, err := awsService.RequeueMessage(context.TODO(), "queue-url", `{"notify_task_arn":"task-1"}`, 2, 60*time.Second); err != nil {
+		t.Fatalf("RequeueMessage() error = %v", err)
+	}
+
+	sent := sqsClient.Messages[0]
+	if sent.DelaySeconds != 60 || aws.ToString(sent.MessageBody) != `{"notify_task_arn":"task-1"}` {
+		t.Errorf("unexpected message %+v", sent)
+	}
+
+	record := events.SQSMessage{MessageAttributes: map[string]events.SQSMessageAttribute{
+		RequeueCountAttribute: {StringValue: sent.MessageAttributes[RequeueCountAttribute].StringValue, DataType: "Number"},
+	}}
+	if actual := RequeueCount(record); actual != 2 {
+		t.Errorf("RequeueCount() = %d, want 2", actual)
+	}
+}
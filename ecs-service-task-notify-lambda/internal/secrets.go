@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// Secret values are reloaded after TTL, so that rotated secrets are picked up by warm Lambdas
+const secretValueTTL = 5 * time.Minute
+
+// Secrets Manager secret or SSM SecureString parameter
+type SecretSource struct {
+	SecretId      string
+	ParameterName string
+}
+
+// Secret source from environment variables named by prefix, e.g. NOTIFY_SIGNING
+// reads NOTIFY_SIGNING_SECRET_ID and NOTIFY_SIGNING_PARAMETER_NAME
+func SecretSourceFromEnv(lookupEnv func(key string) (string, bool), prefix string) SecretSource {
+	secretId, _ := lookupEnv(prefix + "_SECRET_ID")
+	parameterName, _ := lookupEnv(prefix + "_PARAMETER_NAME")
+	return SecretSource{SecretId: secretId, ParameterName: parameterName}
+}
+
+// Secret source is optional, disabled unless configured
+func (source SecretSource) Enabled() bool {
+	return source.SecretId != "" || source.ParameterName != ""
+}
+
+type cachedSecretValue struct {
+	value     string
+	expiresAt time.Time
+}
+
+// Secret values by source, cached across warm invocations
+var secretValueCache = struct {
+	mu      sync.Mutex
+	entries map[SecretSource]cachedSecretValue
+}{entries: make(map[SecretSource]cachedSecretValue)}
+
+// Secret values loading, a cache miss loads one value per source
+var secretValueLoads singleFlight[SecretSource, string]
+
+// Get secret value of the source, cached for secretValueTTL. The cache is not
+// locked while loading, values of other sources are served meanwhile
+func (awsService *AWSService) SecretValue(ctx context.Context, source SecretSource) (string, error) {
+	secretValueCache.mu.Lock()
+	cached, ok := secretValueCache.entries[source]
+	secretValueCache.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.value, nil
+	}
+
+	return secretValueLoads.do(ctx, source, func() (string, error) {
+		value, err := awsService.loadSecretValue(ctx, source)
+		if err != nil {
+			return "", err
+		}
+		secretValueCache.mu.Lock()
+		secretValueCache.entries[source] = cachedSecretValue{value: value, expiresAt: time.Now().Add(secretValueTTL)}
+		secretValueCache.mu.Unlock()
+		return value, nil
+	})
+}
+
+func (awsService *AWSService) loadSecretValue(ctx context.Context, source SecretSource) (string, error) {
+	requestId := RequestIdFromContext(ctx)
+
+	switch {
+	case source.SecretId != "":
+		output, err := awsService.secretsManagerClient.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(source.SecretId),
+		})
+		if err != nil {
+			slog.Error("failed to get secret value", "requestId", requestId, "secretId", source.SecretId, "errorMessage", err)
+			return "", err
+		}
+		return aws.ToString(output.SecretString), nil
+	case source.ParameterName != "":
+		output, err := awsService.ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(source.ParameterName),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			slog.Error("failed to get parameter value", "requestId", requestId, "parameterName", source.ParameterName, "errorMessage", err)
+			return "", err
+		}
+		return aws.ToString(output.Parameter.Value), nil
+	}
+	return "", errors.New("secret source not configured")
+}
@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/signature"
+)
+
+// Get signing key set stored as JSON in the secret source
+func (awsService *AWSService) SigningKeySet(ctx context.Context, source SecretSource) (*signature.KeySet, error) {
+	value, err := awsService.SecretValue(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	keySet, err := signature.ParseKeySet([]byte(value))
+	if err != nil {
+		slog.Error("failed to parse signing key set", "requestId", RequestIdFromContext(ctx), "errorMessage", err)
+		return nil, err
+	}
+	return keySet, nil
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/internal/fake"
+)
+
+const testKeySetValue = `{"primary":"key-2","keys":{"key-1":"secret-1","key-2":"secret-2"}}`
+
+var signingKeySetTests = map[string]struct {
+	source  SecretSource
+	wantErr bool
+}{
+	"secrets manager secret": {SecretSource{SecretId: "signing-keys"}, false},
+	"ssm parameter":          {SecretSource{ParameterName: "/notifier/signing-keys"}, false},
+	"missing secret":         {SecretSource{SecretId: "missing"}, true},
+	"invalid key set":        {SecretSource{SecretId: "invalid"}, true},
+}
+
+func TestSigningKeySet(t *testing.T) {
+	for name, tc := range signingKeySetTests {
+		t.Run(name, func(t *testing.T) {
+			resetSecretValueCache(t)
+			secretsManagerClient, ssmClient := fake.NewSecretsManager(), fake.NewSSM()
+			secretsManagerClient.Secrets["signing-keys"] = testKeySetValue
+			secretsManagerClient.Secrets["invalid"] = `{"primary":"key-3","keys":{}}`
+			ssmClient.Parameters["/notifier/signing-keys"] = testKeySetValue
+			awsService := NewAWSService(fake.NewDynamoDB(), fake.NewSQS(), secretsManagerClient, ssmClient)
+
+			for range 2 {
+				keySet, err := awsService.SigningKeySet(context.TODO(), tc.source)
+				if (err != nil) != tc.wantErr {
+					t.Fatalf("SigningKeySet() error = %v, wantErr %v", err, tc.wantErr)
+				}
+				if err == nil && keySet.Primary != "key-2" {
+					t.Errorf("primary key = %s, want key-2", keySet.Primary)
+				}
+			}
+
+			// Loaded key set is cached across invocations
+			if !tc.wantErr && secretsManagerClient.Calls+ssmClient.Calls != 1 {
+				t.Errorf("key set loaded %d times, want once", secretsManagerClient.Calls+ssmClient.Calls)
+			}
+		})
+	}
+}
+
+func resetSecretValueCache(t *testing.T) {
+	secretValueCache.mu.Lock()
+	defer secretValueCache.mu.Unlock()
+	clear(secretValueCache.entries)
+	t.Cleanup(func() {
+		secretValueCache.mu.Lock()
+		defer secretValueCache.mu.Unlock()
+		clear(secretValueCache.entries)
+	})
+}
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"context"
+	"sync"
+)
+
+// Call in flight of a single flight group, done is closed once value and err are set
+type flightCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Concurrent loads of the same key share one call, loads of other keys are not held back
+type singleFlight[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*flightCall[V]
+}
+
+// Load the value of the key, waiting for the load in flight of the same key if any
+func (group *singleFlight[K, V]) do(ctx context.Context, key K, load func() (V, error)) (V, error) {
+	group.mu.Lock()
+	if group.calls == nil {
+		group.calls = make(map[K]*flightCall[V])
+	}
+	if call, ok := group.calls[key]; ok {
+		group.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+	call := &flightCall[V]{done: make(chan struct{})}
+	group.calls[key] = call
+	group.mu.Unlock()
+
+	call.value, call.err = load()
+
+	group.mu.Lock()
+	delete(group.calls, key)
+	group.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
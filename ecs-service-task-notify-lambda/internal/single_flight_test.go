@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleFlightSharesLoadOfKey(t *testing.T) {
+	var group singleFlight[string, int]
+	var loads atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	values := make([]int, 5)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = group.do(context.TODO(), "key", func() (int, error) {
+				loads.Add(1)
+				<-release
+				return 42, nil
+			})
+		}(i)
+	}
+
+	// Let the goroutines join the load in flight
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads.Load() != 1 {
+		t.Errorf("loads = %d, want 1", loads.Load())
+	}
+	for i, value := range values {
+		if value != 42 {
+			t.Errorf("value %d = %d, want 42", i, value)
+		}
+	}
+}
+
+func TestSingleFlightDoesNotHoldBackOtherKeys(t *testing.T) {
+	var group singleFlight[string, int]
+	release := make(chan struct{})
+	defer close(release)
+
+	go group.do(context.TODO(), "slow", func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan int)
+	go func() {
+		value, _ := group.do(context.TODO(), "fast", func() (int, error) { return 2, nil })
+		done <- value
+	}()
+	select {
+	case value := <-done:
+		if value != 2 {
+			t.Errorf("value = %d, want 2", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("load of another key held back by the load in flight")
+	}
+
+	// Waiters of the load in flight give up with their context
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := group.do(ctx, "slow", func() (int, error) { return 3, nil }); err != context.DeadlineExceeded {
+		t.Errorf("do() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
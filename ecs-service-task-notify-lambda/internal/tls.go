@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"log/slog"
+)
+
+// Client certificate secret of mutual TLS, PEM encoded
+type ClientCertificate struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// TLS config of https Notify API calls. Tasks are addressed by private IP address,
+// so the CA bundle and server name verify certificates not issued for the address.
+// Root CAs of the system are trusted unless CA bundle source is set, client
+// certificate is presented for mutual TLS when its source is set.
+func (awsService *AWSService) NotifyTLSConfig(ctx context.Context, caSource SecretSource, clientCertSource SecretSource, serverName string) (*tls.Config, error) {
+	requestId := RequestIdFromContext(ctx)
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if caSource.Enabled() {
+		caBundle, err := awsService.SecretValue(ctx, caSource)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM([]byte(caBundle)) {
+			slog.Error("failed to parse CA bundle", "requestId", requestId)
+			return nil, errors.New("CA bundle without PEM encoded certificates")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if clientCertSource.Enabled() {
+		value, err := awsService.SecretValue(ctx, clientCertSource)
+		if err != nil {
+			return nil, err
+		}
+		var clientCertificate ClientCertificate
+		if err := json.Unmarshal([]byte(value), &clientCertificate); err != nil {
+			slog.Error("failed to unmarshal client certificate", "requestId", requestId, "errorMessage", err)
+			return nil, err
+		}
+		certificate, err := tls.X509KeyPair([]byte(clientCertificate.Certificate), []byte(clientCertificate.PrivateKey))
+		if err != nil {
+			slog.Error("failed to parse client certificate", "requestId", requestId, "errorMessage", err)
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/internal/fake"
+)
+
+// Self-signed client certificate and key, PEM encoded
+func newClientCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ecs-service-task-notify-lambda"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+// TLS server requiring the client certificate, its certificate is valid for example.com and 127.0.0.1
+func newMutualTLSServer(t *testing.T, clientCertPEM string) *httptest.Server {
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM([]byte(clientCertPEM))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serverCABundle(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func TestNotifyMutualTLS(t *testing.T) {
+	clientCertPEM, clientKeyPEM := newClientCertificate(t)
+	server := newMutualTLSServer(t, clientCertPEM)
+	clientCertificate, _ := json.Marshal(ClientCertificate{Certificate: clientCertPEM, PrivateKey: clientKeyPEM})
+
+	tests := map[string]struct {
+		caSecret         string
+		clientCertSecret string
+		serverName       string
+		wantErr          bool
+	}{
+		"ca bundle and client certificate": {"ca", "client-cert", "", false},
+		"server name override":             {"ca", "client-cert", "example.com", false},
+		"server name mismatch":             {"ca", "client-cert", "notify.svc.internal", true},
+		"missing client certificate":       {"ca", "", "", true},
+		"untrusted server certificate":     {"", "client-cert", "", true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			resetSecretValueCache(t)
+			secretsManagerClient := fake.NewSecretsManager()
+			secretsManagerClient.Secrets["ca"] = serverCABundle(server)
+			secretsManagerClient.Secrets["client-cert"] = string(clientCertificate)
+			awsService := NewAWSService(fake.NewDynamoDB(), fake.NewSQS(), secretsManagerClient, fake.NewSSM())
+
+			tlsConfig, err := awsService.NotifyTLSConfig(context.TODO(),
+				SecretSource{SecretId: tc.caSecret}, SecretSource{SecretId: tc.clientCertSecret}, "")
+			if err != nil {
+				t.Fatalf("NotifyTLSConfig() error = %v", err)
+			}
+
+			notifyConfig := DefaultNotifyConfig()
+			notifyConfig.MaxAttempts = 1
+			notifier := NewNotifier(notifyConfig).WithTLSConfig(tlsConfig)
+			defer notifier.CloseIdleConnections()
+
+			tnm := serverTaskNotifyMessage(server)
+			tnm.NotifyMeProtocol = ProtocolHTTPS
+			tnm.NotifyMeTLSServerName = tc.serverName
+
+			if _, err := notifier.Notify(context.TODO(), tnm); (err != nil) != tc.wantErr {
+				t.Errorf("Notify() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotifyTLSConfigInvalidSecrets(t *testing.T) {
+	resetSecretValueCache(t)
+	secretsManagerClient := fake.NewSecretsManager()
+	secretsManagerClient.Secrets["not-pem"] = "certificate"
+	secretsManagerClient.Secrets["not-json"] = "certificate"
+	secretsManagerClient.Secrets["mismatched-key"] = `{"certificate":"","private_key":""}`
+	awsService := NewAWSService(fake.NewDynamoDB(), fake.NewSQS(), secretsManagerClient, fake.NewSSM())
+
+	sources := map[string][2]SecretSource{
+		"ca bundle not pem":          {{SecretId: "not-pem"}, {}},
+		"client certificate json":    {{}, {SecretId: "not-json"}},
+		"client certificate invalid": {{}, {SecretId: "mismatched-key"}},
+		"missing secret":             {{SecretId: "missing"}, {}},
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			if _, err := awsService.NotifyTLSConfig(context.TODO(), source[0], source[1], ""); err == nil {
+				t.Error("NotifyTLSConfig() expected error")
+			}
+		})
+	}
+}
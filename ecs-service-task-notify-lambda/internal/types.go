@@ -1,10 +1,22 @@
 package internal
 
+import "encoding/json"
+
 type TaskNotifyMessage struct {
-	NotifyTaskArn       string `json:"notify_task_arn"`
-	NotifyMeHostAddress string `json:"notify_me_host_address"`
-	NotifyMeHostPort    string `json:"notify_me_host_port"`
-	NotifyMeAPIUri      string `json:"notify_me_api_uri"`
+	NotificationId        string          `json:"notification_id"`
+	Cluster               string          `json:"cluster"`
+	Service               string          `json:"service"`
+	NotifyTaskArn         string          `json:"notify_task_arn"`
+	NotifyMeHostAddress   string          `json:"notify_me_host_address"`
+	NotifyMeHostPort      string          `json:"notify_me_host_port"`
+	NotifyMeAPIUri        string          `json:"notify_me_api_uri"`
+	NotifyMeHTTPMethod    string          `json:"notify_me_http_method"`
+	NotifyMeProtocol      string          `json:"notify_me_protocol"`
+	NotifyMeTLSServerName string          `json:"notify_me_tls_server_name,omitempty"`
+	NotifyMeAuthAudience  string          `json:"notify_me_auth_audience,omitempty"`
+	NotifyMeAuthScope     string          `json:"notify_me_auth_scope,omitempty"`
+	Topic                 string          `json:"topic,omitempty"`
+	Payload               json.RawMessage `json:"payload,omitempty"`
 }
 
 func NewTaskNotifyMessage() *TaskNotifyMessage {
@@ -6,58 +6,200 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
 )
 
-// Get AWSRequestId from Lambda Context Object
-func requestIdFromContext(ctx context.Context) string {
-	var requestId string = "x"
-	if lc, ok := lambdacontext.FromContext(ctx); ok {
-		requestId = lc.AwsRequestID
+// AWS Service factory, replaced by fakes in tests
+var newAWSService = internal.NewAWSServiceFromConfig
+
+// HandleRequest processes SQS messages and triggers Notify API requests,
+// failed messages only are reported as batch item failures
+func HandleRequest(ctx context.Context, event *events.SQSEvent) (events.SQSEventResponse, error) {
+	requestId := internal.RequestIdFromContext(ctx)
+	response := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+
+	awsService, err := newAWSService(ctx)
+	if err != nil {
+		return response, err
 	}
-	return requestId
-}
 
-// HandleRequest processes SQS messages and triggers HTTP GET requests
-func HandleRequest(ctx context.Context, event *events.SQSEvent) error {
-	requestId := requestIdFromContext(ctx)
+	// Optional DynamoDB table to audit notifications
+	awsService = awsService.WithAuditTable(os.Getenv("AUDIT_TABLE_NAME"))
 
-	for _, record := range event.Records {
-		slog.Info("Received Message Details", "requestId", requestId, "messageId", record.MessageId, "messageBody", record.Body)
+	// Optional timeouts and retries of the Notify API call
+	notifyConfig, err := internal.ParseNotifyConfig(os.LookupEnv)
+	if err != nil {
+		slog.Error("Invalid environment variable value", "requestId", requestId, "errorMessage", err)
+		return response, err
+	}
 
-		var tnm internal.TaskNotifyMessage
-		// Unmarshal the JSON string into the TaskNotifyMessage struct
-		err := json.Unmarshal([]byte(record.Body), &tnm)
-		if err != nil {
-			slog.Error("failed to Unmarshal Message to struct", "requestId", requestId, "errorMessage", err)
-			return err
+	// Optional CA bundle, client certificate and server name of https Notify API calls
+	tlsConfig, err := awsService.NotifyTLSConfig(ctx,
+		internal.SecretSourceFromEnv(os.LookupEnv, "NOTIFY_TLS_CA"),
+		internal.SecretSourceFromEnv(os.LookupEnv, "NOTIFY_TLS_CLIENT_CERT"),
+		os.Getenv("NOTIFY_TLS_SERVER_NAME"))
+	if err != nil {
+		return response, err
+	}
+	notifier := internal.NewNotifier(notifyConfig).WithTLSConfig(tlsConfig)
+	defer notifier.CloseIdleConnections()
+
+	// Optional OAuth2 client credentials bearer token of Notify API requests
+	tokenProvider, err := newTokenProvider(ctx, awsService)
+	if err != nil {
+		slog.Error("Invalid Notify API authentication", "requestId", requestId, "errorMessage", err)
+		return response, err
+	}
+	if tokenProvider != nil {
+		notifier = notifier.WithTokenProvider(tokenProvider)
+	}
+
+	// Optional signing of Notify API requests with key set from Secrets Manager or SSM
+	signingKeySource := internal.SecretSourceFromEnv(os.LookupEnv, "NOTIFY_SIGNING")
+	if signingKeySource.Enabled() {
+		keySet, keySetErr := awsService.SigningKeySet(ctx, signingKeySource)
+		if keySetErr != nil {
+			return response, keySetErr
 		}
-		// Make an HTTP GET request
-		// Format the URL with placeholders for host, port, and API URI
-		// TODO Add Support for https protocol
-		// TODO Add Support for pass event message to Notify API
-		url := fmt.Sprintf("http://%s:%s%s", tnm.NotifyMeHostAddress,
-			tnm.NotifyMeHostPort, tnm.NotifyMeAPIUri)
-		slog.Info("notify API formed URL", "requestId", requestId, "URL", url)
-
-		resp, err := http.Get(url)
-		if err != nil {
-			slog.Error("failed to trigger GET call", "requestId", requestId, "errorMessage", err)
-			return err
+		notifier = notifier.WithKeySet(keySet)
+	}
+
+	// Queue the Lambda consumes, retryable failures are re-enqueued with delay unless missing
+	sqsQueueURL := os.Getenv("SQS_QUEUE_URL")
+
+	// Optional dead letter queue of messages failed with permanent errors
+	deadLetterQueueURL := os.Getenv("DEAD_LETTER_QUEUE_URL")
+
+	recordErrs := handleRecords(ctx, awsService, notifier, sqsQueueURL, event.Records, notifyConfig.Concurrency)
+	for i, record := range event.Records {
+		if recordErrs[i] != nil {
+			slog.Error("failed to process message", "requestId", requestId, "messageId", record.MessageId, "errorMessage", recordErrs[i])
+			if failure.AcknowledgePermanentFailure(ctx, awsService.SQSClient(), requestId, deadLetterQueueURL, record, recordErrs[i]) {
+				continue
+			}
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: record.MessageId,
+			})
 		}
-		defer resp.Body.Close()
+	}
+	return response, nil
+}
+
+// Notify records of the batch in parallel by a pool of concurrency workers,
+// outcome of each record is returned at the index of the record
+func handleRecords(ctx context.Context, awsService *internal.AWSService, notifier *internal.Notifier, sqsQueueURL string, records []events.SQSMessage, concurrency int) []error {
+	recordErrs := make([]error, len(records))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(concurrency, len(records)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				recordErrs[i] = handleRecord(ctx, awsService, notifier, sqsQueueURL, records[i])
+			}
+		}()
+	}
 
-		slog.Info("notify API response status code", "requestId", requestId, "statusCode", resp.StatusCode)
-		// check status of http GET call
-		if resp.StatusCode != 200 {
-			return errors.New("failed to trigger Notify API")
+	for i := range records {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return recordErrs
+}
+
+// OAuth2 token provider of NOTIFY_AUTH_MODE=oauth2, nil without authentication
+func newTokenProvider(ctx context.Context, awsService *internal.AWSService) (*internal.TokenProvider, error) {
+	authMode := os.Getenv("NOTIFY_AUTH_MODE")
+	switch authMode {
+	case "", internal.AuthModeNone:
+		return nil, nil
+	case internal.AuthModeOAuth2:
+	default:
+		return nil, fmt.Errorf("unsupported NOTIFY_AUTH_MODE: %q", authMode)
+	}
+
+	tokenURL, ok := os.LookupEnv("NOTIFY_OAUTH2_TOKEN_URL")
+	if !ok || tokenURL == "" {
+		return nil, fmt.Errorf("environment key missing: %v", "NOTIFY_OAUTH2_TOKEN_URL")
+	}
+	clientSource := internal.SecretSourceFromEnv(os.LookupEnv, "NOTIFY_OAUTH2_CLIENT")
+	if !clientSource.Enabled() {
+		return nil, fmt.Errorf("environment key missing: %v", "NOTIFY_OAUTH2_CLIENT_SECRET_ID")
+	}
+
+	credentials, err := awsService.ClientCredentials(ctx, clientSource)
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewTokenProvider(tokenURL, *credentials,
+		os.Getenv("NOTIFY_OAUTH2_AUDIENCE"), os.Getenv("NOTIFY_OAUTH2_SCOPE")), nil
+}
+
+// Trigger Notify API of the task notify message, retryable failures
+// left after in-process attempts are re-enqueued with delay
+func handleRecord(ctx context.Context, awsService *internal.AWSService, notifier *internal.Notifier, sqsQueueURL string, record events.SQSMessage) (err error) {
+	requestId := internal.RequestIdFromContext(ctx)
+	slog.Info("Received Message Details", "requestId", requestId, "messageId", record.MessageId, "messageBody", record.Body)
+
+	// Audited before unmarshalling, invalid messages are audited by SQS message Id
+	requeueCount := internal.RequeueCount(record)
+	auditRecord := internal.NewMessageAuditRecord(record.MessageId)
+	start := time.Now()
+	defer func() {
+		auditRecord.Attempt, _ = strconv.Atoi(record.Attributes["ApproximateReceiveCount"])
+		auditRecord.RequeueCount = requeueCount
+		auditRecord.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			auditRecord.Error = err.Error()
+			auditRecord.Permanent = failure.IsPermanent(err)
 		}
+		awsService.PutAuditRecord(ctx, auditRecord)
+	}()
+
+	var tnm internal.TaskNotifyMessage
+	// Unmarshal the JSON string into the TaskNotifyMessage struct
+	err = json.Unmarshal([]byte(record.Body), &tnm)
+	if err != nil {
+		slog.Error("failed to Unmarshal Message to struct", "requestId", requestId, "errorMessage", err)
+		return failure.Permanent(err)
+	}
+	auditRecord = internal.NewAuditRecord(&tnm)
+
+	slog.Info("notify API formed URL", "requestId", requestId, "notificationId", tnm.NotificationId, "method", tnm.HTTPMethod(), "URL", tnm.NotifyURL())
+	result, notifyErr := notifier.Notify(ctx, &tnm)
+	auditRecord.StatusCode = result.StatusCode
+	auditRecord.Attempts = result.Attempts
+	if notifyErr == nil {
+		slog.Info("notify API response status code", "requestId", requestId, "statusCode", result.StatusCode, "attempts", result.Attempts)
+		return nil
+	}
+	slog.Error("failed to trigger notify API call", "requestId", requestId, "attempts", result.Attempts, "errorMessage", notifyErr)
+
+	var retryableErr *internal.NotifyError
+	if !errors.As(notifyErr, &retryableErr) || !retryableErr.Retryable ||
+		sqsQueueURL == "" || requeueCount >= notifier.Config().MaxRequeues {
+		return notifyErr
+	}
+
+	delay := internal.RequeueDelay(notifier.Config(), requeueCount, retryableErr.RetryAfter)
+	requeueMsgId, requeueErr := awsService.RequeueMessage(ctx, sqsQueueURL, record.Body, requeueCount+1, delay)
+	if requeueErr != nil {
+		return notifyErr // put message on retry
 	}
+	auditRecord.Error = notifyErr.Error()
+	auditRecord.RequeuedDelaySeconds = int(delay / time.Second)
+	slog.Info("Message re-enqueued", "requestId", requestId, "notificationId", tnm.NotificationId, "messageId", *requeueMsgId, "delay", delay)
 	return nil
 }
 
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/internal"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/internal/fake"
+	"github.com/jittakal/ecs-task-notifier/ecs-service-task-notify-lambda/signature"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/failure"
+)
+
+const testKeySetValue = `{"primary":"key-1","keys":{"key-1":"secret-1"}}`
+
+// Replace AWS Service factory with fakes for the duration of the test
+func useFakeAWSService(t testing.TB, sqsClient *fake.SQS) {
+	original := newAWSService
+	newAWSService = func(ctx context.Context) (*internal.AWSService, error) {
+		secretsManagerClient := fake.NewSecretsManager()
+		secretsManagerClient.Secrets["signing-keys"] = testKeySetValue
+		return internal.NewAWSService(fake.NewDynamoDB(), sqsClient, secretsManagerClient, fake.NewSSM()), nil
+	}
+	t.Cleanup(func() { newAWSService = original })
+}
+
+func TestHandleRequestBatchItemFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	body := func(uri string) string {
+		return fmt.Sprintf(`{"notify_task_arn":"arn","notify_me_host_address":"%s","notify_me_host_port":"%s","notify_me_api_uri":"%s"}`,
+			serverURL.Hostname(), serverURL.Port(), uri)
+	}
+	requeued := func(count string) map[string]events.SQSMessageAttribute {
+		return map[string]events.SQSMessageAttribute{
+			internal.RequeueCountAttribute: {StringValue: aws.String(count), DataType: "Number"},
+		}
+	}
+
+	event := &events.SQSEvent{Records: []events.SQSMessage{
+		{MessageId: "ok", Body: body("/notify")},
+		{MessageId: "invalid-json", Body: "{"},
+		{MessageId: "server-error", Body: body("/fail")},
+		{MessageId: "client-error", Body: body("/missing")},
+		{MessageId: "requeues-exhausted", Body: body("/fail"), MessageAttributes: requeued("3")},
+	}}
+
+	sqsClient := fake.NewSQS()
+	useFakeAWSService(t, sqsClient)
+	t.Setenv("SQS_QUEUE_URL", "queue-url")
+	t.Setenv("NOTIFY_RETRY_BASE_DELAY", "1ms")
+	t.Setenv("NOTIFY_RETRY_MAX_DELAY", "5ms")
+	t.Setenv("DEAD_LETTER_QUEUE_URL", "dlq-url")
+
+	response, err := HandleRequest(context.TODO(), event)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Invalid JSON and 4xx responses are permanent errors, acknowledged
+	var failed []string
+	for _, failure := range response.BatchItemFailures {
+		failed = append(failed, failure.ItemIdentifier)
+	}
+	if want := []string{"requeues-exhausted"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("BatchItemFailures = %v, want %v", failed, want)
+	}
+
+	sent := make(map[string][]*sqs.SendMessageInput)
+	for _, message := range sqsClient.Messages {
+		sent[aws.ToString(message.QueueUrl)] = append(sent[aws.ToString(message.QueueUrl)], message)
+	}
+	deadLetters := make(map[string]bool)
+	for _, message := range sent["dlq-url"] {
+		deadLetters[aws.ToString(message.MessageAttributes[failure.DeadLetterMessageIdAttribute].StringValue)] = true
+	}
+	if want := map[string]bool{"invalid-json": true, "client-error": true}; !reflect.DeepEqual(deadLetters, want) {
+		t.Errorf("dead letter messages = %v, want %v", deadLetters, want)
+	}
+
+	// Server error is re-enqueued with delay once in-process attempts are exhausted
+	if len(sent["queue-url"]) != 1 {
+		t.Fatalf("re-enqueued messages = %d, want 1", len(sent["queue-url"]))
+	}
+	requeue := sent["queue-url"][0]
+	if requeue.DelaySeconds != 30 || aws.ToString(requeue.MessageAttributes[internal.RequeueCountAttribute].StringValue) != "1" {
+		t.Errorf("unexpected re-enqueued message %+v", requeue)
+	}
+}
+
+func TestHandleRequestInvalidNotifyConfig(t *testing.T) {
+	useFakeAWSService(t, fake.NewSQS())
+	t.Setenv("NOTIFY_MAX_ATTEMPTS", "many")
+
+	if _, err := HandleRequest(context.TODO(), &events.SQSEvent{}); err == nil {
+		t.Error("HandleRequest() expected invalid environment variable error")
+	}
+}
+
+func TestHandleRequestSignsRequests(t *testing.T) {
+	keySet, _ := signature.ParseKeySet([]byte(testKeySetValue))
+	verifier := signature.NewVerifier(keySet)
+	server := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	body := fmt.Sprintf(`{"notify_task_arn":"arn","notify_me_host_address":"%s","notify_me_host_port":"%s","notify_me_api_uri":"/notify","payload":{"a":1}}`,
+		serverURL.Hostname(), serverURL.Port())
+	event := &events.SQSEvent{Records: []events.SQSMessage{{MessageId: "signed", Body: body}}}
+
+	useFakeAWSService(t, fake.NewSQS())
+	t.Setenv("NOTIFY_MAX_REQUEUES", "0")
+
+	// Unsigned requests are rejected by the verifying task
+	response, err := HandleRequest(context.TODO(), event)
+	if err != nil || len(response.BatchItemFailures) != 1 {
+		t.Fatalf("unsigned request BatchItemFailures = %v, error = %v", response.BatchItemFailures, err)
+	}
+
+	t.Setenv("NOTIFY_SIGNING_SECRET_ID", "signing-keys")
+	response, err = HandleRequest(context.TODO(), event)
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Errorf("signed request BatchItemFailures = %v, error = %v", response.BatchItemFailures, err)
+	}
+}
+
+func TestHandleRequestMissingSigningKeys(t *testing.T) {
+	useFakeAWSService(t, fake.NewSQS())
+	t.Setenv("NOTIFY_SIGNING_SECRET_ID", "missing")
+
+	if _, err := HandleRequest(context.TODO(), &events.SQSEvent{}); err == nil {
+		t.Error("HandleRequest() expected signing key set error")
+	}
+}
+
+var authModeTests = map[string]struct {
+	env     map[string]string
+	wantErr bool
+}{
+	"no authentication":     {map[string]string{"NOTIFY_AUTH_MODE": "none"}, false},
+	"unsupported auth mode": {map[string]string{"NOTIFY_AUTH_MODE": "basic"}, true},
+	"missing token url":     {map[string]string{"NOTIFY_AUTH_MODE": "oauth2", "NOTIFY_OAUTH2_CLIENT_SECRET_ID": "oauth2-client"}, true},
+	"missing client secret": {map[string]string{"NOTIFY_AUTH_MODE": "oauth2", "NOTIFY_OAUTH2_TOKEN_URL": "http://localhost/token"}, true},
+}
+
+func TestHandleRequestAuthMode(t *testing.T) {
+	for name, tc := range authModeTests {
+		t.Run(name, func(t *testing.T) {
+			useFakeAWSService(t, fake.NewSQS())
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			if _, err := HandleRequest(context.TODO(), &events.SQSEvent{}); (err != nil) != tc.wantErr {
+				t.Errorf("HandleRequest() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// Records of a batch larger than NOTIFY_CONCURRENCY are notified by exactly
+// NOTIFY_CONCURRENCY parallel Notify API calls
+func TestHandleRequestConcurrency(t *testing.T) {
+	const batchSize, concurrency = 12, 4
+	var inFlight, maxInFlight, served atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		// Hold the call so that every worker has a call in flight
+		time.Sleep(50 * time.Millisecond)
+		served.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	body := fmt.Sprintf(`{"notify_task_arn":"arn","notify_me_host_address":"%s","notify_me_host_port":"%s","notify_me_api_uri":"/notify"}`,
+		serverURL.Hostname(), serverURL.Port())
+	event := &events.SQSEvent{}
+	for i := range batchSize {
+		event.Records = append(event.Records, events.SQSMessage{MessageId: fmt.Sprintf("m%d", i), Body: body})
+	}
+
+	useFakeAWSService(t, fake.NewSQS())
+	t.Setenv("NOTIFY_CONCURRENCY", strconv.Itoa(concurrency))
+
+	response, err := HandleRequest(context.TODO(), event)
+	if err != nil || len(response.BatchItemFailures) > 0 {
+		t.Fatalf("HandleRequest() error = %v, failures = %v", err, response.BatchItemFailures)
+	}
+	if served.Load() != batchSize {
+		t.Errorf("Notify API calls = %d, want %d", served.Load(), batchSize)
+	}
+	if maxInFlight.Load() != concurrency {
+		t.Errorf("max Notify API calls in flight = %d, want %d", maxInFlight.Load(), concurrency)
+	}
+}
+
+// Records of a batch are notified against a Notify API with injected latency,
+// throughput grows with NOTIFY_CONCURRENCY up to the batch size
+func BenchmarkHandleRequest(b *testing.B) {
+	const batchSize, latency = 10, 20 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	body := fmt.Sprintf(`{"notify_task_arn":"arn","notify_me_host_address":"%s","notify_me_host_port":"%s","notify_me_api_uri":"/notify"}`,
+		serverURL.Hostname(), serverURL.Port())
+	event := &events.SQSEvent{}
+	for i := range batchSize {
+		event.Records = append(event.Records, events.SQSMessage{MessageId: fmt.Sprintf("m%d", i), Body: body})
+	}
+
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(logger)
+
+	for _, concurrency := range []int{1, 5, 10} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			useFakeAWSService(b, fake.NewSQS())
+			b.Setenv("NOTIFY_CONCURRENCY", strconv.Itoa(concurrency))
+
+			b.ResetTimer()
+			for range b.N {
+				response, err := HandleRequest(context.TODO(), event)
+				if err != nil || len(response.BatchItemFailures) > 0 {
+					b.Fatalf("HandleRequest() error = %v, failures = %v", err, response.BatchItemFailures)
+				}
+			}
+			b.ReportMetric(float64(batchSize*b.N)/b.Elapsed().Seconds(), "records/s")
+		})
+	}
+}
+
+func TestHandleRecordAuditsInvalidMessage(t *testing.T) {
+	dynamodbClient := fake.NewDynamoDB()
+	awsService := internal.NewAWSService(dynamodbClient, fake.NewSQS(), fake.NewSecretsManager(), fake.NewSSM()).WithAuditTable("audit-table")
+
+	record := events.SQSMessage{MessageId: "m1", Body: `{"cluster":`}
+	if err := handleRecord(context.TODO(), awsService, nil, "queue-url", record); !failure.IsPermanent(err) {
+		t.Fatalf("handleRecord() error = %v, want permanent error", err)
+	}
+
+	if len(dynamodbClient.Items) != 1 {
+		t.Fatalf("audit records = %d, want 1", len(dynamodbClient.Items))
+	}
+	var auditRecord internal.AuditRecord
+	if err := attributevalue.UnmarshalMap(dynamodbClient.Items[0], &auditRecord); err != nil {
+		t.Fatal(err)
+	}
+	if auditRecord.NotificationId != record.MessageId || auditRecord.Stage != internal.AuditStageTaskNotify ||
+		!auditRecord.Permanent || auditRecord.Error == "" {
+		t.Errorf("audit record = %+v, want permanent error audited by message Id", auditRecord)
+	}
+}
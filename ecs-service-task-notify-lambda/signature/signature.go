@@ -0,0 +1,190 @@
+// Package signature signs Notify API requests with HMAC-SHA256 and verifies
+// them, ECS tasks import it to reject requests not sent by the notifier.
+//
+// The signature covers the request timestamp, URI (path and query) and body:
+//
+//	hex(HMAC-SHA256(key, timestamp + "\n" + requestURI + "\n" + body))
+//
+// Keys are rotated by adding the new key to the key set of the verifying
+// tasks first, then making it the primary key used for signing, and finally
+// removing the old key once no message signed with it is in flight.
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Request header carrying the hex encoded signature
+	SignatureHeader = "X-Notify-Signature"
+	// Request header carrying the Id of the signing key
+	KeyIdHeader = "X-Notify-Key-Id"
+	// Request header carrying the signing time in Unix seconds
+	TimestampHeader = "X-Notify-Timestamp"
+
+	// Accepted clock difference between signer and verifier
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	ErrMissingSignature = errors.New("signature: missing signature headers")
+	ErrUnknownKey       = errors.New("signature: unknown signing key")
+	ErrExpiredTimestamp = errors.New("signature: timestamp outside tolerance")
+	ErrInvalidSignature = errors.New("signature: invalid signature")
+)
+
+// Active signing keys by key Id, the primary key signs new requests.
+// During rotation two keys are active, e.g.
+//
+//	{"primary": "2024-04", "keys": {"2024-01": "...", "2024-04": "..."}}
+type KeySet struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// Parse JSON key set as stored in Secrets Manager or SSM Parameter Store
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var keySet KeySet
+	if err := json.Unmarshal(data, &keySet); err != nil {
+		return nil, fmt.Errorf("signature: invalid key set: %w", err)
+	}
+	if len(keySet.Keys) == 0 {
+		return nil, errors.New("signature: key set without keys")
+	}
+	for keyId, key := range keySet.Keys {
+		if keyId == "" || key == "" {
+			return nil, errors.New("signature: key set with empty key Id or key")
+		}
+	}
+	if _, ok := keySet.Keys[keySet.Primary]; !ok {
+		return nil, fmt.Errorf("signature: primary key %q not in key set", keySet.Primary)
+	}
+	return &keySet, nil
+}
+
+// Key Id and key of the primary key
+func (keySet *KeySet) PrimaryKey() (string, []byte) {
+	return keySet.Primary, []byte(keySet.Keys[keySet.Primary])
+}
+
+// Hex encoded HMAC-SHA256 of timestamp, request URI and body
+func Sign(key []byte, timestamp int64, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(requestURI))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Set signature headers of the request signed at given time
+func SignRequest(req *http.Request, keyId string, key []byte, now time.Time) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(KeyIdHeader, keyId)
+	req.Header.Set(SignatureHeader, Sign(key, timestamp, req.URL.RequestURI(), body))
+	return nil
+}
+
+// Body of outgoing request, left readable
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("signature: request body can not be read twice")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// Verifies signed requests against all keys of the key set
+type Verifier struct {
+	keys      map[string][]byte
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+func NewVerifier(keySet *KeySet) *Verifier {
+	keys := make(map[string][]byte, len(keySet.Keys))
+	for keyId, key := range keySet.Keys {
+		keys[keyId] = []byte(key)
+	}
+	return &Verifier{keys: keys, tolerance: DefaultTolerance, now: time.Now}
+}
+
+// Accepted clock difference, DefaultTolerance unless set
+func (verifier *Verifier) WithTolerance(tolerance time.Duration) *Verifier {
+	verifier.tolerance = tolerance
+	return verifier
+}
+
+// Verify signature headers of the incoming request, the body stays readable
+func (verifier *Verifier) Verify(req *http.Request) error {
+	keyId := req.Header.Get(KeyIdHeader)
+	timestampValue := req.Header.Get(TimestampHeader)
+	signatureValue := req.Header.Get(SignatureHeader)
+	if keyId == "" || timestampValue == "" || signatureValue == "" {
+		return ErrMissingSignature
+	}
+
+	key, ok := verifier.keys[keyId]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	timestamp, err := strconv.ParseInt(timestampValue, 10, 64)
+	if err != nil {
+		return ErrExpiredTimestamp
+	}
+	if age := verifier.now().Sub(time.Unix(timestamp, 0)); age > verifier.tolerance || age < -verifier.tolerance {
+		return ErrExpiredTimestamp
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := Sign(key, timestamp, req.URL.RequestURI(), body)
+	if !hmac.Equal([]byte(expected), []byte(signatureValue)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Middleware rejecting requests without valid signature with 401 Unauthorized
+func (verifier *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifier.Verify(r); err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,142 @@
+package signature
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var signedAt = time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)
+
+func testKeySet(primary string) *KeySet {
+	return &KeySet{Primary: primary, Keys: map[string]string{"key-1": "secret-1", "key-2": "secret-2"}}
+}
+
+func signedRequest(t *testing.T, keySet *KeySet, body string) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, "http://10.0.0.1:8080/v1.0/notify?source=sqs", bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyId, key := keySet.PrimaryKey()
+	if err := SignRequest(req, keyId, key, signedAt); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+var verifyTests = map[string]struct {
+	signingKeys   *KeySet
+	verifyingKeys *KeySet
+	modify        func(req *http.Request)
+	verifyAt      time.Time
+	wantErr       error
+}{
+	"valid signature": {
+		testKeySet("key-1"), testKeySet("key-1"), func(*http.Request) {}, signedAt, nil,
+	},
+	"rotated primary key accepted": {
+		testKeySet("key-2"), testKeySet("key-1"), func(*http.Request) {}, signedAt.Add(time.Minute), nil,
+	},
+	"unknown key": {
+		&KeySet{Primary: "key-3", Keys: map[string]string{"key-3": "secret-3"}}, testKeySet("key-1"),
+		func(*http.Request) {}, signedAt, ErrUnknownKey,
+	},
+	"tampered body": {
+		testKeySet("key-1"), testKeySet("key-1"),
+		func(req *http.Request) { req.Body = io.NopCloser(bytes.NewReader([]byte(`{"event":"other"}`))) },
+		signedAt, ErrInvalidSignature,
+	},
+	"tampered path": {
+		testKeySet("key-1"), testKeySet("key-1"),
+		func(req *http.Request) { req.URL.Path = "/v1.0/admin" },
+		signedAt, ErrInvalidSignature,
+	},
+	"replayed timestamp": {
+		testKeySet("key-1"), testKeySet("key-1"), func(*http.Request) {}, signedAt.Add(10 * time.Minute), ErrExpiredTimestamp,
+	},
+	"forged timestamp": {
+		testKeySet("key-1"), testKeySet("key-1"),
+		func(req *http.Request) { req.Header.Set(TimestampHeader, strconv.FormatInt(signedAt.Unix()+1, 10)) },
+		signedAt, ErrInvalidSignature,
+	},
+	"missing signature": {
+		testKeySet("key-1"), testKeySet("key-1"),
+		func(req *http.Request) { req.Header.Del(SignatureHeader) },
+		signedAt, ErrMissingSignature,
+	},
+}
+
+func TestVerify(t *testing.T) {
+	for name, tc := range verifyTests {
+		t.Run(name, func(t *testing.T) {
+			req := signedRequest(t, tc.signingKeys, `{"event":"refresh"}`)
+			tc.modify(req)
+
+			verifier := NewVerifier(tc.verifyingKeys)
+			verifier.now = func() time.Time { return tc.verifyAt }
+
+			if err := verifier.Verify(req); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyKeepsBodyReadable(t *testing.T) {
+	req := signedRequest(t, testKeySet("key-1"), `{"event":"refresh"}`)
+	verifier := NewVerifier(testKeySet("key-1"))
+	verifier.now = func() time.Time { return signedAt }
+
+	if err := verifier.Verify(req); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"event":"refresh"}` {
+		t.Errorf("body = %q after verification", body)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	verifier := NewVerifier(testKeySet("key-1"))
+	verifier.now = func() time.Time { return signedAt }
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	signed := httptest.NewRecorder()
+	handler.ServeHTTP(signed, signedRequest(t, testKeySet("key-1"), `{}`))
+	if signed.Code != http.StatusOK {
+		t.Errorf("signed request status = %d, want 200", signed.Code)
+	}
+
+	unsigned := httptest.NewRecorder()
+	handler.ServeHTTP(unsigned, httptest.NewRequest(http.MethodPost, "/v1.0/notify", nil))
+	if unsigned.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned request status = %d, want 401", unsigned.Code)
+	}
+}
+
+var parseKeySetTests = map[string]struct {
+	value   string
+	wantErr bool
+}{
+	"valid key set":       {`{"primary":"key-1","keys":{"key-1":"secret-1","key-2":"secret-2"}}`, false},
+	"primary not in keys": {`{"primary":"key-3","keys":{"key-1":"secret-1"}}`, true},
+	"empty key":           {`{"primary":"key-1","keys":{"key-1":""}}`, true},
+	"no keys":             {`{"primary":"key-1"}`, true},
+	"invalid json":        {`secret`, true},
+}
+
+func TestParseKeySet(t *testing.T) {
+	for name, tc := range parseKeySetTests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseKeySet([]byte(tc.value)); (err != nil) != tc.wantErr {
+				t.Errorf("ParseKeySet() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
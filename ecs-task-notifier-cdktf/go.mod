@@ -8,6 +8,7 @@ require (
 	github.com/aws/jsii-runtime-go v1.95.0
 	github.com/cdktf/cdktf-provider-aws-go/aws/v10 v10.0.12
 	github.com/hashicorp/terraform-cdk-go/cdktf v0.20.5
+	github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common v0.0.0
 )
 
 require (
@@ -21,3 +22,5 @@ require (
 	golang.org/x/sys v0.14.0 // indirect
 	golang.org/x/tools v0.17.0 // indirect
 )
+
+replace github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common => ../ecs-task-notifier-common
@@ -1,28 +1,74 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path"
+	"slices"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 	"github.com/hashicorp/terraform-cdk-go/cdktf"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/cloudwatcheventrule"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/cloudwatcheventtarget"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/dataawscalleridentity"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/dynamodbtable"
 	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/iamrole"
 	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/iamrolepolicy"
 	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/lambdaeventsourcemapping"
 	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/lambdafunction"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/lambdapermission"
 	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/s3bucket"
 	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/s3bucketobject"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/schedulerschedule"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/schedulerschedulegroup"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/secretsmanagersecret"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/secretsmanagersecretversion"
 	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-cdktf/generated/hashicorp/aws/sqsqueue"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/schedule"
 
 	awsprovider "github.com/cdktf/cdktf-provider-aws-go/aws/v10/provider"
 )
 
-const (
-	// To be change as per needs
-	awsRegion = "us-east-1"
+// To be change as per needs, one stack per region e.g. us-east-1, us-west-2 and eu-west-1.
+// Notifications published in any region reach the services of the regions they name
+var awsRegions = []string{"us-east-1"}
+
+// To be change as per needs, DynamoDB table stream ARNs by region notifying on item changes e.g.
+// "us-east-1": "arn:aws:dynamodb:us-east-1:123456789012:table/name/stream/2024-01-01T00:00:00.000"
+var dynamodbStreamArns = map[string]string{}
+
+// Objects of the bucket under the key prefix notify the cluster (name or glob) and topic
+type S3EventSource struct {
+	Bucket  string
+	Prefix  string
+	Cluster string
+	Topic   string
+}
 
+// To be change as per needs, existing S3 buckets with EventBridge notifications enabled by region
+// notifying on object changes e.g.
+// "us-east-1": {{Bucket: "config-bucket", Prefix: "orders/", Cluster: "orders", Topic: "config.reload"}}
+var s3EventSources = map[string][]S3EventSource{}
+
+// Events of the bus matching the event pattern notify the cluster and topic selected by
+// JSONPath expressions, literal values for expressions not starting with $
+type EventBridgeSource struct {
+	EventBusName string
+	EventPattern string
+	ClusterPath  string
+	TopicPath    string
+	PayloadPath  string
+}
+
+// To be change as per needs, EventBridge rules by region notifying on matching events e.g.
+// "us-east-1": {EventBusName: "default", EventPattern: `{"source":["com.example.config"]}`, ClusterPath: "$.detail.cluster"}
+var eventBridgeSources = map[string]EventBridgeSource{}
+
+const (
 	// To be change as per needs
 	_awsVpcPrivateSubnetId1   = "subnet-xxxxxxx"
 	_awsVpcPrivateSubnetId2   = "subnet-xxxxxxx"
@@ -33,19 +79,522 @@ const (
 	// To be change as per tests and timeout needs
 	lambdaTimeout = 10.0
 
+	// Notify Lambda retries Notify API calls in-process, allow a batch of slow tasks
+	notifyLambdaTimeout = 30.0
+
+	// Messages in flight stay invisible for six times the consuming Lambda timeout
+	notifyQueueVisibilityTimeout = 6 * notifyLambdaTimeout
+
+	// SQS messages per Lambda invocation, failed messages are reported
+	// as batch item failures and retried individually
+	lambdaBatchSize = 10
+
 	ecsServiceNotificationQueueName = "ecs-service-notification"
 	ecsServiceQueueName             = "ecs-services"
 	ecsServiceTaskQueueName         = "ecs-service-tasks"
+
+	notificationAuditTableName = "ecs-task-notifier-audit"
+
+	// Notify API request signing key set, requests are signed once the stack variable is set
+	notifySigningSecretName = "ecs-task-notifier-signing-keys"
+
+	// Prefix of secrets holding Notify API CA bundle and client certificate
+	notifyTLSSecretPrefix = "ecs-task-notifier-tls"
+
+	// Prefix of secrets holding Notify API OAuth2 client credentials
+	notifyOAuth2SecretPrefix = "ecs-task-notifier-oauth2"
+
+	// Event payload travels with every message, allow SQS maximum message size
+	sqsMaxMessageSize = 262144
+
+	// Receives before a message is moved to its dead letter queue
+	_sqsMaxReceiveCount = 5
+
+	// Dead letter queue name suffix and message retention (14 days)
+	sqsDeadLetterQueueSuffix    = "-dlq"
+	sqsDeadLetterQueueRetention = 1209600
+
+	// Launch types of tasks to notify, capacity provider tasks resolve to EC2 or FARGATE
+	ecsTaskLaunchTypes = "EC2,FARGATE"
+
+	// Lambda execution role name, suffixed with the region of the stack
+	lambdaRoleName = "ECSServiceDiscoveryLambdaRole"
+
+	// ECS Service Task Notify Lambda execution role name, the only role reading Notify API secrets
+	notifyLambdaRoleName = "ECSServiceTaskNotifyLambdaRole"
+
+	// Role created in each workload account, assumed to discover services and tasks of its clusters
+	workloadRoleName = "ECSTaskNotifierWorkloadRole"
+
+	// To be change as per needs e.g. cluster-a=arn:aws:iam::123456789012:role/ECSTaskNotifierWorkloadRole
+	_clusterRoleArns = ""
+
+	// To be change as per needs, item attribute naming the cluster and comma separated
+	// payload attributes (all by default) and stream event names (all by default)
+	_dynamodbStreamClusterAttribute  = "cluster"
+	_dynamodbStreamPayloadAttributes = ""
+	_dynamodbStreamEventNames        = "INSERT,MODIFY,REMOVE"
+
+	// Scheduled notifications, validated with the schedule validate command of ecs-task-notifier-test
+	schedulesFileName = "schedules.json"
+
+	// EventBridge Scheduler group and role of the scheduled notifications
+	scheduleGroupName = "ecs-task-notifier"
+	schedulerRoleName = "ECSTaskNotifierSchedulerRole"
 )
 
-func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
+// Scheduled notification published to the observer queue
+type Schedule struct {
+	Name string
+	// Schedule expression, cron(...), rate(...) or at(...)
+	Expression string
+	// Timezone of cron and at expressions, UTC by default
+	Timezone string
+	// Message of the notification e.g. {"cluster":"*","topic":"cache.rotate"}
+	Message string
+}
+
+// Configuration shared by the stacks of all regions
+type StackConfig struct {
+	// Region of the stack
+	Region string
+	// Regions of all the stacks, services discovered in another region are queued there
+	Regions []string
+	// Scheduled notifications, provisioned in the stack of the first region only
+	Schedules []Schedule
+}
+
+// Load the scheduled notifications of the JSON file, fields besides name, schedule
+// and timezone make the notification message
+func loadSchedules(fileName string) ([]Schedule, error) {
+	definitions, err := schedule.LoadDefinitions(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make([]Schedule, 0, len(definitions))
+	for i, definition := range definitions {
+		if definition.Name == "" || definition.Schedule == "" {
+			return nil, fmt.Errorf("schedule %d requires name and schedule", i)
+		}
+		message, err := definition.Message()
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, Schedule{
+			Name:       definition.Name,
+			Expression: definition.Schedule,
+			Timezone:   definition.Timezone,
+			Message:    message,
+		})
+	}
+	return schedules, nil
+}
+
+// SQS Dead Letter Queue for messages failed more than max receive count
+func newDeadLetterQueue(stack cdktf.TerraformStack, id string, queueName string, region string) sqsqueue.SqsQueue {
+	return sqsqueue.NewSqsQueue(stack, jsii.String(id), &sqsqueue.SqsQueueConfig{
+		Name:                    jsii.String(queueName + "-" + region + sqsDeadLetterQueueSuffix),
+		MaxMessageSize:          jsii.Number(sqsMaxMessageSize),
+		MessageRetentionSeconds: jsii.Number(sqsDeadLetterQueueRetention),
+	})
+}
+
+// SQS Redrive Policy moving messages to dead letter queue
+func redrivePolicy(deadLetterQueue sqsqueue.SqsQueue, maxReceiveCount cdktf.TerraformVariable) *string {
+	return cdktf.Fn_Jsonencode(&map[string]interface{}{
+		"deadLetterTargetArn": deadLetterQueue.Arn(),
+		"maxReceiveCount":     maxReceiveCount.NumberValue(),
+	})
+}
+
+// Services queue URLs of the other regions e.g. us-west-2=https://sqs.us-west-2.amazonaws.com/123456789012/ecs-services-us-west-2
+func regionQueueURLs(accountId string, config StackConfig) string {
+	var queueURLs []string
+	for _, region := range config.Regions {
+		if region == config.Region {
+			continue
+		}
+		queueURLs = append(queueURLs, region+"=https://sqs."+region+".amazonaws.com/"+accountId+"/"+ecsServiceQueueName+"-"+region)
+	}
+	return strings.Join(queueURLs, ",")
+}
+
+// Lambda execution role ARNs of all regions, trusted by the workload account role
+func lambdaRoleArns(accountId string, config StackConfig) string {
+	var roleArns []string
+	for _, region := range config.Regions {
+		roleArns = append(roleArns, `"arn:aws:iam::`+accountId+`:role/`+lambdaRoleName+`-`+region+`"`)
+	}
+	return strings.Join(roleArns, ", ")
+}
+
+// Lambda Function publishing DynamoDB table stream records to the observer queue
+func newDynamoDBStreamSource(stack cdktf.TerraformStack, dynamodbStreamArn string, bucket s3bucket.S3Bucket, lambdaRole iamrole.IamRole,
+	ecsServiceNotificationQueue sqsqueue.SqsQueue, maxRetryAttempts cdktf.TerraformVariable, vpcConfig *lambdafunction.LambdaFunctionVpcConfig) {
+	cwd, _ := os.Getwd()
+
+	// IAM Policies related to DynamoDB streams
+	dynamodbStreamPolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "DynamoDBStreamReadPolicy",
+				"Effect": "Allow",
+				"Action": [
+					"dynamodb:DescribeStream",
+					"dynamodb:GetRecords",
+					"dynamodb:GetShardIterator",
+					"dynamodb:ListStreams"
+				],
+				"Resource": "` + dynamodbStreamArn + `"
+			}
+		]
+	}`
+
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_dynamodb_stream_source_lambda_stream_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("DynamoDBStreamReadPolicy"),
+		Role:   lambdaRole.Name(),
+		Policy: aws.String(dynamodbStreamPolicy),
+	})
+
+	ecsDynamoDBStreamSourceLambdaFile := cdktf.NewTerraformAsset(stack, jsii.String("ecs_dynamodb_stream_source_lambda_file"), &cdktf.TerraformAssetConfig{
+		Path: jsii.String(path.Join(cwd, "../ecs-event-source-lambda/dist/dynamodb-stream-source/")),
+		Type: cdktf.AssetType_ARCHIVE,
+	})
+
+	ecsDynamoDBStreamSourceLambdaS3Object := s3bucketobject.NewS3BucketObject(stack, jsii.String("ecs_dynamodb_stream_source_lambda_archive"), &s3bucketobject.S3BucketObjectConfig{
+		Bucket: bucket.Bucket(),
+		Key:    jsii.String("ecs-dynamodb-stream-source-lambda/" + *ecsDynamoDBStreamSourceLambdaFile.FileName()),
+		Source: ecsDynamoDBStreamSourceLambdaFile.Path(),
+	})
+
+	streamLambdaFilePath := cdktf.Token_AsString(cdktf.Fn_Abspath(ecsDynamoDBStreamSourceLambdaFile.Path()), &cdktf.EncodingOptions{})
+	streamLambdaHash := cdktf.Fn_Filebase64sha256(streamLambdaFilePath)
+
+	ecsDynamoDBStreamSourceLambda := lambdafunction.NewLambdaFunction(stack, jsii.String("ecs_dynamodb_stream_source_lambda"), &lambdafunction.LambdaFunctionConfig{
+		FunctionName:   aws.String("ecs-dynamodb-stream-source-lambda"),
+		S3Bucket:       bucket.Bucket(),
+		S3Key:          ecsDynamoDBStreamSourceLambdaS3Object.Key(),
+		Role:           lambdaRole.Arn(),
+		Runtime:        aws.String("provided.al2"),
+		Handler:        aws.String("main"),
+		Timeout:        aws.Float64(lambdaTimeout),
+		SourceCodeHash: streamLambdaHash,
+		VpcConfig:      vpcConfig,
+		Environment: &lambdafunction.LambdaFunctionEnvironment{
+			Variables: &map[string]*string{
+				"SQS_QUEUE_URL":      ecsServiceNotificationQueue.Url(),
+				"CLUSTER_ATTRIBUTE":  jsii.String(_dynamodbStreamClusterAttribute),
+				"PAYLOAD_ATTRIBUTES": jsii.String(_dynamodbStreamPayloadAttributes),
+				"EVENT_NAMES":        jsii.String(_dynamodbStreamEventNames),
+			},
+		},
+		DependsOn: &[]cdktf.ITerraformDependable{ecsServiceNotificationQueue},
+	})
+
+	// Stream records are retried in order from the first failed record
+	_ = lambdaeventsourcemapping.NewLambdaEventSourceMapping(stack, jsii.String("ecs_dynamodb_stream_source_lambda_source"), &lambdaeventsourcemapping.LambdaEventSourceMappingConfig{
+		EventSourceArn:        jsii.String(dynamodbStreamArn),
+		FunctionName:          ecsDynamoDBStreamSourceLambda.Arn(),
+		StartingPosition:      jsii.String("LATEST"),
+		BatchSize:             jsii.Number(lambdaBatchSize),
+		MaximumRetryAttempts:  maxRetryAttempts.NumberValue(),
+		FunctionResponseTypes: &[]*string{jsii.String("ReportBatchItemFailures")},
+		Enabled:               true,
+		DependsOn:             &[]cdktf.ITerraformDependable{ecsDynamoDBStreamSourceLambda},
+	})
+
+	cdktf.NewTerraformOutput(stack, jsii.String("EcsDynamoDBStreamSourceLambdaArn"), &cdktf.TerraformOutputConfig{
+		Value: ecsDynamoDBStreamSourceLambda.Arn(),
+	})
+}
+
+// Routing rules of the S3 Event Source Lambda e.g. config-bucket/orders/=orders:config.reload
+func s3RoutingRules(sources []S3EventSource) string {
+	var rules []string
+	for _, source := range sources {
+		rule := source.Bucket + "/" + source.Prefix + "=" + source.Cluster
+		if source.Topic != "" {
+			rule += ":" + source.Topic
+		}
+		rules = append(rules, rule)
+	}
+	return strings.Join(rules, ",")
+}
+
+// Key prefixes of the bucket events, prefixes covered by a shorter prefix are left to
+// the routing rules of the Lambda
+func s3NotificationPrefixes(sources []S3EventSource, bucketName string) []string {
+	var prefixes []string
+	for _, source := range sources {
+		if source.Bucket != bucketName {
+			continue
+		}
+		covered := false
+		for _, other := range sources {
+			if other.Bucket == bucketName && other.Prefix != source.Prefix && strings.HasPrefix(source.Prefix, other.Prefix) {
+				covered = true
+				break
+			}
+		}
+		if !covered && !slices.Contains(prefixes, source.Prefix) {
+			prefixes = append(prefixes, source.Prefix)
+		}
+	}
+	return prefixes
+}
+
+// Lambda Function publishing S3 object created and deleted events, delivered by EventBridge,
+// to the observer queue
+func newS3EventSource(stack cdktf.TerraformStack, sources []S3EventSource, bucket s3bucket.S3Bucket, lambdaRole iamrole.IamRole,
+	ecsServiceNotificationQueue sqsqueue.SqsQueue, vpcConfig *lambdafunction.LambdaFunctionVpcConfig) {
+	cwd, _ := os.Getwd()
+
+	ecsS3EventSourceLambdaFile := cdktf.NewTerraformAsset(stack, jsii.String("ecs_s3_event_source_lambda_file"), &cdktf.TerraformAssetConfig{
+		Path: jsii.String(path.Join(cwd, "../ecs-event-source-lambda/dist/s3-event-source/")),
+		Type: cdktf.AssetType_ARCHIVE,
+	})
+
+	ecsS3EventSourceLambdaS3Object := s3bucketobject.NewS3BucketObject(stack, jsii.String("ecs_s3_event_source_lambda_archive"), &s3bucketobject.S3BucketObjectConfig{
+		Bucket: bucket.Bucket(),
+		Key:    jsii.String("ecs-s3-event-source-lambda/" + *ecsS3EventSourceLambdaFile.FileName()),
+		Source: ecsS3EventSourceLambdaFile.Path(),
+	})
+
+	s3LambdaFilePath := cdktf.Token_AsString(cdktf.Fn_Abspath(ecsS3EventSourceLambdaFile.Path()), &cdktf.EncodingOptions{})
+	s3LambdaHash := cdktf.Fn_Filebase64sha256(s3LambdaFilePath)
+
+	ecsS3EventSourceLambda := lambdafunction.NewLambdaFunction(stack, jsii.String("ecs_s3_event_source_lambda"), &lambdafunction.LambdaFunctionConfig{
+		FunctionName:   aws.String("ecs-s3-event-source-lambda"),
+		S3Bucket:       bucket.Bucket(),
+		S3Key:          ecsS3EventSourceLambdaS3Object.Key(),
+		Role:           lambdaRole.Arn(),
+		Runtime:        aws.String("provided.al2"),
+		Handler:        aws.String("main"),
+		Timeout:        aws.Float64(lambdaTimeout),
+		SourceCodeHash: s3LambdaHash,
+		VpcConfig:      vpcConfig,
+		Environment: &lambdafunction.LambdaFunctionEnvironment{
+			Variables: &map[string]*string{
+				"SQS_QUEUE_URL": ecsServiceNotificationQueue.Url(),
+				"ROUTING_RULES": jsii.String(s3RoutingRules(sources)),
+			},
+		},
+		DependsOn: &[]cdktf.ITerraformDependable{ecsServiceNotificationQueue},
+	})
+
+	// Object events of the buckets are delivered by EventBridge, one rule per bucket, so
+	// that the notification configuration of the buckets is left to their owners
+	var bucketNames []string
+	for _, source := range sources {
+		if !slices.Contains(bucketNames, source.Bucket) {
+			bucketNames = append(bucketNames, source.Bucket)
+		}
+	}
+	for i, bucketName := range bucketNames {
+		eventRule := cloudwatcheventrule.NewCloudwatchEventRule(stack, jsii.String(fmt.Sprintf("ecs_s3_event_source_rule_%d", i)), &cloudwatcheventrule.CloudwatchEventRuleConfig{
+			Name:         jsii.String(fmt.Sprintf("ecs-task-notifier-s3-event-source-%d", i)),
+			Description:  jsii.String("S3 object events of " + bucketName + " notifying ECS tasks through the ECS Task Notifier"),
+			EventPattern: jsii.String(s3EventPattern(sources, bucketName)),
+		})
+
+		_ = cloudwatcheventtarget.NewCloudwatchEventTarget(stack, jsii.String(fmt.Sprintf("ecs_s3_event_source_target_%d", i)), &cloudwatcheventtarget.CloudwatchEventTargetConfig{
+			Rule:     eventRule.Name(),
+			TargetId: jsii.String("ecs-s3-event-source-lambda"),
+			Arn:      ecsS3EventSourceLambda.Arn(),
+		})
+
+		_ = lambdapermission.NewLambdaPermission(stack, jsii.String(fmt.Sprintf("ecs_s3_event_source_lambda_permission_%d", i)), &lambdapermission.LambdaPermissionConfig{
+			StatementId:  jsii.String("AllowEventBridgeInvoke-" + bucketName),
+			Action:       jsii.String("lambda:InvokeFunction"),
+			FunctionName: ecsS3EventSourceLambda.FunctionName(),
+			Principal:    jsii.String("events.amazonaws.com"),
+			SourceArn:    eventRule.Arn(),
+		})
+	}
+
+	cdktf.NewTerraformOutput(stack, jsii.String("EcsS3EventSourceLambdaArn"), &cdktf.TerraformOutputConfig{
+		Value: ecsS3EventSourceLambda.Arn(),
+	})
+}
+
+// EventBridge event pattern of object created and deleted events of the bucket under its
+// key prefixes, all keys of the bucket with an empty prefix
+func s3EventPattern(sources []S3EventSource, bucketName string) string {
+	detail := map[string]any{"bucket": map[string]any{"name": []string{bucketName}}}
+	prefixes := s3NotificationPrefixes(sources, bucketName)
+	if !slices.Contains(prefixes, "") {
+		var keyFilters []map[string]string
+		for _, prefix := range prefixes {
+			keyFilters = append(keyFilters, map[string]string{"prefix": prefix})
+		}
+		detail["object"] = map[string]any{"key": keyFilters}
+	}
+
+	eventPattern, _ := json.Marshal(map[string]any{
+		"source":      []string{"aws.s3"},
+		"detail-type": []string{"Object Created", "Object Deleted"},
+		"detail":      detail,
+	})
+	return string(eventPattern)
+}
+
+// Lambda Function publishing events of an EventBridge rule to the observer queue
+func newEventBridgeSource(stack cdktf.TerraformStack, source EventBridgeSource, bucket s3bucket.S3Bucket, lambdaRole iamrole.IamRole,
+	ecsServiceNotificationQueue sqsqueue.SqsQueue, vpcConfig *lambdafunction.LambdaFunctionVpcConfig) {
+	cwd, _ := os.Getwd()
+
+	ecsEventBridgeSourceLambdaFile := cdktf.NewTerraformAsset(stack, jsii.String("ecs_eventbridge_source_lambda_file"), &cdktf.TerraformAssetConfig{
+		Path: jsii.String(path.Join(cwd, "../ecs-event-source-lambda/dist/eventbridge-source/")),
+		Type: cdktf.AssetType_ARCHIVE,
+	})
+
+	ecsEventBridgeSourceLambdaS3Object := s3bucketobject.NewS3BucketObject(stack, jsii.String("ecs_eventbridge_source_lambda_archive"), &s3bucketobject.S3BucketObjectConfig{
+		Bucket: bucket.Bucket(),
+		Key:    jsii.String("ecs-eventbridge-source-lambda/" + *ecsEventBridgeSourceLambdaFile.FileName()),
+		Source: ecsEventBridgeSourceLambdaFile.Path(),
+	})
+
+	eventBridgeLambdaFilePath := cdktf.Token_AsString(cdktf.Fn_Abspath(ecsEventBridgeSourceLambdaFile.Path()), &cdktf.EncodingOptions{})
+	eventBridgeLambdaHash := cdktf.Fn_Filebase64sha256(eventBridgeLambdaFilePath)
+
+	ecsEventBridgeSourceLambda := lambdafunction.NewLambdaFunction(stack, jsii.String("ecs_eventbridge_source_lambda"), &lambdafunction.LambdaFunctionConfig{
+		FunctionName:   aws.String("ecs-eventbridge-source-lambda"),
+		S3Bucket:       bucket.Bucket(),
+		S3Key:          ecsEventBridgeSourceLambdaS3Object.Key(),
+		Role:           lambdaRole.Arn(),
+		Runtime:        aws.String("provided.al2"),
+		Handler:        aws.String("main"),
+		Timeout:        aws.Float64(lambdaTimeout),
+		SourceCodeHash: eventBridgeLambdaHash,
+		VpcConfig:      vpcConfig,
+		Environment: &lambdafunction.LambdaFunctionEnvironment{
+			Variables: &map[string]*string{
+				"SQS_QUEUE_URL": ecsServiceNotificationQueue.Url(),
+				"CLUSTER_PATH":  jsii.String(source.ClusterPath),
+				"TOPIC_PATH":    jsii.String(source.TopicPath),
+				"PAYLOAD_PATH":  jsii.String(source.PayloadPath),
+			},
+		},
+		DependsOn: &[]cdktf.ITerraformDependable{ecsServiceNotificationQueue},
+	})
+
+	eventBridgeRule := cloudwatcheventrule.NewCloudwatchEventRule(stack, jsii.String("ecs_eventbridge_source_rule"), &cloudwatcheventrule.CloudwatchEventRuleConfig{
+		Name:         jsii.String("ecs-task-notifier-eventbridge-source"),
+		Description:  jsii.String("Events notifying ECS tasks through the ECS Task Notifier"),
+		EventBusName: jsii.String(source.EventBusName),
+		EventPattern: jsii.String(source.EventPattern),
+	})
+
+	_ = cloudwatcheventtarget.NewCloudwatchEventTarget(stack, jsii.String("ecs_eventbridge_source_target"), &cloudwatcheventtarget.CloudwatchEventTargetConfig{
+		Rule:         eventBridgeRule.Name(),
+		EventBusName: jsii.String(source.EventBusName),
+		TargetId:     jsii.String("ecs-eventbridge-source-lambda"),
+		Arn:          ecsEventBridgeSourceLambda.Arn(),
+	})
+
+	_ = lambdapermission.NewLambdaPermission(stack, jsii.String("ecs_eventbridge_source_lambda_permission"), &lambdapermission.LambdaPermissionConfig{
+		StatementId:  jsii.String("AllowEventBridgeInvoke"),
+		Action:       jsii.String("lambda:InvokeFunction"),
+		FunctionName: ecsEventBridgeSourceLambda.FunctionName(),
+		Principal:    jsii.String("events.amazonaws.com"),
+		SourceArn:    eventBridgeRule.Arn(),
+	})
+
+	cdktf.NewTerraformOutput(stack, jsii.String("EcsEventBridgeSourceRuleArn"), &cdktf.TerraformOutputConfig{
+		Value: eventBridgeRule.Arn(),
+	})
+}
+
+// EventBridge Scheduler schedules publishing notifications to the observer queue
+func newSchedules(stack cdktf.TerraformStack, schedules []Schedule, accountId string, region string,
+	ecsServiceNotificationQueue sqsqueue.SqsQueue) {
+	// IAM policies for EventBridge Scheduler of the account
+	schedulerRolePolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Action": "sts:AssumeRole",
+				"Principal": {
+					"Service": "scheduler.amazonaws.com"
+				},
+				"Effect": "Allow",
+				"Condition": {
+					"StringEquals": {
+						"aws:SourceAccount": "` + accountId + `"
+					}
+				},
+				"Sid": "SchedulerExecutionRole"
+			}
+		]
+	}`
+
+	// IAM Policies related to SQS observer queue
+	schedulerSQSPolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "SchedulerSQSSendMessagePolicy",
+				"Effect": "Allow",
+				"Action": "sqs:SendMessage",
+				"Resource": "` + *ecsServiceNotificationQueue.Arn() + `"
+			}
+		]
+	}`
+
+	schedulerRole := iamrole.NewIamRole(stack, jsii.String("ecs_task_notifier_scheduler_role"), &iamrole.IamRoleConfig{
+		Name:             jsii.String(schedulerRoleName + "-" + region),
+		AssumeRolePolicy: &schedulerRolePolicy,
+	})
+
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_task_notifier_scheduler_sqs_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("SchedulerSQSSendMessagePolicy"),
+		Role:   schedulerRole.Name(),
+		Policy: aws.String(schedulerSQSPolicy),
+	})
+
+	scheduleGroup := schedulerschedulegroup.NewSchedulerScheduleGroup(stack, jsii.String("ecs_task_notifier_schedule_group"), &schedulerschedulegroup.SchedulerScheduleGroupConfig{
+		Name: jsii.String(scheduleGroupName),
+	})
+
+	for _, schedule := range schedules {
+		config := &schedulerschedule.SchedulerScheduleConfig{
+			Name:               jsii.String(schedule.Name),
+			GroupName:          scheduleGroup.Name(),
+			ScheduleExpression: jsii.String(schedule.Expression),
+			FlexibleTimeWindow: &schedulerschedule.SchedulerScheduleFlexibleTimeWindow{
+				Mode: jsii.String("OFF"),
+			},
+			Target: &schedulerschedule.SchedulerScheduleTarget{
+				Arn:     ecsServiceNotificationQueue.Arn(),
+				RoleArn: schedulerRole.Arn(),
+				Input:   jsii.String(schedule.Message),
+			},
+		}
+		if schedule.Timezone != "" {
+			config.ScheduleExpressionTimezone = jsii.String(schedule.Timezone)
+		}
+		_ = schedulerschedule.NewSchedulerSchedule(stack, jsii.String("ecs_task_notifier_schedule_"+schedule.Name), config)
+	}
+
+	cdktf.NewTerraformOutput(stack, jsii.String("ScheduleGroupArn"), &cdktf.TerraformOutputConfig{
+		Value: scheduleGroup.Arn(),
+	})
+}
+
+func NewMyStack(scope constructs.Construct, id string, config StackConfig) cdktf.TerraformStack {
 	stack := cdktf.NewTerraformStack(scope, &id)
 
 	// AWS Provider
 	awsprovider.NewAwsProvider(stack, jsii.String("AWS"), &awsprovider.AwsProviderConfig{
-		Region: jsii.String(awsRegion),
+		Region: jsii.String(config.Region),
 	})
 
+	// Account of the stacks, queues and roles of other regions are named after it
+	callerIdentity := dataawscalleridentity.NewDataAwsCallerIdentity(stack, jsii.String("current"), &dataawscalleridentity.DataAwsCallerIdentityConfig{})
+	accountId := *callerIdentity.AccountId()
+
 	// Terraform Stack Input Variables
 	awsVpcPrivateSubnetId1 := cdktf.NewTerraformVariable(stack, jsii.String("awsVpcPrivateSubnetId1"), &cdktf.TerraformVariableConfig{
 		Type:        jsii.String("string"),
@@ -65,9 +614,28 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 		Description: jsii.String("Lambda Function Security Group"),
 	})
 
+	sqsMaxReceiveCount := cdktf.NewTerraformVariable(stack, jsii.String("sqsMaxReceiveCount"), &cdktf.TerraformVariableConfig{
+		Type:        jsii.String("number"),
+		Default:     jsii.Number(_sqsMaxReceiveCount),
+		Description: jsii.String("Receives before a message is moved to the dead letter queue"),
+	})
+
+	clusterRoleArns := cdktf.NewTerraformVariable(stack, jsii.String("clusterRoleArns"), &cdktf.TerraformVariableConfig{
+		Type:        jsii.String("string"),
+		Default:     jsii.String(_clusterRoleArns),
+		Description: jsii.String("Comma separated workload account role ARNs of clusters e.g. cluster-a=role-arn"),
+	})
+
+	notifySigningKeys := cdktf.NewTerraformVariable(stack, jsii.String("notifySigningKeys"), &cdktf.TerraformVariableConfig{
+		Type:        jsii.String("string"),
+		Default:     jsii.String(""),
+		Sensitive:   jsii.Bool(true),
+		Description: jsii.String("Notify API request signing key set, requests are not signed if empty"),
+	})
+
 	// S3 bucket for lambda archive files
 	bucket := s3bucket.NewS3Bucket(stack, jsii.String("ecs_task_notifier_lambda_bucket"), &s3bucket.S3BucketConfig{
-		Bucket: jsii.String(lambdaZipBucketName + "-" + awsRegion),
+		Bucket: jsii.String(lambdaZipBucketName + "-" + config.Region),
 	})
 	cwd, _ := os.Getwd()
 
@@ -99,13 +667,16 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 				"Sid": "ECSDescribeServicePolicy",
 				"Effect": "Allow",
 				"Action": [
+					"ecs:ListClusters",
+					"ecs:DescribeClusters",
 					"ecs:ListServices",
 					"ecs:DescribeServices",
 					"ecs:ListTasks",
 					"ecs:DescribeTasks",
 					"ecs:DescribeTaskDefinition",
 					"ecs:ListContainerInstances",
-					"ecs:DescribeContainerInstances"
+					"ecs:DescribeContainerInstances",
+					"ecs:ListTagsForResource"
 				],
 				"Resource": "*"
 			}
@@ -169,16 +740,108 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 		]
 	}`
 
+	// IAM Policies related to workload account roles of cross-account clusters
+	stsAssumeWorkloadRolePolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "STSAssumeWorkloadRolePolicy",
+				"Effect": "Allow",
+				"Action": "sts:AssumeRole",
+				"Resource": "arn:aws:iam::*:role/` + workloadRoleName + `"
+			}
+		]
+	}`
+
+	// Permissions of the workload account role, discovering services and tasks of its clusters
+	workloadRolePolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "ECSTaskNotifierWorkloadPolicy",
+				"Effect": "Allow",
+				"Action": [
+					"ecs:ListClusters",
+					"ecs:DescribeClusters",
+					"ecs:ListServices",
+					"ecs:DescribeServices",
+					"ecs:ListTasks",
+					"ecs:DescribeTasks",
+					"ecs:DescribeTaskDefinition",
+					"ecs:DescribeContainerInstances",
+					"ecs:ListTagsForResource",
+					"ec2:DescribeInstances"
+				],
+				"Resource": "*"
+			}
+		]
+	}`
+
+	// IAM Policies related to Notify API request signing keys, TLS certificates and OAuth2 clients
+	secretsManagerSigningKeyPolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "SecretsManagerSigningKeyPolicy",
+				"Effect": "Allow",
+				"Action": [
+					"secretsmanager:GetSecretValue"
+				],
+				"Resource": [
+					"arn:aws:secretsmanager:*:*:secret:` + notifySigningSecretName + `-*",
+					"arn:aws:secretsmanager:*:*:secret:` + notifyTLSSecretPrefix + `*",
+					"arn:aws:secretsmanager:*:*:secret:` + notifyOAuth2SecretPrefix + `*"
+				]
+			}
+		]
+	}`
+
+	// DynamoDB Table - Notification Audit Trail
+	// Partition Key - notification_id, Sort Key - audit_key (stage and service/task)
+	notificationAuditTable := dynamodbtable.NewDynamodbTable(stack, jsii.String("ecs_task_notifier_audit_table"), &dynamodbtable.DynamodbTableConfig{
+		Name:        jsii.String(notificationAuditTableName + "-" + config.Region),
+		BillingMode: jsii.String("PAY_PER_REQUEST"),
+		HashKey:     jsii.String("notification_id"),
+		RangeKey:    jsii.String("audit_key"),
+		Attribute: &[]*dynamodbtable.DynamodbTableAttribute{
+			{Name: jsii.String("notification_id"), Type: jsii.String("S")},
+			{Name: jsii.String("audit_key"), Type: jsii.String("S")},
+		},
+		Ttl: &dynamodbtable.DynamodbTableTtl{
+			AttributeName: jsii.String("expires_at"),
+			Enabled:       true,
+		},
+	})
+
+	// IAM Policies related to DynamoDB audit table
+	dynamodbAuditServicePolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "DynamoDBAuditServicePolicy",
+				"Effect": "Allow",
+				"Action": [
+					"dynamodb:PutItem"
+				],
+				"Resource": "` + *notificationAuditTable.Arn() + `"
+			}
+		]
+	}`
+
 	// SQS Queue - ECS Notification - Observer Object
+	ecsServiceNotificationDeadLetterQueue := newDeadLetterQueue(stack, "ecs_service_notification_dlq", ecsServiceNotificationQueueName, config.Region)
 	ecsServiceNotificationQueue := sqsqueue.NewSqsQueue(stack, jsii.String("ecs_service_notification_queue"), &sqsqueue.SqsQueueConfig{
-		Name:           jsii.String(ecsServiceNotificationQueueName + "-" + awsRegion),
-		MaxMessageSize: jsii.Number(1024),
+		Name:           jsii.String(ecsServiceNotificationQueueName + "-" + config.Region),
+		MaxMessageSize: jsii.Number(sqsMaxMessageSize),
+		RedrivePolicy:  redrivePolicy(ecsServiceNotificationDeadLetterQueue, sqsMaxReceiveCount),
 	})
 
 	// SQS Queue - ECS Services
+	ecsServiceDeadLetterQueue := newDeadLetterQueue(stack, "ecs_services_dlq", ecsServiceQueueName, config.Region)
 	ecsServiceQueue := sqsqueue.NewSqsQueue(stack, jsii.String("ecs_services_queue"), &sqsqueue.SqsQueueConfig{
-		Name:           jsii.String(ecsServiceQueueName + "-" + awsRegion),
-		MaxMessageSize: jsii.Number(1024),
+		Name:           jsii.String(ecsServiceQueueName + "-" + config.Region),
+		MaxMessageSize: jsii.Number(sqsMaxMessageSize),
+		RedrivePolicy:  redrivePolicy(ecsServiceDeadLetterQueue, sqsMaxReceiveCount),
 	})
 
 	// Lambda Function - ECS Service Discovery Lambda
@@ -196,7 +859,7 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 	})
 
 	lambdaRole := iamrole.NewIamRole(stack, jsii.String("ecs_service_discovery_lambda_role"), &iamrole.IamRoleConfig{
-		Name:             jsii.String("ECSServiceDiscoveryLambdaRole"),
+		Name:             jsii.String(lambdaRoleName + "-" + config.Region),
 		AssumeRolePolicy: &lambdaRolePolicy,
 	})
 
@@ -218,6 +881,33 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 		Policy: aws.String(sqsServicePolicy),
 	})
 
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_service_discovery_lambda_dynamodb_execution_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("DynamoDBAuditWritePolicy"),
+		Role:   lambdaRole.Name(),
+		Policy: aws.String(dynamodbAuditServicePolicy),
+	})
+
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_service_discovery_lambda_sts_execution_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("STSAssumeWorkloadRolePolicy"),
+		Role:   lambdaRole.Name(),
+		Policy: aws.String(stsAssumeWorkloadRolePolicy),
+	})
+
+	// Trust policy of the workload account role, trusting the Lambda roles of all regions
+	workloadRoleTrustPolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "ECSTaskNotifierTrustPolicy",
+				"Effect": "Allow",
+				"Principal": {
+					"AWS": [` + lambdaRoleArns(accountId, config) + `]
+				},
+				"Action": "sts:AssumeRole"
+			}
+		]
+	}`
+
 	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_service_discovery_lambda_cwlog_execution_policy"), &iamrolepolicy.IamRolePolicyConfig{
 		Name:   jsii.String("CloudWatchLogReadWritePolicy"),
 		Role:   lambdaRole.Name(),
@@ -243,24 +933,33 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 		},
 		Environment: &lambdafunction.LambdaFunctionEnvironment{
 			Variables: &map[string]*string{
-				"SQS_QUEUE_URL": ecsServiceQueue.Url(),
+				"SQS_QUEUE_URL":          ecsServiceQueue.Url(),
+				"AUDIT_TABLE_NAME":       notificationAuditTable.Name(),
+				"DEAD_LETTER_QUEUE_URL":  ecsServiceNotificationDeadLetterQueue.Url(),
+				"CLUSTER_ROLE_ARNS":      clusterRoleArns.StringValue(),
+				"REGION_SQS_QUEUE_URLS":  jsii.String(regionQueueURLs(accountId, config)),
+				"NOTIFICATION_QUEUE_URL": ecsServiceNotificationQueue.Url(),
 			},
 		},
 		DependsOn: &[]cdktf.ITerraformDependable{ecsServiceQueue},
 	})
 
 	_ = lambdaeventsourcemapping.NewLambdaEventSourceMapping(stack, jsii.String("ecs_service_discovery_lambda_source"), &lambdaeventsourcemapping.LambdaEventSourceMappingConfig{
-		EventSourceArn: ecsServiceNotificationQueue.Arn(),
-		FunctionName:   ecsServiceDiscoveryLambda.Arn(),
-		BatchSize:      jsii.Number(1),
-		Enabled:        true,
-		DependsOn:      &[]cdktf.ITerraformDependable{ecsServiceQueue, ecsServiceDiscoveryLambda},
+		EventSourceArn:        ecsServiceNotificationQueue.Arn(),
+		FunctionName:          ecsServiceDiscoveryLambda.Arn(),
+		BatchSize:             jsii.Number(lambdaBatchSize),
+		FunctionResponseTypes: &[]*string{jsii.String("ReportBatchItemFailures")},
+		Enabled:               true,
+		DependsOn:             &[]cdktf.ITerraformDependable{ecsServiceQueue, ecsServiceDiscoveryLambda},
 	})
 
 	// SQS Queue - ECS Services Tasks
+	ecsServiceTaskDeadLetterQueue := newDeadLetterQueue(stack, "ecs_service_tasks_dlq", ecsServiceTaskQueueName, config.Region)
 	ecsServiceTaskQueue := sqsqueue.NewSqsQueue(stack, jsii.String("ecs_service_tasks_queue"), &sqsqueue.SqsQueueConfig{
-		Name:           jsii.String(ecsServiceTaskQueueName + "-" + awsRegion),
-		MaxMessageSize: jsii.Number(1024),
+		Name:                     jsii.String(ecsServiceTaskQueueName + "-" + config.Region),
+		MaxMessageSize:           jsii.Number(sqsMaxMessageSize),
+		VisibilityTimeoutSeconds: jsii.Number(notifyQueueVisibilityTimeout),
+		RedrivePolicy:            redrivePolicy(ecsServiceTaskDeadLetterQueue, sqsMaxReceiveCount),
 	})
 
 	// Lambda Function - ECS Service Task Discovery
@@ -296,18 +995,23 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 		},
 		Environment: &lambdafunction.LambdaFunctionEnvironment{
 			Variables: &map[string]*string{
-				"SQS_QUEUE_URL": ecsServiceTaskQueue.Url(),
+				"SQS_QUEUE_URL":         ecsServiceTaskQueue.Url(),
+				"ECS_TASK_LAUNCH_TYPES": jsii.String(ecsTaskLaunchTypes),
+				"AUDIT_TABLE_NAME":      notificationAuditTable.Name(),
+				"DEAD_LETTER_QUEUE_URL": ecsServiceDeadLetterQueue.Url(),
+				"SERVICE_QUEUE_URL":     ecsServiceQueue.Url(),
 			},
 		},
 		DependsOn: &[]cdktf.ITerraformDependable{ecsServiceTaskQueue},
 	})
 
 	_ = lambdaeventsourcemapping.NewLambdaEventSourceMapping(stack, jsii.String("ecs_service_task_discovery_lambda_source"), &lambdaeventsourcemapping.LambdaEventSourceMappingConfig{
-		EventSourceArn: ecsServiceQueue.Arn(),
-		FunctionName:   ecsServiceTaskDiscoveryLambda.Arn(),
-		BatchSize:      jsii.Number(1),
-		Enabled:        true,
-		DependsOn:      &[]cdktf.ITerraformDependable{ecsServiceQueue, ecsServiceTaskDiscoveryLambda},
+		EventSourceArn:        ecsServiceQueue.Arn(),
+		FunctionName:          ecsServiceTaskDiscoveryLambda.Arn(),
+		BatchSize:             jsii.Number(lambdaBatchSize),
+		FunctionResponseTypes: &[]*string{jsii.String("ReportBatchItemFailures")},
+		Enabled:               true,
+		DependsOn:             &[]cdktf.ITerraformDependable{ecsServiceQueue, ecsServiceTaskDiscoveryLambda},
 	})
 
 	// Lambda Function - ECS Service Task Notify
@@ -327,30 +1031,137 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 	notifyLambdaFilePath := cdktf.Token_AsString(cdktf.Fn_Abspath(ecsServiceTaskNotifyLambdaFile.Path()), &cdktf.EncodingOptions{})
 	notifyLambdaHash := cdktf.Fn_Filebase64sha256(notifyLambdaFilePath)
 
+	// Secrets Manager Secret - Notify API request signing key set
+	notifySigningSecret := secretsmanagersecret.NewSecretsmanagerSecret(stack, jsii.String("notify_signing_secret"), &secretsmanagersecret.SecretsmanagerSecretConfig{
+		Name:        jsii.String(notifySigningSecretName),
+		Description: jsii.String("Notify API request signing key set, e.g. {\"primary\":\"key-1\",\"keys\":{\"key-1\":\"...\"}}"),
+	})
+
+	// Secret version and signing with the configured key set only, a secret without
+	// version would fail every Notify API call
+	notifySigningEnabled := cdktf.Op_Neq(notifySigningKeys.StringValue(), jsii.String(""))
+	_ = secretsmanagersecretversion.NewSecretsmanagerSecretVersion(stack, jsii.String("notify_signing_secret_version"), &secretsmanagersecretversion.SecretsmanagerSecretVersionConfig{
+		SecretId:     notifySigningSecret.Id(),
+		SecretString: notifySigningKeys.StringValue(),
+		Count:        cdktf.Fn_Conditional(notifySigningEnabled, jsii.Number(1), jsii.Number(0)),
+	})
+	notifySigningSecretId := cdktf.Token_AsString(cdktf.Fn_Conditional(notifySigningEnabled, notifySigningSecret.Name(), jsii.String("")), &cdktf.EncodingOptions{})
+
+	// Lambda execution role of the ECS Service Task Notify Lambda, it neither calls ECS nor assumes
+	// workload roles, and is the only Lambda reading Notify API secrets
+	notifyLambdaRole := iamrole.NewIamRole(stack, jsii.String("ecs_service_task_notify_lambda_role"), &iamrole.IamRoleConfig{
+		Name:             jsii.String(notifyLambdaRoleName + "-" + config.Region),
+		AssumeRolePolicy: &lambdaRolePolicy,
+	})
+
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_service_task_notify_lambda_ec2_execution_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("EC2NetworkInterfacePolicy"),
+		Role:   notifyLambdaRole.Name(),
+		Policy: aws.String(ec2ServicePolicy),
+	})
+
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_service_task_notify_lambda_sqs_execution_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("SQSReadWritePolicy"),
+		Role:   notifyLambdaRole.Name(),
+		Policy: aws.String(sqsServicePolicy),
+	})
+
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_service_task_notify_lambda_dynamodb_execution_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("DynamoDBAuditWritePolicy"),
+		Role:   notifyLambdaRole.Name(),
+		Policy: aws.String(dynamodbAuditServicePolicy),
+	})
+
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_service_task_notify_lambda_signing_key_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("SecretsManagerSigningKeyReadPolicy"),
+		Role:   notifyLambdaRole.Name(),
+		Policy: aws.String(secretsManagerSigningKeyPolicy),
+	})
+
+	_ = iamrolepolicy.NewIamRolePolicy(stack, jsii.String("ecs_service_task_notify_lambda_cwlog_execution_policy"), &iamrolepolicy.IamRolePolicyConfig{
+		Name:   jsii.String("CloudWatchLogReadWritePolicy"),
+		Role:   notifyLambdaRole.Name(),
+		Policy: aws.String(cloudWatchLogServicePolicy),
+	})
+
 	// Lambda Function - ECS Service Task Notification
 	ecsServiceTaskNotifyLambda := lambdafunction.NewLambdaFunction(stack, jsii.String("ecs_service_task_notify_lambda"), &lambdafunction.LambdaFunctionConfig{
 		FunctionName:   aws.String("ecs-service-task-notify-lambda"),
 		S3Bucket:       bucket.Bucket(),
 		S3Key:          ecsServiceTaskNotifyLambdaS3Object.Key(),
-		Role:           lambdaRole.Arn(),
+		Role:           notifyLambdaRole.Arn(),
 		Runtime:        aws.String("provided.al2"),
 		Handler:        aws.String("main"),
-		Timeout:        aws.Float64(lambdaTimeout),
+		Timeout:        aws.Float64(notifyLambdaTimeout),
 		SourceCodeHash: notifyLambdaHash,
 		VpcConfig: &lambdafunction.LambdaFunctionVpcConfig{
 			SecurityGroupIds: &[]*string{awsLambdaSecurityGroupId.StringValue()},
 			SubnetIds:        &[]*string{awsVpcPrivateSubnetId1.StringValue(), awsVpcPrivateSubnetId2.StringValue()},
 		},
+		Environment: &lambdafunction.LambdaFunctionEnvironment{
+			Variables: &map[string]*string{
+				"AUDIT_TABLE_NAME":         notificationAuditTable.Name(),
+				"SQS_QUEUE_URL":            ecsServiceTaskQueue.Url(),
+				"DEAD_LETTER_QUEUE_URL":    ecsServiceTaskDeadLetterQueue.Url(),
+				"NOTIFY_CONNECT_TIMEOUT":   jsii.String("2s"),
+				"NOTIFY_READ_TIMEOUT":      jsii.String("3s"),
+				"NOTIFY_MAX_ATTEMPTS":      jsii.String("3"),
+				"NOTIFY_MAX_REQUEUES":      jsii.String("3"),
+				"NOTIFY_CONCURRENCY":       jsii.String("10"),
+				"NOTIFY_SIGNING_SECRET_ID": notifySigningSecretId,
+			},
+		},
 	})
 
 	_ = lambdaeventsourcemapping.NewLambdaEventSourceMapping(stack, jsii.String("ecs_service_task_notify_lambda_source"), &lambdaeventsourcemapping.LambdaEventSourceMappingConfig{
-		EventSourceArn: ecsServiceTaskQueue.Arn(),
-		FunctionName:   ecsServiceTaskNotifyLambda.Arn(),
-		BatchSize:      jsii.Number(1),
-		Enabled:        true,
-		DependsOn:      &[]cdktf.ITerraformDependable{ecsServiceTaskQueue, ecsServiceTaskNotifyLambda},
+		EventSourceArn:        ecsServiceTaskQueue.Arn(),
+		FunctionName:          ecsServiceTaskNotifyLambda.Arn(),
+		BatchSize:             jsii.Number(lambdaBatchSize),
+		FunctionResponseTypes: &[]*string{jsii.String("ReportBatchItemFailures")},
+		Enabled:               true,
+		DependsOn:             &[]cdktf.ITerraformDependable{ecsServiceTaskQueue, ecsServiceTaskNotifyLambda},
 	})
 
+	// Lambda Function - DynamoDB Stream Source
+	// Trigger on DynamoDB table stream of the region, if any
+	// Publish Messages to SQS Queue - ECS Notification
+	if dynamodbStreamArn, ok := dynamodbStreamArns[config.Region]; ok {
+		newDynamoDBStreamSource(stack, dynamodbStreamArn, bucket, lambdaRole, ecsServiceNotificationQueue, sqsMaxReceiveCount,
+			&lambdafunction.LambdaFunctionVpcConfig{
+				SecurityGroupIds: &[]*string{awsLambdaSecurityGroupId.StringValue()},
+				SubnetIds:        &[]*string{awsVpcPrivateSubnetId1.StringValue(), awsVpcPrivateSubnetId2.StringValue()},
+			})
+	}
+
+	// Lambda Function - S3 Event Source
+	// Trigger on S3 object created and deleted events of the buckets of the region, if any
+	// Publish Messages to SQS Queue - ECS Notification
+	if sources := s3EventSources[config.Region]; len(sources) > 0 {
+		newS3EventSource(stack, sources, bucket, lambdaRole, ecsServiceNotificationQueue,
+			&lambdafunction.LambdaFunctionVpcConfig{
+				SecurityGroupIds: &[]*string{awsLambdaSecurityGroupId.StringValue()},
+				SubnetIds:        &[]*string{awsVpcPrivateSubnetId1.StringValue(), awsVpcPrivateSubnetId2.StringValue()},
+			})
+	}
+
+	// Lambda Function - EventBridge Source
+	// Trigger on events of the EventBridge rule of the region, if any
+	// Publish Messages to SQS Queue - ECS Notification
+	if source, ok := eventBridgeSources[config.Region]; ok {
+		newEventBridgeSource(stack, source, bucket, lambdaRole, ecsServiceNotificationQueue,
+			&lambdafunction.LambdaFunctionVpcConfig{
+				SecurityGroupIds: &[]*string{awsLambdaSecurityGroupId.StringValue()},
+				SubnetIds:        &[]*string{awsVpcPrivateSubnetId1.StringValue(), awsVpcPrivateSubnetId2.StringValue()},
+			})
+	}
+
+	// EventBridge Scheduler - Scheduled Notifications
+	// Publish Messages to SQS Queue - ECS Notification of the first region, notifications
+	// of other regions name them
+	if len(config.Schedules) > 0 && config.Region == config.Regions[0] {
+		newSchedules(stack, config.Schedules, accountId, config.Region, ecsServiceNotificationQueue)
+	}
+
 	// Output SQS Queue URL
 	cdktf.NewTerraformOutput(stack, jsii.String("EcsServicesNotificationQueueId"), &cdktf.TerraformOutputConfig{
 		Value: ecsServiceNotificationQueue.Id(),
@@ -368,12 +1179,51 @@ func NewMyStack(scope constructs.Construct, id string) cdktf.TerraformStack {
 		Value: ecsServiceTaskQueue.Id(),
 	})
 
+	cdktf.NewTerraformOutput(stack, jsii.String("NotificationAuditTableName"), &cdktf.TerraformOutputConfig{
+		Value: notificationAuditTable.Name(),
+	})
+
+	cdktf.NewTerraformOutput(stack, jsii.String("EcsServicesNotificationDeadLetterQueueId"), &cdktf.TerraformOutputConfig{
+		Value: ecsServiceNotificationDeadLetterQueue.Id(),
+	})
+
+	cdktf.NewTerraformOutput(stack, jsii.String("EcsServicesDeadLetterQueueId"), &cdktf.TerraformOutputConfig{
+		Value: ecsServiceDeadLetterQueue.Id(),
+	})
+
+	cdktf.NewTerraformOutput(stack, jsii.String("EcsTasksDeadLetterQueueId"), &cdktf.TerraformOutputConfig{
+		Value: ecsServiceTaskDeadLetterQueue.Id(),
+	})
+
+	cdktf.NewTerraformOutput(stack, jsii.String("NotifySigningSecretArn"), &cdktf.TerraformOutputConfig{
+		Value: notifySigningSecret.Arn(),
+	})
+
+	// Role to create in each workload account, named workloadRoleName
+	cdktf.NewTerraformOutput(stack, jsii.String("WorkloadAccountRoleTrustPolicy"), &cdktf.TerraformOutputConfig{
+		Value: jsii.String(workloadRoleTrustPolicy),
+	})
+
+	cdktf.NewTerraformOutput(stack, jsii.String("WorkloadAccountRolePolicy"), &cdktf.TerraformOutputConfig{
+		Value: jsii.String(workloadRolePolicy),
+	})
+
 	return stack
 }
 
 func main() {
 	app := cdktf.NewApp(nil)
 
-	NewMyStack(app, "ecs-task-notifier-cdktf")
+	schedules, err := loadSchedules(schedulesFileName)
+	if err != nil {
+		fmt.Println("Error loading schedules:", err)
+		os.Exit(1)
+	}
+
+	config := StackConfig{Regions: awsRegions, Schedules: schedules}
+	for _, region := range awsRegions {
+		config.Region = region
+		NewMyStack(app, "ecs-task-notifier-cdktf-"+region, config)
+	}
 	app.Synth()
 }
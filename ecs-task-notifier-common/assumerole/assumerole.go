@@ -0,0 +1,63 @@
+// Package assumerole builds AWS clients of workload account roles and regions,
+// calling with credentials of the assumed role
+package assumerole
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/cache"
+)
+
+// Session name of assumed workload account roles, shown in CloudTrail of the workload account
+const SessionName = "ecs-task-notifier"
+
+// Role ARN and region of role clients, clients of the notifier account without role
+type Key struct {
+	RoleArn string
+	Region  string
+}
+
+// Clients factory assuming roles with the STS client of the config, clients are
+// built once per role and region by newClients and kept in the clients cache.
+// Each client caches the assumed role credentials until expiry and assumes the
+// role again then
+func NewFactory[C any](cfg aws.Config, clientsCache *cache.TTLCache[Key, C], newClients func(roleCfg aws.Config) C) func(roleArn string, region string) C {
+	stsClient := sts.NewFromConfig(cfg)
+	return func(roleArn string, region string) C {
+		key := Key{RoleArn: roleArn, Region: region}
+		if clients, ok := clientsCache.Get(key); ok {
+			return clients
+		}
+
+		roleCfg := cfg.Copy()
+		if region != "" {
+			roleCfg.Region = region
+		}
+		if roleArn != "" {
+			roleCfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, roleArn,
+				func(options *stscreds.AssumeRoleOptions) {
+					options.RoleSessionName = SessionName
+				}))
+		}
+		clients := newClients(roleCfg)
+		clientsCache.Put(key, clients)
+		return clients
+	}
+}
+
+// Check the ARN is an IAM role ARN e.g. arn:aws:iam::123456789012:role/ECSTaskNotifierWorkloadRole
+func ValidateRoleArn(roleArn string) error {
+	parsed, err := arn.Parse(roleArn)
+	if err != nil {
+		return fmt.Errorf("invalid role ARN %q: %w", roleArn, err)
+	}
+	if parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "role/") {
+		return fmt.Errorf("invalid role ARN %q: not an IAM role", roleArn)
+	}
+	return nil
+}
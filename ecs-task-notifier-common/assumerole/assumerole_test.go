@@ -0,0 +1,69 @@
+package assumerole
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/cache"
+)
+
+const workloadRoleArn = "arn:aws:iam::210987654321:role/ECSTaskNotifierWorkloadRole"
+
+// Clients of a role and region, recording the config they were built with
+type roleClients struct {
+	cfg aws.Config
+}
+
+func TestFactoryCachesClients(t *testing.T) {
+	clientsCache := cache.New[Key, *roleClients](0)
+	factory := NewFactory(aws.Config{Region: "us-east-1"}, clientsCache, func(roleCfg aws.Config) *roleClients {
+		return &roleClients{cfg: roleCfg}
+	})
+
+	otherRoleArn := "arn:aws:iam::111122223333:role/ECSTaskNotifierWorkloadRole"
+	workloadClients := factory(workloadRoleArn, "")
+	if factory(workloadRoleArn, "") != workloadClients {
+		t.Error("role clients built again for the same role")
+	}
+	for _, clients := range []*roleClients{factory(otherRoleArn, ""), factory(workloadRoleArn, "us-west-2"), factory("", "us-west-2")} {
+		if clients == workloadClients {
+			t.Error("role clients shared by another role or region")
+		}
+	}
+	if stats := clientsCache.TakeStats(); stats != (cache.Stats{Hits: 1, Misses: 4}) {
+		t.Errorf("role clients cache = %+v, want 1 hit and 4 misses", stats)
+	}
+
+	if region := factory("", "us-west-2").cfg.Region; region != "us-west-2" {
+		t.Errorf("region of clients = %q, want us-west-2", region)
+	}
+	if region := workloadClients.cfg.Region; region != "us-east-1" {
+		t.Errorf("region of clients without region = %q, want default region us-east-1", region)
+	}
+	if factory("", "").cfg.Credentials != nil {
+		t.Error("clients without role assume a role")
+	}
+	if workloadClients.cfg.Credentials == nil {
+		t.Error("clients of the workload role without assumed role credentials")
+	}
+}
+
+var validateRoleArnTests = map[string]struct {
+	roleArn string
+	wantErr bool
+}{
+	"workload role": {workloadRoleArn, false},
+	"invalid arn":   {"ECSTaskNotifierWorkloadRole", true},
+	"not a role":    {"arn:aws:iam::210987654321:user/notifier", true},
+	"not iam":       {"arn:aws:sqs:us-east-1:210987654321:role/queue", true},
+}
+
+func TestValidateRoleArn(t *testing.T) {
+	for name, tc := range validateRoleArnTests {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateRoleArn(tc.roleArn); (err != nil) != tc.wantErr {
+				t.Errorf("ValidateRoleArn(%q) error = %v, wantErr %v", tc.roleArn, err, tc.wantErr)
+			}
+		})
+	}
+}
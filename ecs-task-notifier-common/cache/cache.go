@@ -0,0 +1,78 @@
+// Package cache provides an in-memory cache of the Lambdas, surviving across
+// warm invocations
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache hits and misses since statistics were last taken
+type Stats struct {
+	Hits   int
+	Misses int
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// In-memory cache surviving across warm invocations of the Lambda,
+// entries never expire when ttl is zero
+type TTLCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[K]entry[V]
+	stats   Stats
+}
+
+func New[K comparable, V any](ttl time.Duration) *TTLCache[K, V] {
+	return &TTLCache[K, V]{ttl: ttl, now: time.Now, entries: make(map[K]entry[V])}
+}
+
+// Get cached value of the key, counted as hit or miss
+func (cache *TTLCache[K, V]) Get(key K) (V, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cached, ok := cache.entries[key]
+	if ok && cache.ttl > 0 && !cache.now().Before(cached.expiresAt) {
+		delete(cache.entries, key)
+		ok = false
+	}
+	if !ok {
+		cache.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	cache.stats.Hits++
+	return cached.value, true
+}
+
+func (cache *TTLCache[K, V]) Put(key K, value V) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries[key] = entry[V]{value: value, expiresAt: cache.now().Add(cache.ttl)}
+}
+
+// Take statistics and reset them, so that statistics are reported per invocation
+func (cache *TTLCache[K, V]) TakeStats() Stats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	cache.stats = Stats{}
+	return stats
+}
+
+// Remove all entries and statistics
+func (cache *TTLCache[K, V]) Clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	clear(cache.entries)
+	cache.stats = Stats{}
+}
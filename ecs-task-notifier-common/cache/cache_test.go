@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := New[string, string](time.Minute)
+	cache.now = func() time.Time { return now }
+
+	if _, ok := cache.Get("key"); ok {
+		t.Fatal("Get() hit on empty cache")
+	}
+	cache.Put("key", "value")
+	if value, ok := cache.Get("key"); !ok || value != "value" {
+		t.Fatalf("Get() = %q, %v, want value, true", value, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.Get("key"); ok {
+		t.Error("Get() hit on expired entry")
+	}
+
+	if stats := cache.TakeStats(); stats != (Stats{Hits: 1, Misses: 2}) {
+		t.Errorf("TakeStats() = %+v, want 1 hit and 2 misses", stats)
+	}
+	if stats := cache.TakeStats(); stats != (Stats{}) {
+		t.Errorf("TakeStats() = %+v, want reset statistics", stats)
+	}
+}
+
+func TestTTLCacheWithoutExpiry(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := New[string, int](0)
+	cache.now = func() time.Time { return now }
+
+	cache.Put("key", 1)
+	now = now.Add(365 * 24 * time.Hour)
+	if value, ok := cache.Get("key"); !ok || value != 1 {
+		t.Errorf("Get() = %d, %v, want 1, true", value, ok)
+	}
+
+	cache.Clear()
+	if _, ok := cache.Get("key"); ok {
+		t.Error("Get() hit after Clear()")
+	}
+}
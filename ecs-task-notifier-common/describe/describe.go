@@ -0,0 +1,102 @@
+// Package describe calls ECS and EC2 describe APIs in chunks of the API limits
+// with bounded concurrency
+package describe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Maximum describe calls in flight, keeps bursts below ECS API throttling
+const Concurrency = 4
+
+// Split items into chunks of at most size items
+func Chunks(items []string, size int) [][]string {
+	var batches [][]string
+	for len(items) > size {
+		batches = append(batches, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		batches = append(batches, items)
+	}
+	return batches
+}
+
+// Describe items in chunks of at most size items with at most Concurrency
+// calls in flight, results are returned in item order. The first failed call
+// cancels the calls not yet completed and its error is returned
+func InChunks[T any](ctx context.Context, items []string, size int, describe func(ctx context.Context, chunk []string) ([]T, error)) ([]T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := Chunks(items, size)
+	results := make([][]T, len(batches))
+	limiter := make(chan struct{}, Concurrency)
+
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		limiter <- struct{}{}
+		if err := ctx.Err(); err != nil {
+			<-limiter
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			described, err := describe(ctx, batch)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			results[i] = described
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	var described []T
+	for _, result := range results {
+		described = append(described, result...)
+	}
+	return described, nil
+}
+
+// Error of describe failures, ARNs deleted since listed are reported MISSING
+// and skipped, other failures fail the describe
+func FailuresErr(requestId string, operation string, failures []types.Failure) error {
+	var errs []error
+	for _, failure := range failures {
+		if aws.ToString(failure.Reason) == "MISSING" {
+			slog.Warn("Described resource is missing, skipped", "requestId", requestId,
+				"operation", operation, "arn", aws.ToString(failure.Arn))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s failure of %s: %s %s", operation,
+			aws.ToString(failure.Arn), aws.ToString(failure.Reason), aws.ToString(failure.Detail)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,65 @@
+package describe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChunks(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if actual := Chunks(items, 2); !reflect.DeepEqual(actual, want) {
+		t.Errorf("Chunks() = %v, want %v", actual, want)
+	}
+	if actual := Chunks(nil, 2); len(actual) != 0 {
+		t.Errorf("Chunks() = %v, want no chunks", actual)
+	}
+}
+
+func TestDescribeChunks(t *testing.T) {
+	var items []string
+	for i := range 50 {
+		items = append(items, fmt.Sprintf("item-%02d", i))
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	described, err := InChunks(context.TODO(), items, 3, func(ctx context.Context, chunk []string) ([]string, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return chunk, nil
+	})
+	if err != nil {
+		t.Fatalf("InChunks() error = %v", err)
+	}
+	if !reflect.DeepEqual(described, items) {
+		t.Errorf("InChunks() = %v, want items in order", described)
+	}
+	if maxInFlight.Load() > Concurrency {
+		t.Errorf("describe calls in flight = %d, want at most %d", maxInFlight.Load(), Concurrency)
+	}
+}
+
+func TestDescribeChunksError(t *testing.T) {
+	describeErr := errors.New("ThrottlingException")
+	_, err := InChunks(context.TODO(), []string{"a", "b", "c"}, 1, func(ctx context.Context, chunk []string) ([]string, error) {
+		if chunk[0] == "b" {
+			return nil, describeErr
+		}
+		return chunk, nil
+	})
+	if !errors.Is(err, describeErr) {
+		t.Errorf("InChunks() error = %v, want %v", err, describeErr)
+	}
+}
@@ -0,0 +1,76 @@
+// Package dynamodblocal runs the audit record tests of the Lambdas against DynamoDB Local, e.g.
+// docker run -p 8000:8000 amazon/dynamodb-local
+// AWS_ENDPOINT_URL_DYNAMODB=http://localhost:8000 go test ./...
+package dynamodblocal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Audit table of the tests, created once and shared by the tests of all Lambdas
+const AuditTableName = "ecs-task-notifier-audit-test"
+
+// DynamoDB Local client with the audit table created, the test is skipped unless
+// AWS_ENDPOINT_URL_DYNAMODB is set
+func NewAuditTable(t testing.TB) *dynamodb.Client {
+	t.Helper()
+	if os.Getenv("AWS_ENDPOINT_URL_DYNAMODB") == "" {
+		t.Skip("AWS_ENDPOINT_URL_DYNAMODB not set, skipping DynamoDB Local test")
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dynamodb.NewFromConfig(cfg)
+
+	// Same keys as the audit table of the stack
+	_, err = client.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
+		TableName:   aws.String(AuditTableName),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("notification_id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("audit_key"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("notification_id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("audit_key"), KeyType: types.KeyTypeRange},
+		},
+	})
+	var inUseErr *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUseErr) {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// Get the audit record of the notification and audit key, unmarshalled into auditRecord
+func GetAuditRecord(t testing.TB, client *dynamodb.Client, notificationId string, auditKey string, auditRecord any) {
+	t.Helper()
+	output, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(AuditTableName),
+		Key: map[string]types.AttributeValue{
+			"notification_id": &types.AttributeValueMemberS{Value: notificationId},
+			"audit_key":       &types.AttributeValueMemberS{Value: auditKey},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Item == nil {
+		t.Fatalf("audit record %s %s not found", notificationId, auditKey)
+	}
+
+	if err := attributevalue.UnmarshalMap(output.Item, auditRecord); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+package failure
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Message attributes of messages routed to the dead letter queue
+const (
+	DeadLetterErrorAttribute     = "ErrorMessage"
+	DeadLetterMessageIdAttribute = "SourceMessageId"
+)
+
+// SQS client sending messages to the dead letter queue
+type SQSClient interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// Route message failed with a permanent error to the dead letter queue,
+// the error and the source message Id are carried as message attributes
+func SendToDeadLetterQueue(ctx context.Context, sqsClient SQSClient, requestId string, deadLetterQueueURL string, messageId string, body string, cause error) error {
+	_, err := sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(deadLetterQueueURL),
+		MessageBody: aws.String(body),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			DeadLetterErrorAttribute:     {DataType: aws.String("String"), StringValue: aws.String(cause.Error())},
+			DeadLetterMessageIdAttribute: {DataType: aws.String("String"), StringValue: aws.String(messageId)},
+		},
+	})
+	if err != nil {
+		slog.Error("failed to send message to dead letter queue", "requestId", requestId, "messageId", messageId, "errorMessage", err)
+		return err
+	}
+	return nil
+}
+
+// Acknowledge message failed with a permanent error, as retries fail again.
+// The message is routed to the dead letter queue when configured, and
+// retried when routing fails. Transient errors are never acknowledged
+func AcknowledgePermanentFailure(ctx context.Context, sqsClient SQSClient, requestId string, deadLetterQueueURL string, record events.SQSMessage, err error) bool {
+	if !IsPermanent(err) {
+		return false
+	}
+	if deadLetterQueueURL != "" {
+		if dlqErr := SendToDeadLetterQueue(ctx, sqsClient, requestId, deadLetterQueueURL, record.MessageId, record.Body, err); dlqErr != nil {
+			return false
+		}
+	}
+	slog.Warn("Message acknowledged on permanent error", "requestId", requestId,
+		"messageId", record.MessageId, "deadLetterQueued", deadLetterQueueURL != "")
+	return true
+}
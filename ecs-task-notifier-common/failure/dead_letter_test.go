@@ -0,0 +1,61 @@
+package failure
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// SQS client recording sent messages, failing every send when err is set
+type fakeSQSClient struct {
+	messages []*sqs.SendMessageInput
+	err      error
+}
+
+func (sqsClient *fakeSQSClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	if sqsClient.err != nil {
+		return nil, sqsClient.err
+	}
+	sqsClient.messages = append(sqsClient.messages, params)
+	return &sqs.SendMessageOutput{MessageId: aws.String("dlq-message-id")}, nil
+}
+
+var acknowledgePermanentFailureTests = map[string]struct {
+	err                error
+	deadLetterQueueURL string
+	sendErr            error
+	want               bool
+	wantDeadLetters    int
+}{
+	"transient error":               {errors.New("connection reset by peer"), "dlq-url", nil, false, 0},
+	"permanent error":               {Permanent(errors.New("invalid message")), "dlq-url", nil, true, 1},
+	"permanent without dead letter": {Permanent(errors.New("invalid message")), "", nil, true, 0},
+	"dead letter failure":           {Permanent(errors.New("invalid message")), "dlq-url", errors.New("throttled"), false, 0},
+}
+
+func TestAcknowledgePermanentFailure(t *testing.T) {
+	record := events.SQSMessage{MessageId: "message-id", Body: "{}"}
+
+	for name, tc := range acknowledgePermanentFailureTests {
+		t.Run(name, func(t *testing.T) {
+			sqsClient := &fakeSQSClient{err: tc.sendErr}
+
+			actual := AcknowledgePermanentFailure(context.Background(), sqsClient, "request-id", tc.deadLetterQueueURL, record, tc.err)
+			if actual != tc.want {
+				t.Errorf("AcknowledgePermanentFailure() = %v, want %v", actual, tc.want)
+			}
+			if len(sqsClient.messages) != tc.wantDeadLetters {
+				t.Fatalf("dead letter messages = %d, want %d", len(sqsClient.messages), tc.wantDeadLetters)
+			}
+			for _, message := range sqsClient.messages {
+				if sourceId := aws.ToString(message.MessageAttributes[DeadLetterMessageIdAttribute].StringValue); sourceId != record.MessageId {
+					t.Errorf("%s = %q, want %q", DeadLetterMessageIdAttribute, sourceId, record.MessageId)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Package failure classifies errors of the Lambdas as permanent or transient and
+// routes messages failed with a permanent error to the dead letter queue
+package failure
+
+import (
+	"errors"
+
+	"github.com/aws/smithy-go"
+)
+
+// Error failing every retry of the message, e.g. invalid message or missing cluster
+type PermanentError struct {
+	Err error
+}
+
+func (permanentErr *PermanentError) Error() string {
+	return permanentErr.Err.Error()
+}
+
+func (permanentErr *PermanentError) Unwrap() error {
+	return permanentErr.Err
+}
+
+// Mark error as permanent, nil stays nil
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Error classifying itself as permanent or transient, e.g. errors of the Notify API
+type ClassifiedError interface {
+	error
+	Permanent() bool
+}
+
+// Error codes of throttled AWS API calls, client faults retried as transient errors
+var throttlingErrorCodes = map[string]bool{
+	"Throttling":               true,
+	"ThrottlingException":      true,
+	"ThrottledException":       true,
+	"RequestThrottled":         true,
+	"RequestLimitExceeded":     true,
+	"TooManyRequestsException": true,
+}
+
+// Error codes of denied AWS API calls, not always modeled as client faults
+var accessDeniedErrorCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedOperation": true,
+}
+
+// Classify error as permanent or transient. Errors marked permanent, classified
+// errors reporting permanent, access denied and client faults of AWS API calls,
+// e.g. ClusterNotFoundException, are permanent. Throttling, server faults and
+// network errors are transient
+func IsPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var permanentErr *PermanentError
+	if errors.As(err, &permanentErr) {
+		return true
+	}
+
+	var classifiedErr ClassifiedError
+	if errors.As(err, &classifiedErr) {
+		return classifiedErr.Permanent()
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case throttlingErrorCodes[apiErr.ErrorCode()]:
+			return false
+		case accessDeniedErrorCodes[apiErr.ErrorCode()]:
+			return true
+		}
+		return apiErr.ErrorFault() == smithy.FaultClient
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package failure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go"
+)
+
+// Error of an HTTP API classifying itself by status code
+type statusError struct {
+	statusCode int
+}
+
+func (statusErr *statusError) Error() string {
+	return fmt.Sprintf("status code %d", statusErr.statusCode)
+}
+
+func (statusErr *statusError) Permanent() bool {
+	return statusErr.statusCode < 500
+}
+
+var isPermanentTests = map[string]struct {
+	err  error
+	want bool
+}{
+	"no error":              {nil, false},
+	"marked permanent":      {Permanent(errors.New("invalid port")), true},
+	"wrapped permanent":     {fmt.Errorf("record: %w", Permanent(&json.SyntaxError{})), true},
+	"classified permanent":  {fmt.Errorf("notify: %w", &statusError{statusCode: 404}), true},
+	"classified transient":  {&statusError{statusCode: 503}, false},
+	"cluster not found":     {&types.ClusterNotFoundException{Message: aws.String("Cluster not found.")}, true},
+	"invalid parameter":     {&types.InvalidParameterException{}, true},
+	"access denied":         {&types.AccessDeniedException{}, true},
+	"generic access denied": {&smithy.GenericAPIError{Code: "AccessDenied"}, true},
+	"throttling":            {&smithy.GenericAPIError{Code: "ThrottlingException", Fault: smithy.FaultClient}, false},
+	"server exception":      {&types.ServerException{}, false},
+	"unknown fault":         {&smithy.GenericAPIError{Code: "InternalFailure"}, false},
+	"network error":         {errors.New("connection reset by peer"), false},
+	"deadline exceeded":     {context.DeadlineExceeded, false},
+}
+
+func TestIsPermanent(t *testing.T) {
+	for name, tc := range isPermanentTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := IsPermanent(tc.err); actual != tc.want {
+				t.Errorf("IsPermanent(%v) = %v, want %v", tc.err, actual, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package requeue sends the failed part of a message back to the queue the Lambda
+// consumes, delayed exponentially on each re-enqueue
+package requeue
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// Delay of the first re-enqueue, doubled on each re-enqueue
+	baseDelay = 30 * time.Second
+	// SQS maximum message delay
+	maxDelay = 15 * time.Minute
+)
+
+// SQS client re-enqueueing messages
+type SQSClient interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// Re-enqueues of the SQS message so far, counted by the message attribute
+func Count(record events.SQSMessage, attribute string) int {
+	value, ok := record.MessageAttributes[attribute]
+	if !ok || value.StringValue == nil {
+		return 0
+	}
+	requeueCount, _ := strconv.Atoi(*value.StringValue)
+	return requeueCount
+}
+
+// SQS delay of the re-enqueue, exponential on re-enqueues
+func Delay(requeueCount int) time.Duration {
+	if requeueCount >= 16 {
+		return maxDelay
+	}
+	return min(maxDelay, baseDelay<<requeueCount)
+}
+
+// Send message back to the queue to be delivered after delay, the re-enqueue
+// count is carried by the message attribute
+func Send(ctx context.Context, sqsClient SQSClient, requestId string, sqsQueueURL string, message any, attribute string, requeueCount int, delay time.Duration) (*string, error) {
+	messageBody, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("failed to json.Marshal for re-enqueued message", "requestId", requestId, "errorMessage", err)
+		return nil, err
+	}
+
+	sendMsgOutput, err := sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:     aws.String(sqsQueueURL),
+		MessageBody:  aws.String(string(messageBody)),
+		DelaySeconds: int32(delay / time.Second),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			attribute: {
+				DataType:    aws.String("Number"),
+				StringValue: aws.String(strconv.Itoa(requeueCount)),
+			},
+		},
+	})
+	if err != nil {
+		slog.Error("failed to re-enqueue message to SQS", "requestId", requestId, "errorMessage", err)
+		return nil, err
+	}
+	return sendMsgOutput.MessageId, nil
+}
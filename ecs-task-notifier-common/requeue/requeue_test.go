@@ -0,0 +1,76 @@
+package requeue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const testAttribute = "TestRequeueCount"
+
+// SQS client recording sent messages
+type fakeSQSClient struct {
+	messages []*sqs.SendMessageInput
+}
+
+func (sqsClient *fakeSQSClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	sqsClient.messages = append(sqsClient.messages, params)
+	return &sqs.SendMessageOutput{MessageId: aws.String("requeue-message-id")}, nil
+}
+
+var countTests = map[string]struct {
+	attributes map[string]events.SQSMessageAttribute
+	want       int
+}{
+	"not re-enqueued": {nil, 0},
+	"re-enqueued":     {map[string]events.SQSMessageAttribute{testAttribute: {StringValue: aws.String("2"), DataType: "Number"}}, 2},
+	"other attribute": {map[string]events.SQSMessageAttribute{"OtherRequeueCount": {StringValue: aws.String("2"), DataType: "Number"}}, 0},
+	"invalid count":   {map[string]events.SQSMessageAttribute{testAttribute: {StringValue: aws.String("many"), DataType: "Number"}}, 0},
+}
+
+func TestCount(t *testing.T) {
+	for name, tc := range countTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := Count(events.SQSMessage{MessageAttributes: tc.attributes}, testAttribute); actual != tc.want {
+				t.Errorf("Count() = %d, want %d", actual, tc.want)
+			}
+		})
+	}
+}
+
+var delayTests = map[int]time.Duration{
+	0:  30 * time.Second,
+	1:  time.Minute,
+	4:  8 * time.Minute,
+	5:  15 * time.Minute,
+	64: 15 * time.Minute,
+}
+
+func TestDelay(t *testing.T) {
+	for requeueCount, want := range delayTests {
+		if actual := Delay(requeueCount); actual != want {
+			t.Errorf("Delay(%d) = %v, want %v", requeueCount, actual, want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	sqsClient := &fakeSQSClient{}
+
+	messageId, err := Send(context.TODO(), sqsClient, "request-id", "queue-url", map[string]string{"cluster": "cluster-a"}, testAttribute, 2, time.Minute)
+	if err != nil || aws.ToString(messageId) != "requeue-message-id" {
+		t.Fatalf("Send() = %v, %v", aws.ToString(messageId), err)
+	}
+	if len(sqsClient.messages) != 1 {
+		t.Fatalf("sent messages = %d, want 1", len(sqsClient.messages))
+	}
+	message := sqsClient.messages[0]
+	if aws.ToString(message.MessageBody) != `{"cluster":"cluster-a"}` || message.DelaySeconds != 60 ||
+		aws.ToString(message.MessageAttributes[testAttribute].StringValue) != "2" {
+		t.Errorf("sent message = %+v", message)
+	}
+}
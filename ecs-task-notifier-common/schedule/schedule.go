@@ -0,0 +1,55 @@
+// Package schedule defines the scheduled notifications of schedules.json, provisioned by
+// the cdktf stack and validated by the schedule commands of ecs-task-notifier-test
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Scheduled notification, name, schedule and timezone make the EventBridge Scheduler
+// schedule and the other fields the message published to the observer queue
+type Definition struct {
+	Name string `json:"name,omitempty"`
+	// Schedule expression, cron(...), rate(...) or at(...)
+	Schedule string `json:"schedule,omitempty"`
+	// Timezone of cron and at expressions, UTC by default
+	Timezone    string            `json:"timezone,omitempty"`
+	Cluster     string            `json:"cluster,omitempty"`
+	Clusters    []string          `json:"clusters,omitempty"`
+	ClusterTags map[string]string `json:"cluster_tags,omitempty"`
+	RoleArn     string            `json:"role_arn,omitempty"`
+	Regions     []string          `json:"regions,omitempty"`
+	Topic       string            `json:"topic,omitempty"`
+	Selector    json.RawMessage   `json:"selector,omitempty"`
+	Payload     json.RawMessage   `json:"payload,omitempty"`
+}
+
+// Notification message of the schedule e.g. {"cluster":"*","topic":"cache.rotate"}
+func (definition Definition) Message() (string, error) {
+	definition.Name, definition.Schedule, definition.Timezone = "", "", ""
+	message, err := json.Marshal(definition)
+	if err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
+
+// Load the schedule definitions of the JSON file, unknown fields are rejected so that
+// a misspelled field fails instead of being left out of the message
+func LoadDefinitions(fileName string) ([]Definition, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var definitions []Definition
+	if err := decoder.Decode(&definitions); err != nil {
+		return nil, fmt.Errorf("invalid schedules file %s: %w", fileName, err)
+	}
+	return definitions, nil
+}
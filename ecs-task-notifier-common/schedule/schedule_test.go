@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSchedules(t *testing.T, content string) string {
+	fileName := filepath.Join(t.TempDir(), "schedules.json")
+	if err := os.WriteFile(fileName, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+var loadDefinitionsTests = map[string]struct {
+	content     string
+	wantMessage string
+	wantErr     bool
+}{
+	"notification fields": {
+		`[{"name": "nightly", "schedule": "cron(0 2 * * ? *)", "timezone": "Europe/Berlin", "cluster": "*", "topic": "cache.rotate", "payload": {"action": "rotate"}}]`,
+		`{"cluster":"*","topic":"cache.rotate","payload":{"action":"rotate"}}`, false,
+	},
+	"cluster tags and regions": {
+		`[{"name": "nightly", "schedule": "rate(1 day)", "cluster_tags": {"env": "prod"}, "regions": ["us-west-2"]}]`,
+		`{"cluster_tags":{"env":"prod"},"regions":["us-west-2"]}`, false,
+	},
+	"unknown field":   {`[{"name": "nightly", "schedule": "rate(1 day)", "cluster": "*", "topics": "cache.rotate"}]`, "", true},
+	"not a list":      {`{"name": "nightly"}`, "", true},
+	"invalid cluster": {`[{"name": "nightly", "schedule": "rate(1 day)", "cluster": ["*"]}]`, "", true},
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	for name, tc := range loadDefinitionsTests {
+		t.Run(name, func(t *testing.T) {
+			definitions, err := LoadDefinitions(writeSchedules(t, tc.content))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("LoadDefinitions() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			message, err := definitions[0].Message()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if message != tc.wantMessage {
+				t.Errorf("Message() = %s, want %s", message, tc.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Year range of EventBridge cron expressions
+const (
+	cronMinYear = 1970
+	cronMaxYear = 2199
+)
+
+var cronMonthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+// Day-of-week names, 1 is Sunday
+var cronWeekdayNames = map[string]int{
+	"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7,
+}
+
+// Schedule expression of EventBridge Scheduler, cron(...), rate(...) or at(...)
+type scheduleExpression interface {
+	// Fire times after the given time, at most count
+	next(after time.Time, count int) []time.Time
+}
+
+// Parse the schedule expression, fire times of cron and at expressions are in the location
+func parseScheduleExpression(expression string, location *time.Location) (scheduleExpression, error) {
+	expression = strings.TrimSpace(expression)
+	kind, rest, ok := strings.Cut(expression, "(")
+	if !ok || !strings.HasSuffix(rest, ")") {
+		return nil, fmt.Errorf("invalid schedule expression %q, expected cron(...), rate(...) or at(...)", expression)
+	}
+	rest = strings.TrimSuffix(rest, ")")
+
+	switch kind {
+	case "cron":
+		return parseCronExpression(rest, location)
+	case "rate":
+		return parseRateExpression(rest)
+	case "at":
+		at, err := time.ParseInLocation("2006-01-02T15:04:05", strings.TrimSpace(rest), location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid at expression %q: %w", rest, err)
+		}
+		return atExpression(at), nil
+	}
+	return nil, fmt.Errorf("invalid schedule expression %q, expected cron(...), rate(...) or at(...)", expression)
+}
+
+// One-time schedule
+type atExpression time.Time
+
+func (at atExpression) next(after time.Time, count int) []time.Time {
+	if count > 0 && time.Time(at).After(after) {
+		return []time.Time{time.Time(at)}
+	}
+	return nil
+}
+
+// Schedule firing every interval, rate schedules start when created
+type rateExpression time.Duration
+
+// Parse the value and unit of the rate expression e.g. "1 hour" or "15 minutes"
+func parseRateExpression(expression string) (rateExpression, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("invalid rate expression %q, expected value and unit", expression)
+	}
+	value, err := strconv.Atoi(fields[0])
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid rate value %q", fields[0])
+	}
+	// EventBridge Scheduler rejects "1 minutes" and "5 minute", singular unit of 1 only
+	units := map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}
+	unitName, plural := strings.CutSuffix(fields[1], "s")
+	unit, ok := units[unitName]
+	if !ok || plural != (value != 1) {
+		return 0, fmt.Errorf("invalid rate unit %q, expected minute, hour or day of 1, and minutes, hours or days otherwise", fields[1])
+	}
+	return rateExpression(time.Duration(value) * unit), nil
+}
+
+func (rate rateExpression) next(after time.Time, count int) []time.Time {
+	var times []time.Time
+	for i := 1; i <= count; i++ {
+		times = append(times, after.Add(time.Duration(i)*time.Duration(rate)))
+	}
+	return times
+}
+
+// Cron expression of the six fields minutes, hours, day-of-month, month, day-of-week and year
+type cronExpression struct {
+	location *time.Location
+	minutes  []bool
+	hours    []bool
+	months   []bool
+	years    []bool
+
+	// Day-of-month, unused when ?
+	anyDayOfMonth   bool
+	daysOfMonth     []bool
+	lastDayOfMonth  bool
+	nearestWeekdays []int
+	lastWeekday     bool
+
+	// Day-of-week, 1 is Sunday, unused when ?
+	anyDayOfWeek     bool
+	daysOfWeek       []bool
+	nthDaysOfWeek    map[int]int
+	lastDaysOfMonths []bool
+}
+
+// Parse the fields of a cron expression e.g. "0 2 * * ? *" or "0 9 ? * MON-FRI *"
+func parseCronExpression(expression string, location *time.Location) (*cronExpression, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("invalid cron expression %q, expected 6 fields", expression)
+	}
+
+	cron := &cronExpression{location: location}
+	var err error
+	if cron.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minutes: %w", err)
+	}
+	if cron.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hours: %w", err)
+	}
+	if err = cron.parseDayOfMonth(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid day-of-month: %w", err)
+	}
+	if cron.months, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("invalid month: %w", err)
+	}
+	if err = cron.parseDayOfWeek(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid day-of-week: %w", err)
+	}
+	if cron.years, err = parseCronField(fields[5], cronMinYear, cronMaxYear, nil); err != nil {
+		return nil, fmt.Errorf("invalid year: %w", err)
+	}
+	if cron.anyDayOfMonth == cron.anyDayOfWeek {
+		return nil, fmt.Errorf("invalid cron expression %q, either day-of-month or day-of-week must be ?", expression)
+	}
+	return cron, nil
+}
+
+// Day-of-month values, L the last day, nW the weekday nearest to day n and LW the last weekday
+func (cron *cronExpression) parseDayOfMonth(field string) error {
+	if field == "?" {
+		cron.anyDayOfMonth = true
+		return nil
+	}
+
+	var values []string
+	for _, value := range strings.Split(field, ",") {
+		switch {
+		case value == "L":
+			cron.lastDayOfMonth = true
+		case value == "LW":
+			cron.lastWeekday = true
+		case strings.HasSuffix(value, "W"):
+			day, err := parseCronValue(strings.TrimSuffix(value, "W"), 1, 31, nil)
+			if err != nil {
+				return err
+			}
+			cron.nearestWeekdays = append(cron.nearestWeekdays, day)
+		default:
+			values = append(values, value)
+		}
+	}
+
+	cron.daysOfMonth = make([]bool, 32)
+	if len(values) == 0 {
+		return nil
+	}
+	days, err := parseCronField(strings.Join(values, ","), 1, 31, nil)
+	if err != nil {
+		return err
+	}
+	cron.daysOfMonth = days
+	return nil
+}
+
+// Day-of-week values, d#n the nth weekday d of the month and dL the last weekday d of the month
+func (cron *cronExpression) parseDayOfWeek(field string) error {
+	if field == "?" {
+		cron.anyDayOfWeek = true
+		return nil
+	}
+
+	cron.nthDaysOfWeek = make(map[int]int)
+	cron.lastDaysOfMonths = make([]bool, 8)
+	var values []string
+	for _, value := range strings.Split(field, ",") {
+		switch {
+		case strings.Contains(value, "#"):
+			weekdayValue, nthValue, _ := strings.Cut(value, "#")
+			weekday, err := parseCronValue(weekdayValue, 1, 7, cronWeekdayNames)
+			if err != nil {
+				return err
+			}
+			nth, err := parseCronValue(nthValue, 1, 5, nil)
+			if err != nil {
+				return err
+			}
+			cron.nthDaysOfWeek[weekday] = nth
+		case value == "L":
+			// Last day of the week, Saturday
+			values = append(values, "7")
+		case strings.HasSuffix(value, "L"):
+			weekday, err := parseCronValue(strings.TrimSuffix(value, "L"), 1, 7, cronWeekdayNames)
+			if err != nil {
+				return err
+			}
+			cron.lastDaysOfMonths[weekday] = true
+		default:
+			values = append(values, value)
+		}
+	}
+
+	cron.daysOfWeek = make([]bool, 8)
+	if len(values) == 0 {
+		return nil
+	}
+	weekdays, err := parseCronField(strings.Join(values, ","), 1, 7, cronWeekdayNames)
+	if err != nil {
+		return err
+	}
+	cron.daysOfWeek = weekdays
+	return nil
+}
+
+// Parse the comma separated values, ranges a-b, steps */n or a/n and * of the field
+// into the set of values between min and max
+func parseCronField(field string, min int, max int, names map[string]int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		base, stepValue, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepValue); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		from, to := min, max
+		switch {
+		case base == "*":
+		case strings.Contains(base, "-"):
+			fromValue, toValue, _ := strings.Cut(base, "-")
+			var err error
+			if from, err = parseCronValue(fromValue, min, max, names); err != nil {
+				return nil, err
+			}
+			if to, err = parseCronValue(toValue, min, max, names); err != nil {
+				return nil, err
+			}
+			if from > to {
+				return nil, fmt.Errorf("invalid range %q", base)
+			}
+		default:
+			value, err := parseCronValue(base, min, max, names)
+			if err != nil {
+				return nil, err
+			}
+			from = value
+			// A single value without step, a/n steps up to max
+			if !hasStep {
+				to = value
+			}
+		}
+
+		for value := from; value <= to; value += step {
+			set[value] = true
+		}
+	}
+	return set, nil
+}
+
+// Parse the number or name of a field value between min and max
+func parseCronValue(value string, min int, max int, names map[string]int) (int, error) {
+	if number, ok := names[strings.ToUpper(value)]; ok {
+		return number, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min || number > max {
+		return 0, fmt.Errorf("invalid value %q, expected %d-%d", value, min, max)
+	}
+	return number, nil
+}
+
+// Check whether the cron expression fires on the day
+func (cron *cronExpression) matchesDay(day time.Time) bool {
+	if !cron.years[day.Year()] || !cron.months[int(day.Month())] {
+		return false
+	}
+	lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, cron.location).Day()
+
+	if cron.anyDayOfMonth {
+		weekday := int(day.Weekday()) + 1
+		if cron.daysOfWeek[weekday] || cron.lastDaysOfMonths[weekday] && day.Day()+7 > lastDay {
+			return true
+		}
+		nth, ok := cron.nthDaysOfWeek[weekday]
+		return ok && (day.Day()-1)/7+1 == nth
+	}
+
+	if cron.daysOfMonth[day.Day()] || cron.lastDayOfMonth && day.Day() == lastDay {
+		return true
+	}
+	if cron.lastWeekday && day.Day() == nearestWeekday(day, lastDay, lastDay) {
+		return true
+	}
+	for _, nearest := range cron.nearestWeekdays {
+		if nearest <= lastDay && day.Day() == nearestWeekday(day, nearest, lastDay) {
+			return true
+		}
+	}
+	return false
+}
+
+// Weekday nearest to the day of the month of the given day, within the month
+func nearestWeekday(day time.Time, dayOfMonth int, lastDay int) int {
+	switch time.Date(day.Year(), day.Month(), dayOfMonth, 0, 0, 0, 0, day.Location()).Weekday() {
+	case time.Saturday:
+		if dayOfMonth == 1 {
+			return 3
+		}
+		return dayOfMonth - 1
+	case time.Sunday:
+		if dayOfMonth == lastDay {
+			return dayOfMonth - 2
+		}
+		return dayOfMonth + 1
+	}
+	return dayOfMonth
+}
+
+func (cron *cronExpression) next(after time.Time, count int) []time.Time {
+	var times []time.Time
+	after = after.In(cron.location)
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, cron.location)
+	for len(times) < count && day.Year() <= cronMaxYear {
+		if !cron.years[day.Year()] {
+			day = time.Date(day.Year()+1, time.January, 1, 0, 0, 0, 0, cron.location)
+			continue
+		}
+		if cron.matchesDay(day) {
+			for hour := 0; hour < 24 && len(times) < count; hour++ {
+				for minute := 0; minute < 60 && len(times) < count; minute++ {
+					if !cron.hours[hour] || !cron.minutes[minute] {
+						continue
+					}
+					fireTime := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, cron.location)
+					// Local times skipped by daylight saving time changes do not fire
+					if fireTime.Hour() == hour && fireTime.After(after) {
+						times = append(times, fireTime)
+					}
+				}
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return times
+}
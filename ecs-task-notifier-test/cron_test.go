@@ -0,0 +1,155 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var parseScheduleExpressionErrorTests = map[string]string{
+	"unknown kind":            "every(5 minutes)",
+	"missing parenthesis":     "cron(0 2 * * ? *",
+	"five fields":             "cron(0 2 * * ?)",
+	"both days":               "cron(0 2 * * * *)",
+	"both days unset":         "cron(0 2 ? * ? *)",
+	"minute out of range":     "cron(60 2 * * ? *)",
+	"invalid range":           "cron(0 5-2 * * ? *)",
+	"invalid step":            "cron(*/0 * * * ? *)",
+	"invalid month name":      "cron(0 2 ? FOO MON *)",
+	"invalid nth weekday":     "cron(0 2 ? * MON#6 *)",
+	"year out of range":       "cron(0 2 * * ? 2200)",
+	"rate without unit":       "rate(5)",
+	"rate unknown unit":       "rate(5 weeks)",
+	"rate zero":               "rate(0 minutes)",
+	"rate plural of one":      "rate(1 minutes)",
+	"rate singular of many":   "rate(5 hour)",
+	"at without time":         "at(2026-10-18)",
+	"at invalid date":         "at(2026-13-01T00:00:00)",
+	"nearest weekday invalid": "cron(0 2 32W * ? *)",
+}
+
+func TestParseScheduleExpressionErrors(t *testing.T) {
+	for name, expression := range parseScheduleExpressionErrorTests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseScheduleExpression(expression, time.UTC); err == nil {
+				t.Errorf("parseScheduleExpression(%q) error = nil, want error", expression)
+			}
+		})
+	}
+}
+
+// Saturday 2026-10-17 10:30 UTC
+var scheduleAfter = time.Date(2026, time.October, 17, 10, 30, 0, 0, time.UTC)
+
+var scheduleExpressionNextTests = map[string]struct {
+	expression string
+	count      int
+	want       []string
+}{
+	"daily": {"cron(0 2 * * ? *)", 2, []string{
+		"2026-10-18T02:00:00Z", "2026-10-19T02:00:00Z",
+	}},
+	"later today": {"cron(45 10 * * ? *)", 1, []string{
+		"2026-10-17T10:45:00Z",
+	}},
+	"minute steps": {"cron(0/20 11 * * ? *)", 3, []string{
+		"2026-10-17T11:00:00Z", "2026-10-17T11:20:00Z", "2026-10-17T11:40:00Z",
+	}},
+	"weekdays": {"cron(0 9 ? * MON-FRI *)", 2, []string{
+		"2026-10-19T09:00:00Z", "2026-10-20T09:00:00Z",
+	}},
+	"weekday numbers": {"cron(0 9 ? * 1,7 *)", 2, []string{
+		"2026-10-18T09:00:00Z", "2026-10-24T09:00:00Z",
+	}},
+	"hour list": {"cron(0 8,20 * * ? *)", 2, []string{
+		"2026-10-17T20:00:00Z", "2026-10-18T08:00:00Z",
+	}},
+	"first of month": {"cron(0 0 1 * ? *)", 2, []string{
+		"2026-11-01T00:00:00Z", "2026-12-01T00:00:00Z",
+	}},
+	"last day of month": {"cron(0 0 L * ? *)", 2, []string{
+		"2026-10-31T00:00:00Z", "2026-11-30T00:00:00Z",
+	}},
+	"last weekday of month": {"cron(0 0 LW * ? *)", 2, []string{
+		"2026-10-30T00:00:00Z", "2026-11-30T00:00:00Z",
+	}},
+	"nearest weekday": {"cron(0 0 1W * ? *)", 2, []string{
+		"2026-11-02T00:00:00Z", "2026-12-01T00:00:00Z",
+	}},
+	"nth weekday": {"cron(0 0 ? * MON#1 *)", 2, []string{
+		"2026-11-02T00:00:00Z", "2026-12-07T00:00:00Z",
+	}},
+	"last weekday": {"cron(0 0 ? * 6L *)", 2, []string{
+		"2026-10-30T00:00:00Z", "2026-11-27T00:00:00Z",
+	}},
+	"month names": {"cron(0 0 1 JAN,JUL ? *)", 2, []string{
+		"2027-01-01T00:00:00Z", "2027-07-01T00:00:00Z",
+	}},
+	"leap day": {"cron(0 0 29 FEB ? *)", 1, []string{
+		"2028-02-29T00:00:00Z",
+	}},
+	"year": {"cron(0 0 1 1 ? 2030)", 2, []string{
+		"2030-01-01T00:00:00Z",
+	}},
+	"past year":   {"cron(0 0 1 1 ? 2020)", 1, nil},
+	"never fires": {"cron(0 0 31 FEB ? *)", 1, nil},
+	"rate": {"rate(15 minutes)", 2, []string{
+		"2026-10-17T10:45:00Z", "2026-10-17T11:00:00Z",
+	}},
+	"rate singular": {"rate(1 day)", 1, []string{
+		"2026-10-18T10:30:00Z",
+	}},
+	"at": {"at(2026-12-24T18:00:00)", 3, []string{
+		"2026-12-24T18:00:00Z",
+	}},
+	"at past": {"at(2026-01-01T00:00:00)", 1, nil},
+}
+
+func TestScheduleExpressionNext(t *testing.T) {
+	for name, tc := range scheduleExpressionNextTests {
+		t.Run(name, func(t *testing.T) {
+			expression, err := parseScheduleExpression(tc.expression, time.UTC)
+			if err != nil {
+				t.Fatalf("parseScheduleExpression(%q) error = %v", tc.expression, err)
+			}
+			actual := expression.next(scheduleAfter, tc.count)
+			if len(actual) != len(tc.want) {
+				t.Fatalf("next() = %v, want %v", actual, tc.want)
+			}
+			for i, fireTime := range actual {
+				if fireTime.UTC().Format(time.RFC3339) != tc.want[i] {
+					t.Errorf("next()[%d] = %s, want %s", i, fireTime.UTC().Format(time.RFC3339), tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCronExpressionTimezone(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expression, err := parseScheduleExpression("cron(30 2 * * ? *)", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Daylight saving time ends 2026-10-25 and starts 2027-03-28 skipping 2:30
+	want := map[string]string{
+		"2026-10-24": "2026-10-24T00:30:00Z",
+		"2026-10-26": "2026-10-26T01:30:00Z",
+	}
+	for _, fireTime := range expression.next(scheduleAfter, 10) {
+		day := fireTime.Format("2006-01-02")
+		if utc, ok := want[day]; ok && fireTime.UTC().Format(time.RFC3339) != utc {
+			t.Errorf("next() on %s = %s, want %s", day, fireTime.UTC().Format(time.RFC3339), utc)
+		}
+	}
+
+	spring := time.Date(2027, time.March, 27, 12, 0, 0, 0, location)
+	for _, fireTime := range expression.next(spring, 2) {
+		if fireTime.Format("2006-01-02") == "2027-03-28" {
+			t.Errorf("next() = %s, want no fire time on skipped local time", fireTime)
+		}
+	}
+}
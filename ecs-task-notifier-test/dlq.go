@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Messages received from dead letter queue stay invisible while processed
+	dlqVisibilityTimeout = 60
+	// Maximum messages per SQS ReceiveMessage call
+	dlqReceiveBatchSize = 10
+	// Message attribute of the permanent error routing a message to dead letter queue
+	dlqErrorAttribute = "ErrorMessage"
+)
+
+// Message filter on cluster and service names, empty value matches all
+type messageFilter struct {
+	cluster string
+	service string
+}
+
+// Check if the message body matches the cluster and service filter
+func (filter messageFilter) matches(body string) bool {
+	if filter.cluster == "" && filter.service == "" {
+		return true
+	}
+
+	var message struct {
+		Cluster  string   `json:"cluster"`
+		Clusters []string `json:"clusters"`
+		Service  string   `json:"service"`
+	}
+	if err := json.Unmarshal([]byte(body), &message); err != nil {
+		// Unparsable messages match only without filter
+		return false
+	}
+
+	if filter.cluster != "" && !clusterMatches(append(message.Clusters, message.Cluster), filter.cluster) {
+		return false
+	}
+	if filter.service != "" && filter.service != message.Service {
+		return false
+	}
+	return true
+}
+
+// Check if the cluster is targeted by the message clusters, cluster globs
+// e.g. "*" of notifications broadcast to several clusters match as well
+func clusterMatches(messageClusters []string, cluster string) bool {
+	for _, messageCluster := range messageClusters {
+		if matched, _ := path.Match(messageCluster, cluster); matched || messageCluster == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+// SQS Redrive Policy of a source queue
+type redrivePolicy struct {
+	DeadLetterTargetArn string      `json:"deadLetterTargetArn"`
+	MaxReceiveCount     json.Number `json:"maxReceiveCount"`
+}
+
+// Queue name is the last element of queue URL and queue ARN
+func queueNameOf(queueURLOrArn string) string {
+	if idx := strings.LastIndexAny(queueURLOrArn, "/:"); idx >= 0 {
+		return queueURLOrArn[idx+1:]
+	}
+	return queueURLOrArn
+}
+
+func newDlqCommand(awsRegion *string) *cobra.Command {
+	dlqCmd := &cobra.Command{
+		Use:   "dlq",
+		Short: "Inspect and redrive dead letter queue messages",
+	}
+
+	dlqCmd.AddCommand(newDlqListCommand(awsRegion))
+	dlqCmd.AddCommand(newDlqInspectCommand(awsRegion))
+	dlqCmd.AddCommand(newDlqRedriveCommand(awsRegion))
+
+	return dlqCmd
+}
+
+func newDlqListCommand(awsRegion *string) *cobra.Command {
+	var queueNamePrefix string
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List source queues with their dead letter queue",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client := newSQSClient(ctx, *awsRegion)
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "SOURCE QUEUE\tDEAD LETTER QUEUE\tMAX RECEIVE COUNT\tMESSAGES")
+
+			input := &sqs.ListQueuesInput{}
+			if queueNamePrefix != "" {
+				input.QueueNamePrefix = aws.String(queueNamePrefix)
+			}
+			paginator := sqs.NewListQueuesPaginator(client, input)
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return err
+				}
+
+				for _, queueURL := range page.QueueUrls {
+					attributes, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+						QueueUrl:       aws.String(queueURL),
+						AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameRedrivePolicy},
+					})
+					if err != nil {
+						return err
+					}
+
+					policyValue, ok := attributes.Attributes[string(types.QueueAttributeNameRedrivePolicy)]
+					if !ok {
+						continue
+					}
+					var policy redrivePolicy
+					if err := json.Unmarshal([]byte(policyValue), &policy); err != nil {
+						return fmt.Errorf("invalid redrive policy of %s: %w", queueNameOf(queueURL), err)
+					}
+
+					dlqName := queueNameOf(policy.DeadLetterTargetArn)
+					messages := "-"
+					if dlqURL, err := getQueueURL(ctx, client, dlqName); err == nil {
+						if count, err := approximateNumberOfMessages(ctx, client, dlqURL); err == nil {
+							messages = count
+						}
+					}
+					fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", queueNameOf(queueURL), dlqName, policy.MaxReceiveCount, messages)
+				}
+			}
+			return writer.Flush()
+		},
+	}
+
+	listCmd.Flags().StringVarP(&queueNamePrefix, "queue-name-prefix", "x", "", "SQS Queue Name Prefix")
+	return listCmd
+}
+
+func newDlqInspectCommand(awsRegion *string) *cobra.Command {
+	var dlqName string
+	var maxMessages int
+	var filter messageFilter
+
+	inspectCmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Print messages of a dead letter queue without removing them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client := newSQSClient(ctx, *awsRegion)
+
+			dlqURL, err := getQueueURL(ctx, client, dlqName)
+			if err != nil {
+				return err
+			}
+
+			received := make(scannedMessages)
+			defer func() { releaseMessages(ctx, client, dlqURL, received.messages()) }()
+
+			printed := 0
+			for printed < maxMessages {
+				messages, err := receiveMessages(ctx, client, dlqURL)
+				if err != nil {
+					return err
+				}
+				// Queue scanned once messages received earlier become visible again
+				messages = received.add(messages)
+				if len(messages) == 0 {
+					break
+				}
+
+				for _, message := range messages {
+					if printed >= maxMessages || !filter.matches(aws.ToString(message.Body)) {
+						continue
+					}
+					fmt.Printf("MessageId: %s ReceiveCount: %s\n", aws.ToString(message.MessageId),
+						message.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+					if errorMessage, ok := message.MessageAttributes[dlqErrorAttribute]; ok {
+						fmt.Printf("Error: %s\n", aws.ToString(errorMessage.StringValue))
+					}
+					fmt.Printf("%s\n\n", aws.ToString(message.Body))
+					printed++
+				}
+			}
+			fmt.Println("Total messages matched:", printed)
+			return nil
+		},
+	}
+
+	inspectCmd.Flags().StringVarP(&dlqName, "dlq-name", "q", "", "Dead Letter Queue Name")
+	inspectCmd.Flags().IntVarP(&maxMessages, "max-messages", "n", 10, "Maximum number of messages to print")
+	inspectCmd.Flags().StringVarP(&filter.cluster, "ecs-cluster-name", "c", "", "Filter messages by ECS Cluster Name")
+	inspectCmd.Flags().StringVarP(&filter.service, "ecs-service-name", "s", "", "Filter messages by ECS Service Name")
+	inspectCmd.MarkFlagRequired("dlq-name")
+
+	return inspectCmd
+}
+
+func newDlqRedriveCommand(awsRegion *string) *cobra.Command {
+	var dlqName, sourceQueueName string
+	var maxMessages int
+	var dryRun bool
+	var filter messageFilter
+
+	redriveCmd := &cobra.Command{
+		Use:   "redrive",
+		Short: "Move dead letter queue messages back to their source queue",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client := newSQSClient(ctx, *awsRegion)
+
+			dlqURL, err := getQueueURL(ctx, client, dlqName)
+			if err != nil {
+				return err
+			}
+
+			sourceQueueURL, err := resolveSourceQueueURL(ctx, client, dlqURL, sourceQueueName)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Redrive messages from", dlqName, "to", queueNameOf(aws.ToString(sourceQueueURL)))
+
+			// Messages not moved are made visible again on completion
+			received := make(scannedMessages)
+			defer func() { releaseMessages(ctx, client, dlqURL, received.messages()) }()
+
+			moved := 0
+			for moved < maxMessages {
+				messages, err := receiveMessages(ctx, client, dlqURL)
+				if err != nil {
+					return err
+				}
+				// Queue scanned once messages received earlier become visible again
+				messages = received.add(messages)
+				if len(messages) == 0 {
+					break
+				}
+
+				for _, message := range messages {
+					if moved >= maxMessages || !filter.matches(aws.ToString(message.Body)) {
+						continue
+					}
+
+					if dryRun {
+						fmt.Println("Would redrive message:", aws.ToString(message.MessageId))
+						moved++
+						continue
+					}
+
+					if _, err := client.SendMessage(ctx, &sqs.SendMessageInput{
+						QueueUrl:    sourceQueueURL,
+						MessageBody: message.Body,
+					}); err != nil {
+						return fmt.Errorf("failed to send message %s: %w", aws.ToString(message.MessageId), err)
+					}
+
+					if _, err := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+						QueueUrl:      dlqURL,
+						ReceiptHandle: message.ReceiptHandle,
+					}); err != nil {
+						return fmt.Errorf("failed to delete message %s: %w", aws.ToString(message.MessageId), err)
+					}
+					received.remove(message)
+					fmt.Println("Redrive message:", aws.ToString(message.MessageId))
+					moved++
+				}
+			}
+
+			if dryRun {
+				fmt.Println("Total messages to redrive:", moved)
+			} else {
+				fmt.Println("Total messages redriven:", moved)
+			}
+			return nil
+		},
+	}
+
+	redriveCmd.Flags().StringVarP(&dlqName, "dlq-name", "q", "", "Dead Letter Queue Name")
+	redriveCmd.Flags().StringVarP(&sourceQueueName, "source-queue-name", "t", "", "Source Queue Name (default: queue having redrive policy to the dead letter queue)")
+	redriveCmd.Flags().IntVarP(&maxMessages, "max-messages", "n", 100, "Maximum number of messages to redrive")
+	redriveCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print messages to redrive without moving them")
+	redriveCmd.Flags().StringVarP(&filter.cluster, "ecs-cluster-name", "c", "", "Redrive messages of ECS Cluster Name only")
+	redriveCmd.Flags().StringVarP(&filter.service, "ecs-service-name", "s", "", "Redrive messages of ECS Service Name only")
+	redriveCmd.MarkFlagRequired("dlq-name")
+
+	return redriveCmd
+}
+
+// Source queue is either given by name or the only queue having
+// redrive policy targeting the dead letter queue
+func resolveSourceQueueURL(ctx context.Context, client *sqs.Client, dlqURL *string, sourceQueueName string) (*string, error) {
+	if sourceQueueName != "" {
+		return getQueueURL(ctx, client, sourceQueueName)
+	}
+
+	var sourceQueueURLs []string
+	paginator := sqs.NewListDeadLetterSourceQueuesPaginator(client, &sqs.ListDeadLetterSourceQueuesInput{
+		QueueUrl: dlqURL,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sourceQueueURLs = append(sourceQueueURLs, page.QueueUrls...)
+	}
+
+	switch len(sourceQueueURLs) {
+	case 0:
+		return nil, errors.New("no source queue found for dead letter queue, use --source-queue-name")
+	case 1:
+		return aws.String(sourceQueueURLs[0]), nil
+	default:
+		return nil, errors.New("multiple source queues found for dead letter queue, use --source-queue-name")
+	}
+}
+
+func receiveMessages(ctx context.Context, client *sqs.Client, queueURL *string) ([]types.Message, error) {
+	output, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              queueURL,
+		MaxNumberOfMessages:   dlqReceiveBatchSize,
+		VisibilityTimeout:     dlqVisibilityTimeout,
+		WaitTimeSeconds:       1,
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MessageAttributeNames: []string{dlqErrorAttribute},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Messages, nil
+}
+
+// Messages received while scanning a queue by message Id. Messages still held when
+// their visibility timeout expires are received again, with a new receipt handle
+type scannedMessages map[string]types.Message
+
+// Record the received messages, returning the messages not received before
+func (scanned scannedMessages) add(messages []types.Message) []types.Message {
+	var unseen []types.Message
+	for _, message := range messages {
+		messageId := aws.ToString(message.MessageId)
+		if _, ok := scanned[messageId]; !ok {
+			unseen = append(unseen, message)
+		}
+		// Latest receipt handle releases the message
+		scanned[messageId] = message
+	}
+	return unseen
+}
+
+// Forget a message deleted from the queue
+func (scanned scannedMessages) remove(message types.Message) {
+	delete(scanned, aws.ToString(message.MessageId))
+}
+
+// Messages received and not deleted
+func (scanned scannedMessages) messages() []types.Message {
+	messages := make([]types.Message, 0, len(scanned))
+	for _, message := range scanned {
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// Make received messages visible again on the queue
+func releaseMessages(ctx context.Context, client *sqs.Client, queueURL *string, messages []types.Message) {
+	for _, message := range messages {
+		if _, err := client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          queueURL,
+			ReceiptHandle:     message.ReceiptHandle,
+			VisibilityTimeout: 0,
+		}); err != nil {
+			fmt.Println("Error releasing message:", aws.ToString(message.MessageId), err)
+		}
+	}
+}
+
+func approximateNumberOfMessages(ctx context.Context, client *sqs.Client, queueURL *string) (string, error) {
+	attributes, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       queueURL,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+	})
+	if err != nil {
+		return "", err
+	}
+	return attributes.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)], nil
+}
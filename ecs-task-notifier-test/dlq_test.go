@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+var messageFilterTests = map[string]struct {
+	filter messageFilter
+	body   string
+	want   bool
+}{
+	"no filter matches all":      {messageFilter{}, `not json`, true},
+	"cluster filter match":       {messageFilter{cluster: "c1"}, `{"cluster":"c1"}`, true},
+	"cluster filter mismatch":    {messageFilter{cluster: "c1"}, `{"cluster":"c2"}`, false},
+	"service filter match":       {messageFilter{service: "s1"}, `{"cluster":"c1","service":"s1"}`, true},
+	"service filter no service":  {messageFilter{service: "s1"}, `{"cluster":"c1"}`, false},
+	"cluster and service filter": {messageFilter{"c1", "s1"}, `{"cluster":"c1","service":"s1"}`, true},
+	"cluster filter in list":     {messageFilter{cluster: "c1"}, `{"clusters":["c2","c1"]}`, true},
+	"cluster filter not in list": {messageFilter{cluster: "c1"}, `{"clusters":["c2","c3"]}`, false},
+	"cluster filter broadcast":   {messageFilter{cluster: "team-a"}, `{"cluster":"team-*"}`, true},
+	"filter on unparsable body":  {messageFilter{cluster: "c1"}, `{`, false},
+}
+
+func TestMessageFilterMatches(t *testing.T) {
+	for name, tc := range messageFilterTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := tc.filter.matches(tc.body); actual != tc.want {
+				t.Errorf("matches() = %v, want %v", actual, tc.want)
+			}
+		})
+	}
+}
+
+var queueNameOfTests = map[string]struct {
+	value string
+	want  string
+}{
+	"queue url": {"https://sqs.us-east-1.amazonaws.com/123456789012/ecs-services-us-east-1", "ecs-services-us-east-1"},
+	"queue arn": {"arn:aws:sqs:us-east-1:123456789012:ecs-services-us-east-1-dlq", "ecs-services-us-east-1-dlq"},
+	"name":      {"ecs-services", "ecs-services"},
+}
+
+func TestQueueNameOf(t *testing.T) {
+	for name, tc := range queueNameOfTests {
+		t.Run(name, func(t *testing.T) {
+			if actual := queueNameOf(tc.value); actual != tc.want {
+				t.Errorf("queueNameOf() = %v, want %v", actual, tc.want)
+			}
+		})
+	}
+}
+
+func TestScannedMessages(t *testing.T) {
+	message := func(messageId string, receiptHandle string) types.Message {
+		return types.Message{MessageId: aws.String(messageId), ReceiptHandle: aws.String(receiptHandle)}
+	}
+	scanned := make(scannedMessages)
+
+	if unseen := scanned.add([]types.Message{message("m1", "r1"), message("m2", "r2")}); len(unseen) != 2 {
+		t.Fatalf("add() = %v, want 2 unseen messages", unseen)
+	}
+
+	// Messages visible again after the visibility timeout are received with a new receipt handle
+	unseen := scanned.add([]types.Message{message("m1", "r3"), message("m3", "r4")})
+	if len(unseen) != 1 || aws.ToString(unseen[0].MessageId) != "m3" {
+		t.Fatalf("add() = %v, want m3 only", unseen)
+	}
+	if unseen := scanned.add([]types.Message{message("m2", "r5")}); len(unseen) != 0 {
+		t.Fatalf("add() = %v, want no unseen messages", unseen)
+	}
+
+	scanned.remove(message("m3", "r4"))
+	receiptHandles := make(map[string]string)
+	for _, message := range scanned.messages() {
+		receiptHandles[aws.ToString(message.MessageId)] = aws.ToString(message.ReceiptHandle)
+	}
+	if len(receiptHandles) != 2 || receiptHandles["m1"] != "r3" || receiptHandles["m2"] != "r5" {
+		t.Errorf("messages() = %v, want m1 and m2 with latest receipt handles", receiptHandles)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -21,22 +22,61 @@ func getQueueURL(ctx context.Context, client *sqs.Client, queueName string) (*st
 	return output.QueueUrl, nil
 }
 
+// Load AWS configuration and build SQS client for the AWS Region
+func newSQSClient(ctx context.Context, awsRegion string) *sqs.Client {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(awsRegion))
+	if err != nil {
+		fmt.Println("Error loading AWS configuration:", err)
+		os.Exit(1)
+	}
+	return sqs.NewFromConfig(cfg)
+}
+
+// Selector flags targeting services of the cluster
+type selectorFlags struct {
+	services               []string
+	serviceNamePattern     string
+	serviceNameRegex       string
+	tags                   map[string]string
+	taskDefinitionFamilies []string
+}
+
+// Service selector of the message, nil without selector flags
+func (flags selectorFlags) selector() map[string]interface{} {
+	selector := make(map[string]interface{})
+	if len(flags.services) > 0 {
+		selector["services"] = flags.services
+	}
+	if flags.serviceNamePattern != "" {
+		selector["service_name_pattern"] = flags.serviceNamePattern
+	}
+	if flags.serviceNameRegex != "" {
+		selector["service_name_regex"] = flags.serviceNameRegex
+	}
+	if len(flags.tags) > 0 {
+		selector["tags"] = flags.tags
+	}
+	if len(flags.taskDefinitionFamilies) > 0 {
+		selector["task_definition_families"] = flags.taskDefinitionFamilies
+	}
+	if len(selector) == 0 {
+		return nil
+	}
+	return selector
+}
+
 func main() {
-	var awsRegion, ecsClusterName, sqsQueueName string
+	var awsRegion, sqsQueueName, roleArn, topic, payload string
+	var ecsClusterNames, regions []string
+	var clusterTags map[string]string
+	var selector selectorFlags
 
 	// Initialize the CLI application
 	rootCmd := &cobra.Command{
 		Use:   "ecs-task-notifier",
 		Short: "Send notifications to ECS service",
 		Run: func(cmd *cobra.Command, args []string) {
-			// Load AWS configuration
-			cfg, err := config.LoadDefaultConfig(context.Background())
-			if err != nil {
-				fmt.Println("Error loading AWS configuration:", err)
-				os.Exit(1)
-			}
-
-			client := sqs.NewFromConfig(cfg)
+			client := newSQSClient(context.Background(), awsRegion)
 
 			// Get SQS queue URL
 			queueURL, err := getQueueURL(context.Background(), client, sqsQueueName)
@@ -46,7 +86,40 @@ func main() {
 			}
 
 			// Define the message body
-			messageBody := fmt.Sprintf(`{"cluster": "%s"}`, ecsClusterName)
+			message := map[string]interface{}{}
+			if len(ecsClusterNames) == 1 {
+				message["cluster"] = ecsClusterNames[0]
+			} else {
+				message["clusters"] = ecsClusterNames
+			}
+			if len(clusterTags) > 0 {
+				message["cluster_tags"] = clusterTags
+			}
+			if roleArn != "" {
+				message["role_arn"] = roleArn
+			}
+			if len(regions) > 0 {
+				message["regions"] = regions
+			}
+			if topic != "" {
+				message["topic"] = topic
+			}
+			if serviceSelector := selector.selector(); serviceSelector != nil {
+				message["selector"] = serviceSelector
+			}
+			if payload != "" {
+				if !json.Valid([]byte(payload)) {
+					fmt.Println("Error payload is not valid JSON:", payload)
+					os.Exit(1)
+				}
+				message["payload"] = json.RawMessage(payload)
+			}
+			messageBytes, err := json.Marshal(message)
+			if err != nil {
+				fmt.Println("Error building message:", err)
+				os.Exit(1)
+			}
+			messageBody := string(messageBytes)
 
 			// Send message to SQS queue
 			result, err := client.SendMessage(context.Background(), &sqs.SendMessageInput{
@@ -63,14 +136,30 @@ func main() {
 	}
 
 	// Define flags for CLI parameters with short form options
-	rootCmd.Flags().StringVarP(&awsRegion, "aws-region", "r", "us-east-1", "AWS Region")
-	rootCmd.Flags().StringVarP(&ecsClusterName, "ecs-cluster-name", "c", "", "ECS Cluster Name")
+	rootCmd.PersistentFlags().StringVarP(&awsRegion, "aws-region", "r", "us-east-1", "AWS Region")
+	rootCmd.Flags().StringSliceVarP(&ecsClusterNames, "ecs-cluster-name", "c", nil, "ECS Cluster Names or globs e.g. '*' for all clusters")
+	rootCmd.Flags().StringToStringVar(&clusterTags, "cluster-tag", nil, "Target ECS Cluster Tags e.g. env=prod")
 	rootCmd.Flags().StringVarP(&sqsQueueName, "sqs-queue-name", "q", "", "SQS Queue Name")
+	rootCmd.Flags().StringSliceVar(&regions, "region", nil, "Target AWS Regions of the ECS Clusters, the AWS Region by default")
+	rootCmd.Flags().StringVar(&roleArn, "role-arn", "", "Workload Account Role ARN of the ECS Clusters")
+	rootCmd.Flags().StringVarP(&topic, "topic", "t", "", "Notification Topic e.g. config.reload")
+	rootCmd.Flags().StringVarP(&payload, "payload", "p", "", "Event Payload (JSON) passed to Notify API, the notification message without payload")
+	rootCmd.Flags().StringSliceVar(&selector.services, "service", nil, "Target ECS Service Names")
+	rootCmd.Flags().StringVar(&selector.serviceNamePattern, "service-pattern", "", "Target ECS Service Name glob e.g. orders-*")
+	rootCmd.Flags().StringVar(&selector.serviceNameRegex, "service-regex", "", "Target ECS Service Name regular expression")
+	rootCmd.Flags().StringToStringVar(&selector.tags, "tag", nil, "Target ECS Service Tags e.g. team=payments")
+	rootCmd.Flags().StringSliceVar(&selector.taskDefinitionFamilies, "family", nil, "Target Task Definition Families")
 
 	// Bind flags to environment variables
 	rootCmd.MarkFlagRequired("ecs-cluster-name")
 	rootCmd.MarkFlagRequired("sqs-queue-name")
 
+	// Dead letter queue commands
+	rootCmd.AddCommand(newDlqCommand(&awsRegion))
+
+	// Scheduled notification commands
+	rootCmd.AddCommand(newScheduleCommand())
+
 	// Execute the CLI application
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
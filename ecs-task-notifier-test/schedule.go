@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/jittakal/ecs-task-notifier/ecs-task-notifier-common/schedule"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Schedules of the cdktf configuration, relative to this directory
+	defaultSchedulesFile = "../ecs-task-notifier-cdktf/schedules.json"
+	// Layout of the printed fire times
+	fireTimeLayout = "2006-01-02 15:04 MST"
+)
+
+// Names of EventBridge Scheduler schedules
+var scheduleNamePattern = regexp.MustCompile(`^[0-9a-zA-Z_.-]{1,64}$`)
+
+// Scheduled notification of the cdktf configuration, published to the observer queue
+// with the message of the notification fields
+type scheduleDefinition schedule.Definition
+
+// Location of the schedule timezone, UTC by default
+func (definition scheduleDefinition) location() (*time.Location, error) {
+	if definition.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(definition.Timezone)
+}
+
+// Parse the schedule expression in the schedule timezone
+func (definition scheduleDefinition) expression() (scheduleExpression, error) {
+	location, err := definition.location()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", definition.Timezone, err)
+	}
+	return parseScheduleExpression(definition.Schedule, location)
+}
+
+// Validate the schedule name, expression, timezone and target clusters
+func (definition scheduleDefinition) validate() error {
+	if !scheduleNamePattern.MatchString(definition.Name) {
+		return fmt.Errorf("invalid name %q, expected 1-64 letters, digits, '.', '_' or '-'", definition.Name)
+	}
+	if _, err := definition.expression(); err != nil {
+		return err
+	}
+	if definition.Cluster == "" && len(definition.Clusters) == 0 && len(definition.ClusterTags) == 0 {
+		return errors.New("missing cluster, clusters or cluster_tags")
+	}
+	return nil
+}
+
+// Target clusters of the schedule e.g. "team-a,team-b env=prod"
+func (definition scheduleDefinition) target() string {
+	clusters := definition.Clusters
+	if definition.Cluster != "" {
+		clusters = append([]string{definition.Cluster}, clusters...)
+	}
+	target := strings.Join(clusters, ",")
+	keys := make([]string, 0, len(definition.ClusterTags))
+	for key := range definition.ClusterTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		target = strings.TrimSpace(target + " " + key + "=" + definition.ClusterTags[key])
+	}
+	if definition.Topic != "" {
+		target += " topic=" + definition.Topic
+	}
+	return target
+}
+
+// Load the schedule definitions of the JSON file, unknown fields are rejected
+func loadScheduleDefinitions(fileName string) ([]scheduleDefinition, error) {
+	loaded, err := schedule.LoadDefinitions(fileName)
+	if err != nil {
+		return nil, err
+	}
+	definitions := make([]scheduleDefinition, len(loaded))
+	for i, definition := range loaded {
+		definitions[i] = scheduleDefinition(definition)
+	}
+	return definitions, nil
+}
+
+// Validate the schedule definitions, duplicate names included, error per invalid schedule
+func validateScheduleDefinitions(definitions []scheduleDefinition) []error {
+	var errs []error
+	names := make(map[string]bool)
+	for i, definition := range definitions {
+		if err := definition.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("schedule %d %q: %w", i, definition.Name, err))
+		}
+		if names[definition.Name] {
+			errs = append(errs, fmt.Errorf("schedule %d %q: duplicate name", i, definition.Name))
+		}
+		names[definition.Name] = true
+	}
+	return errs
+}
+
+func newScheduleCommand() *cobra.Command {
+	var schedulesFile string
+
+	scheduleCmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "List and validate scheduled notifications",
+	}
+	scheduleCmd.PersistentFlags().StringVarP(&schedulesFile, "file", "f", defaultSchedulesFile, "Schedules file of the cdktf configuration")
+
+	scheduleCmd.AddCommand(newScheduleListCommand(&schedulesFile))
+	scheduleCmd.AddCommand(newScheduleValidateCommand(&schedulesFile))
+
+	return scheduleCmd
+}
+
+func newScheduleListCommand(schedulesFile *string) *cobra.Command {
+	var count int
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List schedules with their next fire times",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			definitions, err := loadScheduleDefinitions(*schedulesFile)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "NAME\tSCHEDULE\tTIMEZONE\tTARGET\tNEXT FIRE TIMES")
+			for _, definition := range definitions {
+				fireTimes := "-"
+				if expression, err := definition.expression(); err != nil {
+					fireTimes = "invalid: " + err.Error()
+				} else if times := expression.next(now, count); len(times) > 0 {
+					fireTimes = formatFireTimes(times)
+				}
+				timezone := definition.Timezone
+				if timezone == "" {
+					timezone = "UTC"
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", definition.Name, definition.Schedule, timezone, definition.target(), fireTimes)
+			}
+			return writer.Flush()
+		},
+	}
+
+	listCmd.Flags().IntVarP(&count, "count", "n", 3, "Number of next fire times to print")
+	return listCmd
+}
+
+func newScheduleValidateCommand(schedulesFile *string) *cobra.Command {
+	var count int
+	var timezone string
+
+	validateCmd := &cobra.Command{
+		Use:   "validate [expression...]",
+		Short: "Validate the schedules file, or the schedule expressions printing their next fire times",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return validateScheduleExpressions(args, timezone, count)
+			}
+
+			definitions, err := loadScheduleDefinitions(*schedulesFile)
+			if err != nil {
+				return err
+			}
+			if errs := validateScheduleDefinitions(definitions); len(errs) > 0 {
+				return errors.Join(errs...)
+			}
+			fmt.Printf("%d schedules of %s are valid\n", len(definitions), *schedulesFile)
+			return nil
+		},
+	}
+
+	validateCmd.Flags().IntVarP(&count, "count", "n", 5, "Number of next fire times to print")
+	validateCmd.Flags().StringVar(&timezone, "timezone", "", "Timezone of the schedule expressions e.g. Europe/Berlin (default UTC)")
+	return validateCmd
+}
+
+// Print the next fire times of each schedule expression
+func validateScheduleExpressions(expressions []string, timezone string, count int) error {
+	now := time.Now()
+	for _, value := range expressions {
+		expression, err := scheduleDefinition{Schedule: value, Timezone: timezone}.expression()
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		for _, fireTime := range expression.next(now, count) {
+			fmt.Println("  " + fireTime.Format(fireTimeLayout))
+		}
+	}
+	return nil
+}
+
+func formatFireTimes(times []time.Time) string {
+	values := make([]string, len(times))
+	for i, fireTime := range times {
+		values[i] = fireTime.Format(fireTimeLayout)
+	}
+	return strings.Join(values, ", ")
+}
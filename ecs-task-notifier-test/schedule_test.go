@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var scheduleDefinitionValidateTests = map[string]struct {
+	definition scheduleDefinition
+	wantErr    bool
+}{
+	"cluster":            {scheduleDefinition{Name: "nightly", Schedule: "cron(0 2 * * ? *)", Cluster: "c1"}, false},
+	"clusters":           {scheduleDefinition{Name: "nightly", Schedule: "rate(1 hour)", Clusters: []string{"c1", "c2"}}, false},
+	"cluster tags":       {scheduleDefinition{Name: "nightly", Schedule: "rate(1 hour)", ClusterTags: map[string]string{"env": "prod"}}, false},
+	"timezone":           {scheduleDefinition{Name: "nightly", Schedule: "cron(0 2 * * ? *)", Timezone: "Europe/Berlin", Cluster: "c1"}, false},
+	"missing name":       {scheduleDefinition{Schedule: "cron(0 2 * * ? *)", Cluster: "c1"}, true},
+	"invalid name":       {scheduleDefinition{Name: "nightly run", Schedule: "cron(0 2 * * ? *)", Cluster: "c1"}, true},
+	"invalid expression": {scheduleDefinition{Name: "nightly", Schedule: "cron(0 2 * * *)", Cluster: "c1"}, true},
+	"invalid timezone":   {scheduleDefinition{Name: "nightly", Schedule: "cron(0 2 * * ? *)", Timezone: "Mars/Base", Cluster: "c1"}, true},
+	"missing target":     {scheduleDefinition{Name: "nightly", Schedule: "cron(0 2 * * ? *)", Topic: "cache.rotate"}, true},
+	"missing expression": {scheduleDefinition{Name: "nightly", Cluster: "c1"}, true},
+}
+
+func TestScheduleDefinitionValidate(t *testing.T) {
+	for name, tc := range scheduleDefinitionValidateTests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.definition.validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleDefinitionTarget(t *testing.T) {
+	definition := scheduleDefinition{
+		Cluster:     "c1",
+		Clusters:    []string{"c2"},
+		ClusterTags: map[string]string{"team": "payments", "env": "prod"},
+		Topic:       "cache.rotate",
+	}
+	if actual, want := definition.target(), "c1,c2 env=prod team=payments topic=cache.rotate"; actual != want {
+		t.Errorf("target() = %q, want %q", actual, want)
+	}
+}
+
+func TestLoadScheduleDefinitions(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "schedules.json")
+	content := `[
+		{"name": "nightly", "schedule": "cron(0 2 * * ? *)", "cluster": "*", "topic": "cache.rotate", "payload": {"action": "rotate"}},
+		{"name": "nightly", "schedule": "rate(1 hour)", "clusters": ["c1"]},
+		{"name": "broken", "schedule": "cron(0 2 * * ? *)"}
+	]`
+	if err := os.WriteFile(fileName, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	definitions, err := loadScheduleDefinitions(fileName)
+	if err != nil {
+		t.Fatalf("loadScheduleDefinitions() error = %v", err)
+	}
+	if len(definitions) != 3 {
+		t.Fatalf("loadScheduleDefinitions() = %d definitions, want 3", len(definitions))
+	}
+	if string(definitions[0].Payload) != `{"action": "rotate"}` {
+		t.Errorf("Payload = %s, want %s", definitions[0].Payload, `{"action": "rotate"}`)
+	}
+
+	// Duplicate name and missing target
+	if errs := validateScheduleDefinitions(definitions); len(errs) != 2 {
+		t.Errorf("validateScheduleDefinitions() = %v, want 2 errors", errs)
+	}
+}
+
+func TestLoadScheduleDefinitionsInvalidFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "schedules.json")
+	if err := os.WriteFile(fileName, []byte(`{"name": "nightly"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadScheduleDefinitions(fileName); err == nil {
+		t.Error("loadScheduleDefinitions() error = nil, want error")
+	}
+}